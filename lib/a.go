@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// A represents the RDATA of an A record (RFC1035 section 3.4.1): a
+// 32bit IPv4 address.
+type A struct {
+	Address net.IP
+}
+
+// AAAA represents the RDATA of an AAAA record (RFC3596 section 2.2):
+// a 128bit IPv6 address.
+type AAAA struct {
+	Address net.IP
+}
+
+func unmarshalARData(msg []byte, off, length int) (data RData, err error) {
+	var a = new(A)
+
+	_, err = UnmarshalA(msg[off:off+length], a)
+	if err != nil {
+		return
+	}
+
+	data = a
+	return
+}
+
+func unmarshalAAAARData(msg []byte, off, length int) (data RData, err error) {
+	var a = new(AAAA)
+
+	_, err = UnmarshalAAAA(msg[off:off+length], a)
+	if err != nil {
+		return
+	}
+
+	data = a
+	return
+}
+
+// UnmarshalA decodes the RDATA of an A record into `a`. `msg` must
+// contain exactly the RDATA of the record.
+func UnmarshalA(msg []byte, a *A) (n int, err error) {
+	if a == nil {
+		err = errors.Errorf("a must be non-nil")
+		return
+	}
+
+	if len(msg) != net.IPv4len {
+		err = errors.Errorf(
+			"a rdata must be %d bytes long - %d",
+			net.IPv4len, len(msg))
+		return
+	}
+
+	a.Address = net.IP(append([]byte{}, msg...))
+
+	n = len(msg)
+	return
+}
+
+// UnmarshalAAAA decodes the RDATA of an AAAA record into `a`. `msg`
+// must contain exactly the RDATA of the record.
+func UnmarshalAAAA(msg []byte, a *AAAA) (n int, err error) {
+	if a == nil {
+		err = errors.Errorf("aaaa must be non-nil")
+		return
+	}
+
+	if len(msg) != net.IPv6len {
+		err = errors.Errorf(
+			"aaaa rdata must be %d bytes long - %d",
+			net.IPv6len, len(msg))
+		return
+	}
+
+	a.Address = net.IP(append([]byte{}, msg...))
+
+	n = len(msg)
+	return
+}
+
+// Marshal encodes the RDATA of the A record.
+func (a A) Marshal() (res []byte, err error) {
+	return a.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the A record to `b`.
+func (a A) AppendTo(b []byte) (res []byte, err error) {
+	var address = a.Address.To4()
+
+	if address == nil {
+		err = errors.Errorf(
+			"%s is not an ipv4 address",
+			a.Address)
+		return
+	}
+
+	res = append(b, address...)
+	return
+}
+
+// Marshal encodes the RDATA of the AAAA record.
+func (a AAAA) Marshal() (res []byte, err error) {
+	return a.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the AAAA record to `b`.
+func (a AAAA) AppendTo(b []byte) (res []byte, err error) {
+	if a.Address.To16() == nil || a.Address.To4() != nil {
+		err = errors.Errorf(
+			"%s is not an ipv6 address",
+			a.Address)
+		return
+	}
+
+	res = append(b, a.Address.To16()...)
+	return
+}
+
+// String renders the address in its textual form.
+func (a A) String() string {
+	return a.Address.String()
+}
+
+// String renders the address in its textual form.
+func (a AAAA) String() string {
+	return a.Address.String()
+}
@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// AFSDB represents the RDATA of an AFSDB record as described in
+// RFC1183 section 1: the location of AFS cell database servers
+// or DCE authenticated name servers.
+//
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                    SUBTYPE                    |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                   HOSTNAME                    /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type AFSDB struct {
+
+	// Subtype is either 1 (AFS version 3.0 volume location
+	// server) or 2 (DCE/NCA root cell directory node).
+	Subtype uint16
+
+	// Hostname is the domain name of the server.
+	Hostname string
+}
+
+func unmarshalAFSDBRData(msg []byte, off, length int) (data RData, err error) {
+	var a = new(AFSDB)
+
+	_, err = unmarshalAFSDB(msg, off, off+length, a)
+	if err != nil {
+		return
+	}
+
+	data = a
+	return
+}
+
+// UnmarshalAFSDB decodes the RDATA of an AFSDB record into `a`. `msg`
+// must contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalAFSDB(msg []byte, a *AFSDB) (n int, err error) {
+	return unmarshalAFSDB(msg, 0, len(msg), a)
+}
+
+func unmarshalAFSDB(msg []byte, off, end int, a *AFSDB) (n int, err error) {
+	if a == nil {
+		err = errors.Errorf("afsdb must be non-nil")
+		return
+	}
+
+	var ndx int = off
+
+	if end-ndx < 2 {
+		err = errors.Errorf(
+			"afsdb rdata must be at least 2 bytes long - %d",
+			end-ndx)
+		return
+	}
+
+	a.Subtype = binary.BigEndian.Uint16(msg[ndx : ndx+2])
+	ndx += 2
+
+	a.Hostname, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read hostname")
+		return
+	}
+
+	ndx += n
+
+	err = checkRDATAEnd(ndx, end)
+	if err != nil {
+		return
+	}
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the RDATA of the AFSDB record.
+func (a AFSDB) Marshal() (res []byte, err error) {
+	return a.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the AFSDB record to `b`.
+func (a AFSDB) AppendTo(b []byte) (res []byte, err error) {
+	res = binary.BigEndian.AppendUint16(b, a.Subtype)
+	return appendRDATAName(res, a.Hostname)
+}
+
+// String renders the RDATA in presentation format.
+func (a AFSDB) String() string {
+	return strconv.Itoa(int(a.Subtype)) + " " + fqdn(a.Hostname)
+}
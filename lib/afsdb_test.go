@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAFSDBMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		entity    *AFSDB
+		presented string
+	}{
+		{
+			desc: "well formed",
+			entity: &AFSDB{
+				Subtype:  1,
+				Hostname: "bigbird.toaster.com",
+			},
+			presented: "1 bigbird.toaster.com.",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *AFSDB
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			require.NoError(t, err)
+
+			unmarshalled = new(AFSDB)
+			_, err = UnmarshalAFSDB(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestAFSDBRejectsTrailingBytes(t *testing.T) {
+	var msg = []byte{0, 1, 1, 'a', 0, 0xff}
+
+	_, err := UnmarshalAFSDB(msg, new(AFSDB))
+	require.Error(t, err)
+}
@@ -0,0 +1,477 @@
+package lib
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCacheEntries is the number of RRsets and negative
+	// answers a cache holds by default.
+	defaultCacheEntries = 10000
+
+	// defaultCacheMaxTTL caps how long records are cached by
+	// default, whatever their TTL.
+	defaultCacheMaxTTL = 24 * time.Hour
+
+	// defaultCacheMaxNegativeTTL caps how long negative answers are
+	// cached by default (RFC2308 section 5).
+	defaultCacheMaxNegativeTTL = 3 * time.Hour
+
+	// defaultStaleWindow is how long after expiring records may still
+	// be served stale by default (RFC8767 section 5).
+	defaultStaleWindow = 24 * time.Hour
+
+	// staleTTL is the TTL of the records of stale answers (RFC8767
+	// section 4).
+	staleTTL = 30
+)
+
+// CacheConfig configures a Cache.
+type CacheConfig struct {
+
+	// MaxEntries is the number of RRsets and negative answers the
+	// cache holds before evicting the least recently used ones,
+	// 10000 if zero.
+	MaxEntries int
+
+	// MaxTTL caps how long records are cached, 24 hours if zero.
+	MaxTTL time.Duration
+
+	// MaxNegativeTTL caps how long negative answers are cached, 3
+	// hours if zero.
+	MaxNegativeTTL time.Duration
+
+	// ServeStale keeps records around after they expire so that
+	// they can be served when fresh ones can't be retrieved (see
+	// `Stale`).
+	ServeStale bool
+
+	// StaleWindow is how long after expiring records may be served
+	// stale, 24 hours if zero.
+	StaleWindow time.Duration
+}
+
+// Cache holds the RRsets of responses, along with their negative
+// answers (NXDOMAIN and NODATA, cached for as long as the SOA that
+// comes with them tells as in RFC2308), so that queries can be
+// answered without asking servers again until their TTLs expire.
+//
+// RRsets are indexed by their name, type and class, and come out of
+// the cache with their TTLs decremented by the time they spent in it.
+// Once the cache is full, the least recently used entries are evicted.
+//
+// A cache can be used by a Client (see `ClientConfig`) or wrap a
+// Handler (see `Handler`). It's safe for concurrent use.
+type Cache struct {
+	maxEntries     int
+	maxTTL         uint32
+	maxNegativeTTL uint32
+	serveStale     bool
+	staleWindow    time.Duration
+
+	// now tells the current time, replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+}
+
+// cacheKey identifies an RRset. Negative answers for names that don't
+// exist (NXDOMAIN) apply to every type, and are stored with type 0.
+type cacheKey struct {
+	name  string
+	qtype QType
+	class QClass
+}
+
+// cacheEntry is an RRset or a negative answer held in a cache.
+type cacheEntry struct {
+	key cacheKey
+
+	// rrs is the RRset, or the SOA that came with the negative
+	// answer.
+	rrs      []*RR
+	negative bool
+	rcode    RCODE
+
+	stored time.Time
+	ttl    uint32
+}
+
+// NewCache creates an empty cache.
+func NewCache(cfg CacheConfig) *Cache {
+	c := &Cache{
+		maxEntries:     cfg.MaxEntries,
+		maxTTL:         uint32(cfg.MaxTTL / time.Second),
+		maxNegativeTTL: uint32(cfg.MaxNegativeTTL / time.Second),
+		serveStale:     cfg.ServeStale,
+		staleWindow:    cfg.StaleWindow,
+		now:            time.Now,
+		entries:        make(map[cacheKey]*list.Element),
+		lru:            list.New(),
+	}
+
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultCacheEntries
+	}
+
+	if c.maxTTL == 0 {
+		c.maxTTL = uint32(defaultCacheMaxTTL / time.Second)
+	}
+
+	if c.maxNegativeTTL == 0 {
+		c.maxNegativeTTL = uint32(defaultCacheMaxNegativeTTL / time.Second)
+	}
+
+	if c.staleWindow == 0 {
+		c.staleWindow = defaultStaleWindow
+	}
+
+	return c
+}
+
+// Len returns the number of entries in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Put stores the records of the response `res` that answer its
+// question: the RRset asked for, along with the aliases that lead to
+// it, or the negative answer if there's none. Other records (e.g.,
+// glue or answers for names the question didn't lead to) aren't
+// trusted, and thus aren't stored.
+//
+// Truncated responses, responses to ANY queries and responses with
+// errors other than NXDOMAIN aren't cached.
+func (c *Cache) Put(res *Message) {
+	if res.TC == 1 || len(res.Questions) != 1 ||
+		(res.RCODE != byte(RCODENoError) && res.RCODE != byte(RCODENameError)) {
+		return
+	}
+
+	var question = res.Questions[0]
+
+	if question.QTYPE == QTypeWildcard {
+		return
+	}
+
+	name, err := ParseName(question.QNAME)
+	if err != nil {
+		return
+	}
+
+	var (
+		rrsets = make(map[cacheKey][]*RR)
+		now    = c.now()
+	)
+
+	for _, rr := range res.Answers {
+		owner, err := ParseName(rr.NAME)
+		if err != nil {
+			continue
+		}
+
+		key := cacheKey{owner.Canonical().text(), rr.TYPE, rr.CLASS}
+		rrsets[key] = append(rrsets[key], rr)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for chain := 0; chain <= maxCNAMEChain; chain++ {
+		var key = cacheKey{name.Canonical().text(), question.QTYPE, question.QCLASS}
+
+		if rrset, ok := rrsets[key]; ok {
+			c.add(&cacheEntry{key: key, rrs: rrset, stored: now, ttl: c.ttlOf(rrset)})
+			return
+		}
+
+		key.qtype = QTypeCNAME
+		rrset, ok := rrsets[key]
+		if !ok || question.QTYPE == QTypeCNAME {
+			break
+		}
+
+		c.add(&cacheEntry{key: key, rrs: rrset, stored: now, ttl: c.ttlOf(rrset)})
+
+		data, ok := rrset[0].Data.(*CNAME)
+		if !ok {
+			return
+		}
+
+		name, err = ParseName(data.Target)
+		if err != nil {
+			return
+		}
+	}
+
+	soa := negativeSOAOf(res, name)
+	if soa == nil {
+		return
+	}
+
+	var entry = &cacheEntry{
+		key:      cacheKey{name.Canonical().text(), question.QTYPE, question.QCLASS},
+		rrs:      []*RR{soa},
+		negative: true,
+		rcode:    RCODE(res.RCODE),
+		stored:   now,
+		ttl:      soa.TTL,
+	}
+
+	if data := soa.Data.(*SOA); data.Minimum < entry.ttl {
+		entry.ttl = data.Minimum
+	}
+
+	if entry.ttl > c.maxNegativeTTL {
+		entry.ttl = c.maxNegativeTTL
+	}
+
+	if entry.rcode == RCODENameError {
+		entry.key.qtype = 0
+	}
+
+	c.add(entry)
+}
+
+// negativeSOAOf returns the SOA of the zone of `name` in the authority
+// section of the negative response `res`, if any.
+func negativeSOAOf(res *Message, name Name) *RR {
+	for _, rr := range res.Authorities {
+		if _, ok := rr.Data.(*SOA); !ok {
+			continue
+		}
+
+		zone, err := ParseName(rr.NAME)
+		if err == nil && name.IsSubdomainOf(zone) {
+			return rr
+		}
+	}
+
+	return nil
+}
+
+// ttlOf returns how long the RRset `rrs` may be cached for: the lowest
+// TTL of its records, capped to the maximum of the cache.
+func (c *Cache) ttlOf(rrs []*RR) (ttl uint32) {
+	ttl = c.maxTTL
+
+	for _, rr := range rrs {
+		if rr.TTL < ttl {
+			ttl = rr.TTL
+		}
+	}
+
+	return
+}
+
+// add stores `entry`, replacing the one with the same key and evicting
+// the least recently used ones if the cache gets full.
+func (c *Cache) add(entry *cacheEntry) {
+	if entry.ttl == 0 {
+		return
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// Get answers the request `req` from the cache, following the aliases
+// it holds, with the TTLs of the records decremented by the time they
+// spent in it. Requests that can't be answered only with records that
+// haven't expired miss the cache.
+//
+// Answers come with RA set, as they're the result of recursion.
+func (c *Cache) Get(req *Message) (res *Message, ok bool) {
+	return c.lookup(req, false)
+}
+
+// Stale answers the request `req` like `Get`, but with records that
+// expired less than the stale window ago as well, whose TTLs are set to
+// 30 seconds (RFC8767). It's meant for when fresh records can't be
+// retrieved (e.g., servers time out), and misses if the cache doesn't
+// serve stale records.
+func (c *Cache) Stale(req *Message) (res *Message, ok bool) {
+	if !c.serveStale {
+		return
+	}
+
+	return c.lookup(req, true)
+}
+
+func (c *Cache) lookup(req *Message, stale bool) (res *Message, ok bool) {
+	if req.Opcode != OpcodeQuery || len(req.Questions) != 1 {
+		return
+	}
+
+	var question = req.Questions[0]
+
+	name, err := ParseName(question.QNAME)
+	if err != nil {
+		return
+	}
+
+	res = NewReply(req)
+	res.RA = 1
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for chain := 0; chain <= maxCNAMEChain; chain++ {
+		var key = cacheKey{name.Canonical().text(), question.QTYPE, question.QCLASS}
+
+		if entry := c.entry(cacheKey{key.name, 0, key.class}, stale); entry != nil {
+			res.RCODE = byte(entry.rcode)
+			res.Authorities = c.decremented(entry, stale)
+			return res, true
+		}
+
+		if entry := c.entry(key, stale); entry != nil {
+			if entry.negative {
+				res.Authorities = c.decremented(entry, stale)
+			} else {
+				res.Answers = append(res.Answers, c.decremented(entry, stale)...)
+			}
+
+			return res, true
+		}
+
+		key.qtype = QTypeCNAME
+		entry := c.entry(key, stale)
+		if entry == nil || question.QTYPE == QTypeCNAME {
+			break
+		}
+
+		res.Answers = append(res.Answers, c.decremented(entry, stale)...)
+
+		data, ok := entry.rrs[0].Data.(*CNAME)
+		if !ok {
+			break
+		}
+
+		name, err = ParseName(data.Target)
+		if err != nil {
+			break
+		}
+	}
+
+	return nil, false
+}
+
+// entry returns the entry of `key` unless it expired (or, for stale
+// lookups, unless it expired longer than the stale window ago),
+// marking it as the most recently used. Entries that can't be served
+// anymore are removed.
+func (c *Cache) entry(key cacheKey, stale bool) *cacheEntry {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	var (
+		entry   = elem.Value.(*cacheEntry)
+		expires = entry.stored.Add(time.Duration(entry.ttl) * time.Second)
+		now     = c.now()
+	)
+
+	if c.serveStale && now.After(expires.Add(c.staleWindow)) ||
+		!c.serveStale && !now.Before(expires) {
+		c.remove(elem)
+		return nil
+	}
+
+	if !stale && !now.Before(expires) {
+		return nil
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+// decremented returns copies of the records of `entry` with their TTLs
+// decremented by the time they spent in the cache, which is set to the
+// TTL of stale records if they expired.
+func (c *Cache) decremented(entry *cacheEntry, stale bool) (rrs []*RR) {
+	var (
+		elapsed = uint32(c.now().Sub(entry.stored) / time.Second)
+		ttl     = uint32(staleTTL)
+	)
+
+	if elapsed < entry.ttl {
+		ttl = entry.ttl - elapsed
+	}
+
+	rrs = make([]*RR, len(entry.rrs))
+	for ndx, rr := range entry.rrs {
+		copied := *rr
+		copied.TTL = ttl
+		rrs[ndx] = &copied
+	}
+
+	return
+}
+
+// Handler returns a Handler that answers requests from the cache,
+// passing the ones it misses on to `next` (e.g., a Proxy) and caching
+// the responses it writes. If those come with SERVFAIL, stale answers
+// are written instead, if any.
+//
+// It has the signature of a Middleware, so it can wrap the handlers of
+// a ServeMux (see `Use`).
+func (c *Cache) Handler(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		if res, ok := c.Get(r); ok {
+			w.WriteMsg(res)
+			return
+		}
+
+		var recorder = &cacheRecorder{ResponseWriter: w}
+
+		next.ServeDNS(recorder, r)
+		if recorder.res == nil {
+			return
+		}
+
+		if recorder.res.RCODE == byte(RCODEServerFailure) {
+			if res, ok := c.Stale(r); ok {
+				w.WriteMsg(res)
+				return
+			}
+		}
+
+		c.Put(recorder.res)
+		w.WriteMsg(recorder.res)
+	})
+}
+
+// cacheRecorder is a ResponseWriter that holds the response written
+// by a handler instead of sending it.
+type cacheRecorder struct {
+	ResponseWriter
+	res *Message
+}
+
+func (w *cacheRecorder) WriteMsg(m *Message) (err error) {
+	w.res = m
+	return
+}
@@ -0,0 +1,380 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRecords parses records in the format of master files.
+func testRecords(t *testing.T, lines ...string) []*RR {
+	rrs, err := ParseZone(strings.NewReader(strings.Join(lines, "\n")), ".", "test")
+	require.NoError(t, err)
+
+	return rrs
+}
+
+func testResponse(qname string, qtype QType, rcode RCODE, answers, authorities []*RR) *Message {
+	req := testQuery(1, qname)
+	req.Questions[0].QTYPE = qtype
+
+	res := NewReply(req)
+	res.RCODE = byte(rcode)
+	res.Answers = answers
+	res.Authorities = authorities
+
+	return res
+}
+
+// testCache creates a cache whose clock only moves with the function
+// it returns.
+func testCache(cfg CacheConfig) (*Cache, func(d time.Duration)) {
+	var (
+		cache = NewCache(cfg)
+		now   = time.Unix(1700000000, 0)
+	)
+
+	cache.now = func() time.Time { return now }
+
+	return cache, func(d time.Duration) { now = now.Add(d) }
+}
+
+func cacheQuery(qname string, qtype QType) *Message {
+	req := testQuery(9, qname)
+	req.Questions[0].QTYPE = qtype
+
+	return req
+}
+
+const testSOA = "example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 604800 300"
+
+func TestCache(t *testing.T) {
+	var testCases = []struct {
+		desc        string
+		response    *Message
+		qname       string
+		qtype       QType
+		elapsed     time.Duration
+		miss        bool
+		rcode       RCODE
+		answers     []string
+		authorities []string
+	}{
+		{
+			desc: "rrset",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1",
+				"example.com. 300 IN A 192.0.2.2"), nil),
+			qname: "EXAMPLE.com",
+			qtype: QTypeA,
+			answers: []string{
+				"example.com. 300 IN A 192.0.2.1",
+				"example.com. 300 IN A 192.0.2.2",
+			},
+		},
+		{
+			desc: "ttl decremented",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1"), nil),
+			qname:   "example.com",
+			qtype:   QTypeA,
+			elapsed: 100 * time.Second,
+			answers: []string{"example.com. 200 IN A 192.0.2.1"},
+		},
+		{
+			desc: "lowest ttl of the rrset",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1",
+				"example.com. 60 IN A 192.0.2.2"), nil),
+			qname:   "example.com",
+			qtype:   QTypeA,
+			elapsed: 10 * time.Second,
+			answers: []string{
+				"example.com. 50 IN A 192.0.2.1",
+				"example.com. 50 IN A 192.0.2.2",
+			},
+		},
+		{
+			desc: "expired",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1"), nil),
+			qname:   "example.com",
+			qtype:   QTypeA,
+			elapsed: 300 * time.Second,
+			miss:    true,
+		},
+		{
+			desc: "other type",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1"), nil),
+			qname: "example.com",
+			qtype: QTypeAAAA,
+			miss:  true,
+		},
+		{
+			desc: "zero ttl",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 0 IN A 192.0.2.1"), nil),
+			qname: "example.com",
+			qtype: QTypeA,
+			miss:  true,
+		},
+		{
+			desc: "cname chain",
+			response: testResponse("www.example.com", QTypeA, RCODENoError, testRecords(t,
+				"www.example.com. 600 IN CNAME web.example.com.",
+				"web.example.com. 60 IN A 192.0.2.3"), nil),
+			qname: "www.example.com",
+			qtype: QTypeA,
+			answers: []string{
+				"www.example.com. 600 IN CNAME web.example.com.",
+				"web.example.com. 60 IN A 192.0.2.3",
+			},
+		},
+		{
+			desc: "target of a cname chain",
+			response: testResponse("www.example.com", QTypeA, RCODENoError, testRecords(t,
+				"www.example.com. 600 IN CNAME web.example.com.",
+				"web.example.com. 60 IN A 192.0.2.3"), nil),
+			qname:   "web.example.com",
+			qtype:   QTypeA,
+			answers: []string{"web.example.com. 60 IN A 192.0.2.3"},
+		},
+		{
+			desc: "cname chain with the target expired",
+			response: testResponse("www.example.com", QTypeA, RCODENoError, testRecords(t,
+				"www.example.com. 600 IN CNAME web.example.com.",
+				"web.example.com. 60 IN A 192.0.2.3"), nil),
+			qname:   "www.example.com",
+			qtype:   QTypeA,
+			elapsed: 60 * time.Second,
+			miss:    true,
+		},
+		{
+			desc: "records the question doesn't lead to",
+			response: testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1",
+				"bank.example. 300 IN A 203.0.113.1"), nil),
+			qname: "bank.example",
+			qtype: QTypeA,
+			miss:  true,
+		},
+		{
+			desc:        "nxdomain",
+			response:    testResponse("nope.example.com", QTypeA, RCODENameError, nil, testRecords(t, testSOA)),
+			qname:       "nope.example.com",
+			qtype:       QTypeA,
+			elapsed:     100 * time.Second,
+			rcode:       RCODENameError,
+			authorities: []string{strings.Replace(testSOA, "3600", "200", 1)},
+		},
+		{
+			desc:        "nxdomain of other types",
+			response:    testResponse("nope.example.com", QTypeA, RCODENameError, nil, testRecords(t, testSOA)),
+			qname:       "nope.example.com",
+			qtype:       QTypeMX,
+			rcode:       RCODENameError,
+			authorities: []string{strings.Replace(testSOA, "3600", "300", 1)},
+		},
+		{
+			desc:     "nxdomain expired by the soa minimum",
+			response: testResponse("nope.example.com", QTypeA, RCODENameError, nil, testRecords(t, testSOA)),
+			qname:    "nope.example.com",
+			qtype:    QTypeA,
+			elapsed:  300 * time.Second,
+			miss:     true,
+		},
+		{
+			desc:        "nodata",
+			response:    testResponse("example.com", QTypeAAAA, RCODENoError, nil, testRecords(t, testSOA)),
+			qname:       "example.com",
+			qtype:       QTypeAAAA,
+			authorities: []string{strings.Replace(testSOA, "3600", "300", 1)},
+		},
+		{
+			desc:     "nodata of other types",
+			response: testResponse("example.com", QTypeAAAA, RCODENoError, nil, testRecords(t, testSOA)),
+			qname:    "example.com",
+			qtype:    QTypeA,
+			miss:     true,
+		},
+		{
+			desc: "nxdomain after a cname",
+			response: testResponse("www.example.com", QTypeA, RCODENameError, testRecords(t,
+				"www.example.com. 600 IN CNAME nope.example.com."), testRecords(t, testSOA)),
+			qname:       "www.example.com",
+			qtype:       QTypeA,
+			rcode:       RCODENameError,
+			answers:     []string{"www.example.com. 600 IN CNAME nope.example.com."},
+			authorities: []string{strings.Replace(testSOA, "3600", "300", 1)},
+		},
+		{
+			desc:     "negative answer without a soa",
+			response: testResponse("nope.example.com", QTypeA, RCODENameError, nil, nil),
+			qname:    "nope.example.com",
+			qtype:    QTypeA,
+			miss:     true,
+		},
+		{
+			desc: "soa of another zone",
+			response: testResponse("nope.example.com", QTypeA, RCODENameError, nil, testRecords(t,
+				"example.net. 3600 IN SOA ns1.example.net. hostmaster.example.net. 1 7200 900 604800 300")),
+			qname: "nope.example.com",
+			qtype: QTypeA,
+			miss:  true,
+		},
+		{
+			desc: "servfail",
+			response: testResponse("example.com", QTypeA, RCODEServerFailure, testRecords(t,
+				"example.com. 300 IN A 192.0.2.1"), nil),
+			qname: "example.com",
+			qtype: QTypeA,
+			miss:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cache, advance := testCache(CacheConfig{})
+
+			cache.Put(tc.response)
+			advance(tc.elapsed)
+
+			res, ok := cache.Get(cacheQuery(tc.qname, tc.qtype))
+			if tc.miss {
+				assert.False(t, ok)
+				return
+			}
+
+			require.True(t, ok)
+			assert.Equal(t, uint16(9), res.ID)
+			assert.Equal(t, byte(1), res.QR)
+			assert.Equal(t, byte(1), res.RA)
+			assert.Equal(t, byte(tc.rcode), res.RCODE)
+			assert.Equal(t, tc.answers, recordLines(res.Answers))
+			assert.Equal(t, tc.authorities, recordLines(res.Authorities))
+		})
+	}
+}
+
+func TestCache_MaxTTL(t *testing.T) {
+	cache, advance := testCache(CacheConfig{MaxTTL: time.Minute, MaxNegativeTTL: 10 * time.Second})
+
+	cache.Put(testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+		"example.com. 86400 IN A 192.0.2.1"), nil))
+	cache.Put(testResponse("nope.example.com", QTypeA, RCODENameError, nil, testRecords(t, testSOA)))
+
+	res, ok := cache.Get(cacheQuery("example.com", QTypeA))
+	require.True(t, ok)
+	assert.Equal(t, uint32(60), res.Answers[0].TTL)
+
+	advance(10 * time.Second)
+
+	_, ok = cache.Get(cacheQuery("nope.example.com", QTypeA))
+	assert.False(t, ok)
+}
+
+func TestCache_evictsLeastRecentlyUsed(t *testing.T) {
+	cache, _ := testCache(CacheConfig{MaxEntries: 2})
+
+	for _, name := range []string{"a.example", "b.example"} {
+		cache.Put(testResponse(name, QTypeA, RCODENoError, testRecords(t, name+". 300 IN A 192.0.2.1"), nil))
+	}
+
+	_, ok := cache.Get(cacheQuery("a.example", QTypeA))
+	require.True(t, ok)
+
+	cache.Put(testResponse("c.example", QTypeA, RCODENoError, testRecords(t, "c.example. 300 IN A 192.0.2.1"), nil))
+	assert.Equal(t, 2, cache.Len())
+
+	for name, cached := range map[string]bool{"a.example": true, "b.example": false, "c.example": true} {
+		_, ok := cache.Get(cacheQuery(name, QTypeA))
+		assert.Equal(t, cached, ok, name)
+	}
+}
+
+func TestCache_Stale(t *testing.T) {
+	var response = testResponse("example.com", QTypeA, RCODENoError, testRecords(t,
+		"example.com. 300 IN A 192.0.2.1"), nil)
+
+	t.Run("fresh", func(t *testing.T) {
+		cache, advance := testCache(CacheConfig{ServeStale: true})
+		cache.Put(response)
+		advance(100 * time.Second)
+
+		res, ok := cache.Stale(cacheQuery("example.com", QTypeA))
+		require.True(t, ok)
+		assert.Equal(t, uint32(200), res.Answers[0].TTL)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cache, advance := testCache(CacheConfig{ServeStale: true})
+		cache.Put(response)
+		advance(time.Hour)
+
+		_, ok := cache.Get(cacheQuery("example.com", QTypeA))
+		assert.False(t, ok)
+
+		res, ok := cache.Stale(cacheQuery("example.com", QTypeA))
+		require.True(t, ok)
+		assert.Equal(t, []string{"example.com. 30 IN A 192.0.2.1"}, recordLines(res.Answers))
+	})
+
+	t.Run("past the stale window", func(t *testing.T) {
+		cache, advance := testCache(CacheConfig{ServeStale: true, StaleWindow: time.Hour})
+		cache.Put(response)
+		advance(2 * time.Hour)
+
+		_, ok := cache.Stale(cacheQuery("example.com", QTypeA))
+		assert.False(t, ok)
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cache, advance := testCache(CacheConfig{})
+		cache.Put(response)
+		advance(time.Hour)
+
+		_, ok := cache.Stale(cacheQuery("example.com", QTypeA))
+		assert.False(t, ok)
+	})
+}
+
+func TestCache_Handler(t *testing.T) {
+	var (
+		failing  bool
+		upstream = &countingHandler{next: HandlerFunc(func(w ResponseWriter, r *Message) {
+			if failing {
+				ErrorHandler(RCODEServerFailure).ServeDNS(w, r)
+				return
+			}
+
+			addressHandler(1).ServeDNS(w, r)
+		})}
+		cache, advance = testCache(CacheConfig{ServeStale: true})
+		handler        = cache.Handler(upstream)
+	)
+
+	for id := uint16(1); id <= 3; id++ {
+		res := HandleMessage(context.Background(), handler, testQuery(id, "example.com"))
+		require.Len(t, res.Answers, 1)
+		assert.Equal(t, id, res.ID)
+	}
+
+	assert.Equal(t, 1, upstream.requests())
+
+	failing = true
+	advance(time.Hour)
+
+	res := HandleMessage(context.Background(), handler, testQuery(4, "example.com"))
+	assert.Equal(t, 2, upstream.requests())
+	assert.Equal(t, byte(RCODENoError), res.RCODE)
+	assert.Equal(t, []string{"example.com. 30 IN A 192.0.2.0"}, recordLines(res.Answers))
+
+	res = HandleMessage(context.Background(), handler, testQuery(5, "example.net"))
+	assert.Equal(t, byte(RCODEServerFailure), res.RCODE)
+}
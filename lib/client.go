@@ -1,22 +1,52 @@
 package lib
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
 	"net"
-	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
-type Client struct {
-	nextId uint16
-	conn   net.Conn
+const (
+	// defaultClientTimeout is how long an exchange may take by
+	// default, TCP fallback included.
+	defaultClientTimeout = 5 * time.Second
+)
 
-	mu sync.Mutex
+var (
+	// ErrCaseMismatch is the cause of the errors of exchanges whose
+	// responses don't echo the case of the name asked (see
+	// `ClientConfig.RandomizeCase`), which is a sign of spoofing.
+	ErrCaseMismatch = errors.New("response doesn't echo the case of the name asked")
+)
+
+type Client struct {
+	address       string
+	timeout       time.Duration
+	cache         *Cache
+	randomizeCase bool
 }
 
 type ClientConfig struct {
 	Address string
+
+	// Timeout is how long an exchange with the server may take, 5
+	// seconds if zero.
+	Timeout time.Duration
+
+	// Cache, if set, answers the queries of the client (see `Query`)
+	// from the responses to previous ones.
+	Cache *Cache
+
+	// RandomizeCase sends the names asked with the case of their
+	// letters picked at random, requiring responses to echo them
+	// byte for byte (DNS 0x20 encoding), which makes spoofing them
+	// harder. Responses that don't are taken as spoofed.
+	RandomizeCase bool
 }
 
 func NewClient(cfg ClientConfig) (c Client, err error) {
@@ -25,14 +55,22 @@ func NewClient(cfg ClientConfig) (c Client, err error) {
 		return
 	}
 
-	c.conn, err = net.Dial("udp", cfg.Address)
+	_, err = net.ResolveUDPAddr("udp", cfg.Address)
 	if err != nil {
 		err = errors.Wrapf(err,
-			"failed to create connection to address %s",
+			"failed to resolve address %s",
 			cfg.Address)
 		return
 	}
 
+	c.address = cfg.Address
+	c.cache = cfg.Cache
+	c.randomizeCase = cfg.RandomizeCase
+	c.timeout = cfg.Timeout
+	if c.timeout == 0 {
+		c.timeout = defaultClientTimeout
+	}
+
 	return
 }
 
@@ -42,22 +80,57 @@ func NewClient(cfg ClientConfig) (c Client, err error) {
 // unmarshall the answer
 // grab the ips from the answer
 func (c *Client) LookupAddr(addr string) (ips []string, err error) {
-	var (
-		id      uint16
-		payload []byte
-	)
+	responseMsg, err := c.Query(addr, QTypeA)
+	if err != nil {
+		return
+	}
 
-	func() {
-		c.mu.Lock()
-		defer c.mu.Unlock()
+	for _, answer := range responseMsg.Answers {
+		record, ok := answer.Data.(*A)
+		if !ok {
+			continue
+		}
 
-		id = c.nextId
-		c.nextId += 1
-	}()
+		ips = append(ips, record.Address.String())
+	}
 
+	return
+}
+
+// LookupHTTPS retrieves the HTTPS records (RFC9460) of the name `name`,
+// which let clients discover the ALPN protocols, alternative endpoints
+// and ECH configuration of an HTTPS origin.
+func (c *Client) LookupHTTPS(name string) (records []*SVCB, err error) {
+	responseMsg, err := c.Query(name, QTypeHTTPS)
+	if err != nil {
+		return
+	}
+
+	for _, answer := range responseMsg.Answers {
+		if answer.TYPE != QTypeHTTPS {
+			continue
+		}
+
+		record, ok := answer.Data.(*SVCB)
+		if !ok {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return
+}
+
+// Query sends a recursive query for records of type `qtype` of the
+// name `name` and reads the response.
+//
+// With a cache, queries are answered from it while the records last,
+// and responses are stored in it. Stale records are served if the
+// server can't be reached or fails (SERVFAIL) and the cache allows.
+func (c *Client) Query(name string, qtype QType) (responseMsg *Message, err error) {
 	queryMsg := &Message{
 		Header: Header{
-			ID:      id,
 			QR:      0,
 			Opcode:  OpcodeQuery,
 			QDCOUNT: 1,
@@ -65,52 +138,364 @@ func (c *Client) LookupAddr(addr string) (ips []string, err error) {
 		},
 		Questions: []*Question{
 			{
-				QNAME:  addr,
-				QTYPE:  QTypeA,
+				QNAME:  name,
+				QTYPE:  qtype,
 				QCLASS: QClassIN,
 			},
 		},
 	}
 
-	payload, _ = queryMsg.Marshal()
-	_, err = c.conn.Write(payload)
+	if c.cache == nil {
+		responseMsg, _, err = c.Exchange(context.Background(), queryMsg)
+		return
+	}
+
+	if cached, ok := c.cache.Get(queryMsg); ok {
+		return cached, nil
+	}
+
+	responseMsg, _, err = c.Exchange(context.Background(), queryMsg)
+	if err != nil || responseMsg.RCODE == byte(RCODEServerFailure) {
+		if stale, ok := c.cache.Stale(queryMsg); ok {
+			return stale, nil
+		}
+
+		return
+	}
+
+	c.cache.Put(responseMsg)
+	return
+}
+
+// Exchange sends the query `req` to the server and reads its response,
+// along with how long it took to arrive.
+//
+// The query goes out with an ID of the client's own (`req` isn't
+// modified) and the counts of its header set from its sections. IDs
+// are picked at random, so that off-path attackers can't guess them to
+// spoof responses. It's sent over UDP through a connection of its own,
+// with responses that don't match its ID and questions ignored, and
+// retried over TCP if the response comes truncated.
+//
+// With `RandomizeCase`, the names of the questions go out with their
+// case randomized, which responses are given back with the case of
+// `req` (along with the owners of their records that echo them).
+func (c *Client) Exchange(ctx context.Context, req *Message) (res *Message, rtt time.Duration, err error) {
+	var (
+		query   = withCounts(req)
+		payload []byte
+		start   = time.Now()
+	)
+
+	query.ID, err = randomID()
+	if err != nil {
+		return
+	}
+
+	if c.randomizeCase {
+		query.Questions, err = randomizeQuestions(req.Questions)
+		if err != nil {
+			return
+		}
+	}
+
+	payload, err = query.Marshal()
 	if err != nil {
 		err = errors.Wrapf(err,
-			"failed to write query payload %+v",
-			queryMsg)
+			"failed to marshal query %+v",
+			query)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err = c.exchange(ctx, "udp", &query, payload)
+	if err == nil && res.TC == 1 {
+		res, err = c.exchange(ctx, "tcp", &query, payload)
+	}
+
+	if err == nil && c.randomizeCase {
+		restoreCase(res, query.Questions, req.Questions)
+	}
+
+	rtt = time.Since(start)
+	return
+}
+
+// exchange sends the query `payload` over `network` and reads the
+// response to it.
+func (c *Client) exchange(ctx context.Context, network string, query *Message, payload []byte) (res *Message, err error) {
+	var (
+		dialer net.Dialer
+		conn   net.Conn
+		buf    = make([]byte, 65535)
+		n      int
+
+		// mismatched tells whether a response that doesn't echo
+		// the case of the query was ignored.
+		mismatched bool
+	)
+
+	conn, err = dialer.DialContext(ctx, network, c.address)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to create connection to address %s",
+			c.address)
 		return
 	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
 
-	fmt.Printf("msg sent %s\n", string(payload))
+	if network == "tcp" {
+		payload = append(binary.BigEndian.AppendUint16(nil, uint16(len(payload))), payload...)
+	}
 
-	buf := make([]byte, 1024)
-	_, err = c.conn.Read(buf)
+	_, err = conn.Write(payload)
 	if err != nil {
 		err = errors.Wrapf(err,
-			"failed to read from conn")
+			"failed to write query payload %+v",
+			query)
 		return
+	}
+
+	for {
+		if network == "tcp" {
+			_, err = io.ReadFull(conn, buf[:2])
+			if err == nil {
+				n = int(binary.BigEndian.Uint16(buf[:2]))
+				_, err = io.ReadFull(conn, buf[:n])
+			}
+		} else {
+			n, err = conn.Read(buf)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+
+			if mismatched {
+				err = errors.Wrapf(ErrCaseMismatch,
+					"no other response arrived (%v)",
+					err)
+			}
+
+			err = errors.Wrapf(err,
+				"failed to read from conn")
+			return
+		}
+
+		res = &Message{}
+		err = UnmarshalMessage(buf[:n], res)
+		if err == nil && res.ID == query.ID && res.QR == 1 && isResponseTo(res, query) {
+			if !c.randomizeCase || echoesCase(res, query) {
+				return
+			}
+
+			mismatched = true
+			err = ErrCaseMismatch
+		}
 
+		// responses that can't be read or that are meant for
+		// other queries (e.g., late or spoofed) are ignored over
+		// UDP, while over TCP the stream carries ours only.
+		if network == "tcp" {
+			if err == nil {
+				err = errors.Errorf(
+					"response %d doesn't match query %d",
+					res.ID, query.ID)
+			}
+
+			err = errors.Wrapf(err,
+				"failed to read message")
+			return
+		}
 	}
+}
+
+// isResponseTo tells whether the questions of the response `res` are
+// the ones of the query `query`. Errors (e.g., FORMERR) may come
+// without them.
+func isResponseTo(res, query *Message) bool {
+	if len(res.Questions) == 0 && res.RCODE != byte(RCODENoError) {
+		return true
+	}
+
+	if len(res.Questions) != len(query.Questions) {
+		return false
+	}
+
+	for ndx, q := range query.Questions {
+		r := res.Questions[ndx]
+
+		if r.QTYPE != q.QTYPE || r.QCLASS != q.QCLASS {
+			return false
+		}
+
+		// queries may carry internationalized names, which go
+		// out as A-labels. Others are taken as they are, as names
+		// with escaped octets (e.g., `\200.example`) aren't valid
+		// IDNs.
+		parse := ParseName
+		if !isASCII(q.QNAME) {
+			parse = ParseIDN
+		}
+
+		sent, err := parse(q.QNAME)
+		if err != nil {
+			return false
+		}
+
+		received, err := ParseName(r.QNAME)
+		if err != nil || !sent.Equal(received) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// echoesCase tells whether the names of the questions of the response
+// `res` are the ones of the query `query` byte for byte, i.e., with the
+// same case. Errors may come without them.
+func echoesCase(res, query *Message) bool {
+	if len(res.Questions) == 0 && res.RCODE != byte(RCODENoError) {
+		return true
+	}
+
+	for ndx, q := range query.Questions {
+		sent, err := ParseName(q.QNAME)
+		if err != nil {
+			return false
+		}
 
-	responseMsg := &Message{}
-	err = UnmarshalMessage(buf, responseMsg)
+		received, err := ParseName(res.Questions[ndx].QNAME)
+		if err != nil || sent.text() != received.text() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// randomID picks the ID of a query at random.
+func randomID() (id uint16, err error) {
+	var buf [2]byte
+
+	_, err = rand.Read(buf[:])
 	if err != nil {
 		err = errors.Wrapf(err,
-			"failed to read message")
+			"failed to read random id")
 		return
 	}
 
-	for _, answer := range responseMsg.Answers {
-		fmt.Printf("ANSWER: %+v\n", answer.RDATA)
+	id = binary.BigEndian.Uint16(buf[:])
+	return
+}
+
+// randomizeQuestions returns copies of `questions` with the case of
+// the letters of their names picked at random. Internationalized names
+// are converted to A-labels first.
+func randomizeQuestions(questions []*Question) (res []*Question, err error) {
+	for _, q := range questions {
+		var (
+			copied = *q
+			name   Name
+		)
+
+		name, err = ParseIDN(q.QNAME)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"malformed qname %s",
+				q.QNAME)
+			return
+		}
+
+		copied.QNAME, err = randomizeCase(name.text())
+		if err != nil {
+			return
+		}
+
+		res = append(res, &copied)
 	}
 
 	return
 }
 
-func (c *Client) Close() {
-	if c.conn != nil {
-		c.conn.Close()
+// randomizeCase flips the case of each of the ASCII letters of `s` at
+// random.
+func randomizeCase(s string) (res string, err error) {
+	var (
+		buf  = []byte(s)
+		bits = make([]byte, len(buf))
+	)
+
+	_, err = rand.Read(bits)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read random bits")
+		return
 	}
 
+	for ndx, c := range buf {
+		if ('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') && bits[ndx]&1 == 1 {
+			buf[ndx] = c ^ 0x20
+		}
+	}
+
+	return string(buf), nil
+}
+
+// restoreCase gives the response `res` back the names of `original`,
+// the questions that went out as `sent`, in its questions and in the
+// owners of its records that echo them. Names come in the form they'd
+// have been decoded in had they not been randomized.
+func restoreCase(res *Message, sent, original []*Question) {
+	var names = make(map[string]string)
+
+	for ndx, q := range sent {
+		name, err := ParseName(q.QNAME)
+		if err != nil {
+			continue
+		}
+
+		restored, err := ParseIDN(original[ndx].QNAME)
+		if err != nil {
+			continue
+		}
+
+		names[name.text()] = restored.text()
+	}
+
+	for ndx, q := range res.Questions {
+		if name, ok := names[q.QNAME]; ok {
+			copied := *q
+			copied.QNAME = name
+			res.Questions[ndx] = &copied
+		}
+	}
+
+	for _, section := range [][]*RR{res.Answers, res.Authorities, res.Additionals} {
+		for _, rr := range section {
+			if name, ok := names[rr.NAME]; ok {
+				rr.NAME = name
+			}
+		}
+	}
+}
+
+// Close releases the resources of the client. Exchanges don't keep
+// connections open, so there's nothing left to release once they're
+// done.
+func (c *Client) Close() {
 	return
 }
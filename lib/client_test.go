@@ -0,0 +1,399 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClient(t *testing.T, address string) *Client {
+	client, err := NewClient(ClientConfig{
+		Address: address,
+		Timeout: time.Second,
+	})
+	require.NoError(t, err)
+
+	return &client
+}
+
+func TestClient_Exchange(t *testing.T) {
+	udpAddr, _, _ := startServer(t, &Server{Handler: addressHandler(1)})
+
+	var (
+		client = testClient(t, udpAddr)
+		req    = testQuery(42, "example.com")
+	)
+
+	res, rtt, err := client.Exchange(context.Background(), req)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint16(42), req.ID)
+	assert.Equal(t, byte(1), res.QR)
+	assert.NotZero(t, rtt)
+	require.Len(t, res.Answers, 1)
+	assert.Equal(t, "example.com.\t60\tIN\tA\t192.0.2.0", res.Answers[0].String())
+}
+
+func TestClient_Exchange_fallsBackToTCP(t *testing.T) {
+	var (
+		conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+		server    = &Server{Handler: addressHandler(40)}
+	)
+	require.NoError(t, err)
+
+	// the TCP listener must share the port of the UDP one, as the
+	// client retries against the same address.
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	require.NoError(t, err)
+
+	go server.ServeUDP(conn)
+	go server.ServeTCP(listener)
+	t.Cleanup(func() { server.Close() })
+
+	client := testClient(t, conn.LocalAddr().String())
+
+	res, _, err := client.Exchange(context.Background(), testQuery(1, "example.com"))
+	require.NoError(t, err)
+
+	assert.Equal(t, byte(0), res.TC)
+	assert.Len(t, res.Answers, 40)
+}
+
+func TestClient_Exchange_ignoresOtherResponses(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	go func() {
+		var buf = make([]byte, 512)
+
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var req Message
+		if UnmarshalMessage(buf[:n], &req) != nil {
+			return
+		}
+
+		// a response for another query, one for another name and
+		// garbage come before the right one.
+		other := withCounts(NewReply(&req))
+		other.ID++
+		spoofed := withCounts(NewReply(testQuery(req.ID, "example.net")))
+		right := withCounts(NewReply(&req))
+		right.Answers = []*RR{{
+			NAME: "example.com", TYPE: QTypeA, CLASS: QClassIN, TTL: 60,
+			Data: &A{Address: net.IPv4(192, 0, 2, 1).To4()},
+		}}
+		right.ANCOUNT = 1
+
+		for _, msg := range []Message{other, spoofed} {
+			payload, _ := msg.Marshal()
+			conn.WriteTo(payload, addr)
+		}
+
+		conn.WriteTo([]byte{0xde, 0xad}, addr)
+
+		payload, _ := right.Marshal()
+		conn.WriteTo(payload, addr)
+	}()
+
+	client := testClient(t, conn.LocalAddr().String())
+
+	res, _, err := client.Exchange(context.Background(), testQuery(1, "example.com"))
+	require.NoError(t, err)
+	require.Len(t, res.Answers, 1)
+}
+
+func TestClient_Exchange_timeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client, err := NewClient(ClientConfig{
+		Address: conn.LocalAddr().String(),
+		Timeout: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	_, _, err = client.Exchange(context.Background(), testQuery(1, "example.com"))
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = client.Exchange(ctx, testQuery(1, "example.com"))
+	assert.Error(t, err)
+}
+
+func TestClient_Query_cache(t *testing.T) {
+	var upstream = &countingHandler{next: addressHandler(1)}
+
+	udpAddr, _, _ := startServer(t, &Server{Handler: upstream})
+
+	client, err := NewClient(ClientConfig{
+		Address: udpAddr,
+		Cache:   NewCache(CacheConfig{}),
+	})
+	require.NoError(t, err)
+
+	for ndx := 0; ndx < 3; ndx++ {
+		ips, err := client.LookupAddr("example.com")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"192.0.2.0"}, ips)
+	}
+
+	assert.Equal(t, 1, upstream.requests())
+}
+
+func TestClient_Exchange_RandomizeCase(t *testing.T) {
+	var (
+		received = make(chan string, 64)
+		handler  = HandlerFunc(func(w ResponseWriter, r *Message) {
+			received <- r.Questions[0].QNAME
+			addressHandler(1).ServeDNS(w, r)
+		})
+	)
+
+	udpAddr, _, _ := startServer(t, &Server{Handler: handler})
+
+	client, err := NewClient(ClientConfig{
+		Address:       udpAddr,
+		Timeout:       time.Second,
+		RandomizeCase: true,
+	})
+	require.NoError(t, err)
+
+	var randomized bool
+
+	for ndx := 0; ndx < 32; ndx++ {
+		req := testQuery(1, "www.example-domain.com")
+
+		res, _, err := client.Exchange(context.Background(), req)
+		require.NoError(t, err)
+
+		sent := <-received
+		assert.True(t, strings.EqualFold("www.example-domain.com", sent), sent)
+		randomized = randomized || sent != "www.example-domain.com"
+
+		assert.Equal(t, "www.example-domain.com", req.Questions[0].QNAME)
+		assert.Equal(t, "www.example-domain.com", res.Questions[0].QNAME)
+		assert.Equal(t, "www.example-domain.com", res.Answers[0].NAME)
+	}
+
+	assert.True(t, randomized)
+
+	// names asked in mixed case come back as they were asked.
+	res, _, err := client.Exchange(context.Background(), testQuery(1, "WWW.Example-Domain.com."))
+	require.NoError(t, err)
+	<-received
+
+	assert.Equal(t, "WWW.Example-Domain.com", res.Questions[0].QNAME)
+	assert.Equal(t, "WWW.Example-Domain.com", res.Answers[0].NAME)
+}
+
+// lowercasedReplies answers `req` with a response whose question is
+// lowercased, followed by one that echoes it if `echo` is set.
+func lowercasedReplies(t *testing.T, req *Message, echo bool) (payloads [][]byte) {
+	lowercased := *req.Questions[0]
+	lowercased.QNAME = strings.ToLower(lowercased.QNAME)
+
+	spoofed := withCounts(NewReply(req))
+	spoofed.Questions = []*Question{&lowercased}
+
+	payload, err := spoofed.Marshal()
+	require.NoError(t, err)
+	payloads = append(payloads, payload)
+
+	if echo {
+		payload, err = withCounts(NewReply(req)).Marshal()
+		require.NoError(t, err)
+		payloads = append(payloads, payload)
+	}
+
+	return
+}
+
+func TestClient_Exchange_RandomizeCase_mismatch(t *testing.T) {
+	udpServer := func(t *testing.T, echo bool) string {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		go func() {
+			var (
+				buf = make([]byte, 512)
+				req Message
+			)
+
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil || UnmarshalMessage(buf[:n], &req) != nil {
+				return
+			}
+
+			for _, payload := range lowercasedReplies(t, &req, echo) {
+				conn.WriteTo(payload, from)
+			}
+		}()
+
+		return conn.LocalAddr().String()
+	}
+
+	exchangeWith := func(t *testing.T, address string, timeout time.Duration) (*Message, error) {
+		client, err := NewClient(ClientConfig{
+			Address:       address,
+			Timeout:       timeout,
+			RandomizeCase: true,
+		})
+		require.NoError(t, err)
+
+		res, _, err := client.Exchange(context.Background(), testQuery(1, "www.example-domain.com"))
+		return res, err
+	}
+
+	t.Run("spoofed response before the right one", func(t *testing.T) {
+		res, err := exchangeWith(t, udpServer(t, true), time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "www.example-domain.com", res.Questions[0].QNAME)
+	})
+
+	t.Run("spoofed response only", func(t *testing.T) {
+		_, err := exchangeWith(t, udpServer(t, false), 100*time.Millisecond)
+		require.Error(t, err)
+		assert.Equal(t, ErrCaseMismatch, errors.Cause(err))
+	})
+
+	t.Run("spoofed response over tcp", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { listener.Close() })
+
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			var (
+				buf = make([]byte, 512)
+				req Message
+			)
+
+			if _, err = io.ReadFull(conn, buf[:2]); err != nil {
+				return
+			}
+
+			n := int(binary.BigEndian.Uint16(buf[:2]))
+			if _, err = io.ReadFull(conn, buf[:n]); err != nil || UnmarshalMessage(buf[:n], &req) != nil {
+				return
+			}
+
+			payload := lowercasedReplies(t, &req, false)[0]
+			conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(payload))), payload...))
+		}()
+
+		client, err := NewClient(ClientConfig{
+			Address:       listener.Addr().String(),
+			RandomizeCase: true,
+		})
+		require.NoError(t, err)
+
+		query := withCounts(testQuery(1, "wWw.ExAmPlE-dOmAiN.cOm"))
+		payload, err := query.Marshal()
+		require.NoError(t, err)
+
+		_, err = client.exchange(context.Background(), "tcp", &query, payload)
+		require.Error(t, err)
+		assert.Equal(t, ErrCaseMismatch, errors.Cause(err))
+	})
+}
+
+func TestClient_Exchange_randomIDs(t *testing.T) {
+	var (
+		received = make(chan uint16, 64)
+		handler  = HandlerFunc(func(w ResponseWriter, r *Message) {
+			received <- r.ID
+			addressHandler(1).ServeDNS(w, r)
+		})
+	)
+
+	udpAddr, _, _ := startServer(t, &Server{Handler: handler})
+
+	var (
+		client = testClient(t, udpAddr)
+		ids    = make(map[uint16]bool)
+	)
+
+	for ndx := 0; ndx < 16; ndx++ {
+		_, _, err := client.Exchange(context.Background(), testQuery(1, "example.com"))
+		require.NoError(t, err)
+
+		ids[<-received] = true
+	}
+
+	// picked at random, a few IDs may repeat, but not most of them.
+	assert.Greater(t, len(ids), 8)
+	assert.False(t, ids[0] && ids[1] && ids[2], "ids look sequential")
+}
+
+func TestIsResponseTo(t *testing.T) {
+	var testCases = []struct {
+		desc     string
+		sent     string
+		received string
+		expected bool
+	}{
+		{
+			desc:     "same name",
+			sent:     "example.com",
+			received: "example.com",
+			expected: true,
+		},
+		{
+			desc:     "different case",
+			sent:     "Example.COM",
+			received: "example.com",
+			expected: true,
+		},
+		{
+			desc:     "internationalized name",
+			sent:     "bücher.example",
+			received: "xn--bcher-kva.example",
+			expected: true,
+		},
+		{
+			desc:     "escaped octet",
+			sent:     `\200.example`,
+			received: `\200.example`,
+			expected: true,
+		},
+		{
+			desc:     "different name",
+			sent:     "example.com",
+			received: "example.net",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var (
+				query = testQuery(1, tc.sent)
+				res   = NewReply(testQuery(1, tc.received))
+			)
+
+			assert.Equal(t, tc.expected, isResponseTo(res, query))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CNAME represents the RDATA of a CNAME record (RFC1035 section
+// 3.3.1): the canonical name of the owner, which is an alias.
+type CNAME struct {
+	Target string
+}
+
+func unmarshalCNAMERData(msg []byte, off, length int) (data RData, err error) {
+	var c = new(CNAME)
+
+	_, err = unmarshalCNAME(msg, off, off+length, c)
+	if err != nil {
+		return
+	}
+
+	data = c
+	return
+}
+
+// UnmarshalCNAME decodes the RDATA of a CNAME record into `c`. `msg`
+// must contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalCNAME(msg []byte, c *CNAME) (n int, err error) {
+	return unmarshalCNAME(msg, 0, len(msg), c)
+}
+
+func unmarshalCNAME(msg []byte, off, end int, c *CNAME) (n int, err error) {
+	if c == nil {
+		err = errors.Errorf("cname must be non-nil")
+		return
+	}
+
+	c.Target, n, err = unmarshalRDATAName(msg, off, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read target")
+		return
+	}
+
+	err = checkRDATAEnd(off+n, end)
+	return
+}
+
+// Marshal encodes the RDATA of the CNAME record.
+func (c CNAME) Marshal() (res []byte, err error) {
+	return c.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the CNAME record to `b`.
+func (c CNAME) AppendTo(b []byte) (res []byte, err error) {
+	return appendRDATAName(b, c.Target)
+}
+
+// String renders the RDATA in presentation format.
+func (c CNAME) String() string {
+	return fqdn(c.Target)
+}
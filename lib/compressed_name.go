@@ -2,10 +2,22 @@ package lib
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
+const (
+	// maxLabelLength is the maximum number of octets that a
+	// single label can carry (RFC1035 section 2.3.4).
+	maxLabelLength = 63
+
+	// maxNameLength is the maximum number of octets that a
+	// domain name can take in its wire format, including
+	// the length octets and the terminating root label.
+	maxNameLength = 255
+)
+
 // Compression format expansion is necessary because programs are
 // free to avoid using pointers in the message they generate but
 // all of the consumers are required to understand arriving messages
@@ -33,11 +45,11 @@ func (c CompressedName) Marshal() (res []byte, err error) {
 		msg_0 = (3 << 6)
 	}
 
-	offsetHigh = uint8(c.Offset & uint16(masks[7]))
-	offsetLow = uint8(c.Offset >> 8)
+	offsetHigh = uint8(c.Offset>>8) & masks[5]
+	offsetLow = uint8(c.Offset & uint16(masks[7]))
 
-	msg_1 = offsetLow
 	msg_0 |= offsetHigh
+	msg_1 = offsetLow
 
 	buf.Write([]byte{
 		msg_0,
@@ -49,8 +61,15 @@ func (c CompressedName) Marshal() (res []byte, err error) {
 	return
 }
 
+// ExpandCompressedName retrieves the domain name that the pointer
+// refers to in the message `msg`.
 func (c CompressedName) ExpandCompressedName(msg []byte) (name string, err error) {
-	// TODO
+	if !c.IsPointer {
+		err = errors.Errorf("compressed name is not a pointer")
+		return
+	}
+
+	name, _, err = unmarshalName(msg, int(c.Offset))
 	return
 }
 
@@ -92,9 +111,297 @@ func UnmarshalCompressedName(msg []byte, c *CompressedName) (n int, err error) {
 	highValue = (msg[0] & masks[5])
 	lowValue = msg[1]
 
-	c.Offset = uint16(highValue) | uint16(lowValue)
+	c.Offset = uint16(highValue)<<8 | uint16(lowValue)
 
 	n = 2
 
 	return
 }
+
+// unmarshalName reads the domain name that starts at the offset `off`
+// of the message `msg`, following any compression pointers found on
+// the way.
+//
+// The whole message must be supplied as pointers are offsets from its
+// start. `n` is the number of bytes that the name takes at `off`, that
+// is, up to the first pointer (inclusive) or the root label.
+//
+// The name is returned in presentation format without the trailing
+// dot, with the root name being represented as `.`.
+func unmarshalName(msg []byte, off int) (name string, n int, err error) {
+	n, err = skipName(msg, off)
+	if err != nil {
+		return
+	}
+
+	name = RawName{msg: msg, off: off}.Name().text()
+	return
+}
+
+// unmarshalUncompressedName reads the domain name that starts at the
+// offset `off` of the message `msg` like `unmarshalName` does, but
+// fails if any of its labels is a compression pointer, as required for
+// the names that must not be compressed (e.g., the SVCB TargetName).
+func unmarshalUncompressedName(msg []byte, off int) (name string, n int, err error) {
+	for ndx := off; ndx < len(msg) && msg[ndx] != 0; ndx += int(msg[ndx]) + 1 {
+		if msg[ndx]>>6 == 3 {
+			err = errors.Errorf(
+				"compression pointer at offset %d not allowed",
+				ndx)
+			return
+		}
+	}
+
+	name, n, err = unmarshalName(msg, off)
+	return
+}
+
+// skipName validates the domain name that starts at the offset `off`
+// of the message `msg` without decoding it, returning the number of
+// bytes that it takes at `off` (see `unmarshalName`).
+//
+// Once validated, the name can be walked with `RawName` without any
+// further checks.
+func skipName(msg []byte, off int) (n int, err error) {
+	var (
+		ndx        int = off
+		size       int = 0
+		nameLength int = 1
+		jumped     bool
+		pointer    CompressedName
+	)
+
+	for {
+		if ndx >= len(msg) {
+			err = errors.Errorf(
+				"name at offset %d goes past the end of the message",
+				off)
+			return
+		}
+
+		size = int(msg[ndx])
+
+		switch size >> 6 {
+		case 0:
+			if size == 0 {
+				ndx += 1
+				if !jumped {
+					n = ndx - off
+				}
+
+				return
+			}
+
+			if ndx+size+1 > len(msg) {
+				err = errors.Errorf(
+					"label at offset %d goes past the end of the message",
+					ndx)
+				return
+			}
+
+			nameLength += size + 1
+			if nameLength > maxNameLength {
+				err = errors.Errorf(
+					"name at offset %d exceeds %d octets",
+					off, maxNameLength)
+				return
+			}
+
+			ndx += size + 1
+		case 3:
+			if ndx+2 > len(msg) {
+				err = errors.Errorf(
+					"pointer at offset %d goes past the end of the message",
+					ndx)
+				return
+			}
+
+			_, err = UnmarshalCompressedName(msg[ndx:ndx+2], &pointer)
+			if err != nil {
+				err = errors.Wrapf(err,
+					"failed to read pointer at offset %d",
+					ndx)
+				return
+			}
+
+			// Only allowing pointers to prior occurrences
+			// guarantees that we can't loop forever.
+			if int(pointer.Offset) >= ndx {
+				err = errors.Errorf(
+					"pointer at offset %d does not point backwards - %d",
+					ndx, pointer.Offset)
+				return
+			}
+
+			if !jumped {
+				n = ndx + 2 - off
+				jumped = true
+			}
+
+			ndx = int(pointer.Offset)
+		default:
+			err = errors.Errorf(
+				"unsupported label type %d at offset %d",
+				size>>6, ndx)
+			return
+		}
+	}
+}
+
+// marshalName encodes a domain name given in presentation format in
+// its uncompressed wire format.
+//
+// Both `example.com` and `example.com.` are accepted, with `.` (or
+// the empty string) standing for the root name.
+func marshalName(name string) (res []byte, err error) {
+	return appendName(nil, name)
+}
+
+// appendName appends the uncompressed wire format of a domain name
+// given in presentation format to `b` (see `marshalName`), writing
+// the labels straight into `b` so that nothing else is allocated.
+func appendName(b []byte, name string) (res []byte, err error) {
+	var (
+		start    = len(b)
+		at       = len(b)
+		absolute bool
+		c        byte
+		size     int
+	)
+
+	res = b
+
+	if name == "" || name == "." {
+		res = append(res, 0)
+		return
+	}
+
+	// the length octet of each label is filled in once the label
+	// is over.
+	res = append(res, 0)
+
+	for ndx := 0; ndx < len(name); ndx++ {
+		switch name[ndx] {
+		case '.':
+			err = setLabelLength(res, at, name)
+			if err != nil {
+				return
+			}
+
+			if ndx == len(name)-1 {
+				absolute = true
+				break
+			}
+
+			at = len(res)
+			res = append(res, 0)
+		case '\\':
+			c, size, err = unescapeByte(name[ndx:])
+			if err != nil {
+				err = errors.Wrapf(err,
+					"malformed name %s",
+					name)
+				return
+			}
+
+			res = append(res, c)
+			ndx += size - 1
+		default:
+			res = append(res, name[ndx])
+		}
+	}
+
+	if !absolute {
+		err = setLabelLength(res, at, name)
+		if err != nil {
+			return
+		}
+	}
+
+	res = append(res, 0)
+
+	if len(res)-start > maxNameLength {
+		err = errors.Errorf(
+			"name %s exceeds %d octets",
+			name, maxNameLength)
+		return
+	}
+
+	return
+}
+
+// setLabelLength fills in the length octet at `at` of the label that
+// ends at the end of `b`, making sure that it respects the limits of
+// RFC1035 section 2.3.4.
+func setLabelLength(b []byte, at int, name string) (err error) {
+	var size = len(b) - at - 1
+
+	if size == 0 {
+		err = errors.Errorf(
+			"can't have empty label in name %s",
+			name)
+		return
+	}
+
+	if size > maxLabelLength {
+		err = errors.Errorf(
+			"label %s exceeds %d octets",
+			escapeLabel(string(b[at+1:])), maxLabelLength)
+		return
+	}
+
+	b[at] = byte(size)
+	return
+}
+
+// canonicalName lowercases a name and removes its trailing dot so
+// that names can be compared (RFC4343).
+func canonicalName(name string) string {
+	n, err := ParseName(name)
+	if err != nil {
+		return asciiLower(strings.TrimSuffix(name, "."))
+	}
+
+	return n.Canonical().text()
+}
+
+// asciiLower lowercases only the ASCII letters of `s`, leaving any
+// other octet untouched - labels are case-insensitive only in ASCII
+// and may carry arbitrary binary data.
+func asciiLower(s string) string {
+	var b []byte
+
+	for ndx := 0; ndx < len(s); ndx++ {
+		if s[ndx] < 'A' || s[ndx] > 'Z' {
+			continue
+		}
+
+		if b == nil {
+			b = []byte(s)
+		}
+
+		b[ndx] += 'a' - 'A'
+	}
+
+	if b == nil {
+		return s
+	}
+
+	return string(b)
+}
+
+// isSubdomain tells whether the name `name` is equal to or below
+// `parent`.
+func isSubdomain(name, parent string) bool {
+	n, err := ParseName(name)
+	if err != nil {
+		return false
+	}
+
+	p, err := ParseName(parent)
+	if err != nil {
+		return false
+	}
+
+	return n.IsSubdomainOf(p)
+}
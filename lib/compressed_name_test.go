@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,3 +43,104 @@ func TestCompressedNameMarshallingAndUnmarshalling(t *testing.T) {
 	}
 
 }
+
+func TestUnmarshalName(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		msg        []byte
+		offset     int
+		name       string
+		n          int
+		shouldFail bool
+	}{
+		{
+			desc:   "root",
+			msg:    []byte{0},
+			offset: 0,
+			name:   ".",
+			n:      1,
+		},
+		{
+			desc:   "uncompressed",
+			msg:    []byte{4, 't', 'e', 's', 't', 3, 'c', 'o', 'm', 0},
+			offset: 0,
+			name:   "test.com",
+			n:      10,
+		},
+		{
+			desc: "pointer to previous name",
+			msg: []byte{
+				4, 't', 'e', 's', 't', 3, 'c', 'o', 'm', 0,
+				3, 'w', 'w', 'w', 0xc0, 0,
+			},
+			offset: 10,
+			name:   "www.test.com",
+			n:      6,
+		},
+		{
+			desc:       "pointer loop",
+			msg:        []byte{0xc0, 0},
+			offset:     0,
+			shouldFail: true,
+		},
+		{
+			desc:       "truncated label",
+			msg:        []byte{4, 't', 'e'},
+			offset:     0,
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			name, n, err := unmarshalName(tc.msg, tc.offset)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.name, name)
+			assert.Equal(t, tc.n, n)
+		})
+	}
+}
+
+func TestAppendName(t *testing.T) {
+	var names = []string{
+		"",
+		".",
+		"com",
+		"example.com.",
+		`first\.last.example.com`,
+		`a\.`,
+		`a\\.b`,
+		`a\032b\000.com`,
+		strings.Repeat(strings.Repeat("a", 62)+".", 4) + "a",
+		strings.Repeat(strings.Repeat("a", 62)+".", 4) + "ab",
+		strings.Repeat("a", 64) + ".com",
+		"www..com",
+		".com",
+		`a\25`,
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			prefix := []byte{0xca, 0xfe}
+
+			res, err := appendName(prefix, name)
+
+			parsed, parseErr := ParseName(name)
+			if parseErr != nil {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			expected, err := parsed.Marshal()
+			require.NoError(t, err)
+			assert.Equal(t, append([]byte{0xca, 0xfe}, expected...), res)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the corpus of responses")
+
+// readHexFile reads a message from a file holding it hex encoded, with
+// whitespace ignored and lines starting with `;` taken as comments.
+func readHexFile(t testing.TB, path string) []byte {
+	var encoded strings.Builder
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		encoded.WriteString(strings.Join(strings.Fields(line), ""))
+	}
+	require.NoError(t, scanner.Err())
+
+	msg, err := hex.DecodeString(encoded.String())
+	require.NoError(t, err, "malformed hex in %s", path)
+
+	return msg
+}
+
+// TestCorpus decodes each of the responses under testdata/responses,
+// comparing the result with the golden file next to it and checking
+// that encoding and decoding it again doesn't change it.
+//
+// Run with `-update` to regenerate the golden files.
+func TestCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "responses", "*.hex"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".hex"), func(t *testing.T) {
+			var (
+				msg     = readHexFile(t, path)
+				golden  = strings.TrimSuffix(path, ".hex") + ".golden"
+				decoded Message
+				again   Message
+			)
+
+			require.NoError(t, UnmarshalMessage(msg, &decoded))
+
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(decoded.String()), 0644))
+			}
+
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), decoded.String())
+
+			encoded, err := decoded.Marshal()
+			require.NoError(t, err)
+			require.NoError(t, UnmarshalMessage(encoded, &again))
+			assert.Equal(t, decoded.String(), again.String())
+
+			reencoded, err := again.Marshal()
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(encoded, reencoded))
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+// addResponseSeeds seeds the fuzzer `f` with the messages of the
+// corpus of responses, with `slice` picking the part of each that's
+// fed to the target.
+func addResponseSeeds(f *testing.F, slice func(msg []byte) []byte) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "responses", "*.hex"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(slice(compressedResponse))
+	for _, path := range paths {
+		f.Add(slice(readHexFile(f, path)))
+	}
+}
+
+// records returns the part of a message past its header and first
+// question, where its first record starts.
+func records(msg []byte) []byte {
+	n, err := skipName(msg, 12)
+	if err != nil || len(msg) < 12+n+4 {
+		return nil
+	}
+
+	return msg[12+n+4:]
+}
+
+func FuzzUnmarshalMessage(f *testing.F) {
+	addResponseSeeds(f, func(msg []byte) []byte { return msg })
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		var first, second Message
+
+		if UnmarshalMessage(msg, &first) != nil {
+			return
+		}
+
+		encoded, err := first.Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal decoded message: %v\n%s", err, first)
+		}
+
+		err = UnmarshalMessage(encoded, &second)
+		if err != nil {
+			t.Fatalf("failed to decode marshalled message: %v\n%s", err, first)
+		}
+
+		if first.String() != second.String() {
+			t.Fatalf("message changed after a round trip:\n%s\n%s", first, second)
+		}
+
+		reencoded, err := second.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("encoding changed after a round trip:\n%x\n%x", encoded, reencoded)
+		}
+	})
+}
+
+func FuzzUnmarshalRR(f *testing.F) {
+	addResponseSeeds(f, records)
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		var first, second RR
+
+		if _, err := UnmarshalRR(msg, &first); err != nil {
+			return
+		}
+
+		encoded, err := first.Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal decoded record: %v\n%s", err, &first)
+		}
+
+		n, err := UnmarshalRR(encoded, &second)
+		if err != nil {
+			t.Fatalf("failed to decode marshalled record: %v\n%s", err, &first)
+		}
+
+		if n != len(encoded) {
+			t.Fatalf("decoded %d bytes out of %d", n, len(encoded))
+		}
+
+		if !first.Equal(&second) || first.String() != second.String() {
+			t.Fatalf("record changed after a round trip:\n%s\n%s", &first, &second)
+		}
+	})
+}
+
+func FuzzUnmarshalQuestion(f *testing.F) {
+	addResponseSeeds(f, func(msg []byte) []byte {
+		if len(msg) < 12 {
+			return nil
+		}
+
+		return msg[12:]
+	})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		var first, second Question
+
+		if _, err := UnmarshalQuestion(msg, &first); err != nil {
+			return
+		}
+
+		encoded, err := first.Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal decoded question: %v\n%s", err, first)
+		}
+
+		_, err = UnmarshalQuestion(encoded, &second)
+		if err != nil {
+			t.Fatalf("failed to decode marshalled question: %v\n%s", err, first)
+		}
+
+		if first != second {
+			t.Fatalf("question changed after a round trip:\n%s\n%s", first, second)
+		}
+	})
+}
+
+func FuzzUnmarshalName(f *testing.F) {
+	addResponseSeeds(f, func(msg []byte) []byte {
+		if len(msg) < 12 {
+			return nil
+		}
+
+		return msg[12:]
+	})
+	f.Add([]byte{0})
+	f.Add([]byte{3, 'a', '.', 'b', 1, '\\', 0})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		var first, second Name
+
+		if _, err := UnmarshalName(msg, &first); err != nil {
+			return
+		}
+
+		encoded, err := first.AppendTo(nil)
+		if err != nil {
+			t.Fatalf("failed to marshal decoded name %s: %v", first, err)
+		}
+
+		n, err := UnmarshalName(encoded, &second)
+		if err != nil {
+			t.Fatalf("failed to decode marshalled name %s: %v", first, err)
+		}
+
+		if n != len(encoded) || !first.Equal(second) || first.String() != second.String() {
+			t.Fatalf("name changed after a round trip: %s %s", first, second)
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package lib
 
 import (
-	"bytes"
 	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -27,8 +29,49 @@ const (
 	OpcodeQuery Opcode = iota
 	OpcodeIquery
 	OpcodeStatus
+
+	// Zone change notification (RFC1996)
+	OpcodeNotify Opcode = 4
+
+	// Dynamic update (RFC2136)
+	OpcodeUpdate Opcode = 5
 )
 
+var rcodeNames = map[RCODE]string{
+	RCODENoError:        "NOERROR",
+	RCODEFormatError:    "FORMERR",
+	RCODEServerFailure:  "SERVFAIL",
+	RCODENameError:      "NXDOMAIN",
+	RCODENotImplemented: "NOTIMP",
+	RCODERefused:        "REFUSED",
+}
+
+var opcodeNames = map[Opcode]string{
+	OpcodeQuery:  "QUERY",
+	OpcodeIquery: "IQUERY",
+	OpcodeStatus: "STATUS",
+	OpcodeNotify: "NOTIFY",
+	OpcodeUpdate: "UPDATE",
+}
+
+// String returns the mnemonic of the response code.
+func (r RCODE) String() string {
+	if name, ok := rcodeNames[r]; ok {
+		return name
+	}
+
+	return "RCODE" + strconv.Itoa(int(r))
+}
+
+// String returns the mnemonic of the opcode.
+func (o Opcode) String() string {
+	if name, ok := opcodeNames[o]; ok {
+		return name
+	}
+
+	return "OPCODE" + strconv.Itoa(int(o))
+}
+
 // Header encapsulates the construct of the header part of the DNS
 // query message.
 // It follows the conventions stated at RFC1035 section 4.1.1.
@@ -120,8 +163,8 @@ func UnmarshalHeader(msg []byte, h *Header) (n int, err error) {
 	}
 
 	if len(msg) != 12 {
-		err = errors.Errorf(
-			"msg does not have the expected size - %d",
+		err = parseErrorf(SectionHeader, 0,
+			"header must be 12 bytes long - %d",
 			len(msg))
 		return
 	}
@@ -162,58 +205,87 @@ func UnmarshalHeader(msg []byte, h *Header) (n int, err error) {
 	// As this comes from the network in UDP packets we can assume that it comes
 	// in BigEndian (network byte order), thus, consider the first byte of each
 	// the most significant.
-	h.QDCOUNT = uint16(msg[5]) | uint16((msg[4] << 8))
+	h.QDCOUNT = binary.BigEndian.Uint16(msg[4:6])
 
 	// ANCOUNT is formed by two bytes that results in uint16
-	h.ANCOUNT = uint16(msg[7]) | uint16(msg[6]<<8)
+	h.ANCOUNT = binary.BigEndian.Uint16(msg[6:8])
 
 	// NSCOUNT is formed by two bytes that results in uint16
-	h.NSCOUNT = uint16(msg[9]) | uint16(msg[8]<<8)
+	h.NSCOUNT = binary.BigEndian.Uint16(msg[8:10])
 
 	// ARCOUNT is formed by two bytes that results in uint16
-	h.ARCOUNT = uint16(msg[11]) | uint16(msg[10]<<8)
+	h.ARCOUNT = binary.BigEndian.Uint16(msg[10:12])
 
 	n = 12
 	return
 }
 
 func (h Header) Marshal() (res []byte, err error) {
+	return h.AppendTo(nil)
+}
+
+// AppendTo appends the wire format of the header to `b`.
+func (h Header) AppendTo(b []byte) (res []byte, err error) {
 	var (
-		buf       = new(bytes.Buffer)
 		h1_0 byte = 0
 		h1_1 byte = 0
 	)
 
-	binary.Write(buf, binary.BigEndian, h.ID)
-
 	// first 8bit part of the second row
 	// QR :		0
 	// Opcode:	1 2 3 4
 	// AA:		5
 	// TC:		6
 	// RD:		7
-	h1_0 = h.QR << (7 - 0)
-	h1_0 |= byte(h.Opcode) << (7 - (1 + 3))
-	h1_0 |= h.AA << (7 - 5)
-	h1_0 |= h.TC << (7 - 6)
-	h1_0 |= h.RD << (7 - 7)
+	h1_0 = (h.QR & masks[0]) << (7 - 0)
+	h1_0 |= (byte(h.Opcode) & masks[3]) << (7 - (1 + 3))
+	h1_0 |= (h.AA & masks[0]) << (7 - 5)
+	h1_0 |= (h.TC & masks[0]) << (7 - 6)
+	h1_0 |= (h.RD & masks[0]) << (7 - 7)
 
 	// second 8bit part of the second row
 	// RA:		0
 	// Z:		1 2 3
 	// RCODE:	4 5 6 7
-	h1_1 = h.RA << (7 - 0)
-	h1_1 |= h.Z << (7 - 1)
-	h1_1 |= byte(h.RCODE) << (7 - (4 + 3))
-
-	buf.WriteByte(h1_0)
-	buf.WriteByte(h1_1)
+	h1_1 = (h.RA & masks[0]) << (7 - 0)
+	h1_1 |= (h.Z & masks[2]) << (7 - 3)
+	h1_1 |= (byte(h.RCODE) & masks[3]) << (7 - (4 + 3))
+
+	res = binary.BigEndian.AppendUint16(b, h.ID)
+	res = append(res, h1_0, h1_1)
+	res = binary.BigEndian.AppendUint16(res, h.QDCOUNT)
+	res = binary.BigEndian.AppendUint16(res, h.ANCOUNT)
+	res = binary.BigEndian.AppendUint16(res, h.NSCOUNT)
+	res = binary.BigEndian.AppendUint16(res, h.ARCOUNT)
+	return
+}
 
-	binary.Write(buf, binary.BigEndian, h.QDCOUNT)
-	binary.Write(buf, binary.BigEndian, h.ANCOUNT)
-	binary.Write(buf, binary.BigEndian, h.NSCOUNT)
-	binary.Write(buf, binary.BigEndian, h.ARCOUNT)
+// String renders the header as `dig` does, e.g.:
+//
+//	;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 1
+//	;; flags: qr rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 0
+func (h Header) String() string {
+	var flags []string
+
+	for _, flag := range []struct {
+		name  string
+		value byte
+	}{
+		{"qr", h.QR},
+		{"aa", h.AA},
+		{"tc", h.TC},
+		{"rd", h.RD},
+		{"ra", h.RA},
+	} {
+		if flag.value != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
 
-	res = buf.Bytes()
-	return
+	return fmt.Sprintf(
+		";; ->>HEADER<<- opcode: %s, status: %s, id: %d\n"+
+			";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d",
+		h.Opcode, RCODE(h.RCODE), h.ID,
+		strings.Join(flags, " "),
+		h.QDCOUNT, h.ANCOUNT, h.NSCOUNT, h.ARCOUNT)
 }
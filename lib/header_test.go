@@ -52,6 +52,25 @@ func TestHeaderMarshallingAndUnmarshalling(t *testing.T) {
 				ARCOUNT: 2,
 			},
 		},
+		{
+			desc: "counts over 255",
+			entity: &Header{
+				ID:      0xabcd,
+				QR:      1,
+				QDCOUNT: 256,
+				ANCOUNT: 300,
+				NSCOUNT: 0x1ff,
+				ARCOUNT: 0xffff,
+			},
+		},
+		{
+			desc: "all of z set",
+			entity: &Header{
+				RA:    1,
+				Z:     7,
+				RCODE: 15,
+			},
+		},
 	}
 
 	var (
@@ -70,13 +89,7 @@ func TestHeaderMarshallingAndUnmarshalling(t *testing.T) {
 			_, err = UnmarshalHeader(msg, unmarshalled)
 			require.NoError(t, err)
 
-			assert.Equal(t, tc.entity.ID, unmarshalled.ID)
-			assert.Equal(t, tc.entity.QR, unmarshalled.QR)
-			assert.Equal(t, tc.entity.Opcode, unmarshalled.Opcode)
-			assert.Equal(t, tc.entity.AA, unmarshalled.AA)
-			assert.Equal(t, tc.entity.RCODE, unmarshalled.RCODE)
-			assert.Equal(t, tc.entity.QDCOUNT, unmarshalled.QDCOUNT)
-			assert.Equal(t, tc.entity.ARCOUNT, unmarshalled.ARCOUNT)
+			assert.Equal(t, *tc.entity, *unmarshalled)
 		})
 	}
 }
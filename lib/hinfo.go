@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// HINFO represents the RDATA of an HINFO record as described in
+// RFC1035 section 3.3.2: general information about a host.
+//
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                      CPU                      /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                       OS                      /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type HINFO struct {
+
+	// CPU is a <character-string> which specifies the CPU type.
+	CPU string
+
+	// OS is a <character-string> which specifies the operating
+	// system type.
+	OS string
+}
+
+func unmarshalHINFORData(msg []byte, off, length int) (data RData, err error) {
+	var h = new(HINFO)
+
+	_, err = unmarshalHINFO(msg, off, off+length, h)
+	if err != nil {
+		return
+	}
+
+	data = h
+	return
+}
+
+// UnmarshalHINFO decodes the RDATA of an HINFO record into `h`. `msg`
+// must contain exactly the RDATA of the record.
+func UnmarshalHINFO(msg []byte, h *HINFO) (n int, err error) {
+	return unmarshalHINFO(msg, 0, len(msg), h)
+}
+
+func unmarshalHINFO(msg []byte, off, end int, h *HINFO) (n int, err error) {
+	if h == nil {
+		err = errors.Errorf("hinfo must be non-nil")
+		return
+	}
+
+	var (
+		ndx   int = off
+		field []byte
+	)
+
+	field, n, err = unmarshalCharacterString(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read cpu")
+		return
+	}
+
+	h.CPU = string(field)
+	ndx += n
+
+	field, n, err = unmarshalCharacterString(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read os")
+		return
+	}
+
+	h.OS = string(field)
+	ndx += n
+
+	err = checkRDATAEnd(ndx, end)
+	if err != nil {
+		return
+	}
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the RDATA of the HINFO record.
+func (h HINFO) Marshal() (res []byte, err error) {
+	return h.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the HINFO record to `b`.
+func (h HINFO) AppendTo(b []byte) (res []byte, err error) {
+	res, err = appendCharacterString(b, h.CPU)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed cpu")
+		return
+	}
+
+	res, err = appendCharacterString(res, h.OS)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed os")
+		return
+	}
+
+	return
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	"INTEL-386" "Linux"
+func (h HINFO) String() string {
+	return quoteCharacterString([]byte(h.CPU)) + " " +
+		quoteCharacterString([]byte(h.OS))
+}
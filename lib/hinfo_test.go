@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHINFOMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		entity     *HINFO
+		presented  string
+		shouldFail bool
+	}{
+		{
+			desc: "well formed",
+			entity: &HINFO{
+				CPU: "INTEL-386",
+				OS:  "Linux",
+			},
+			presented: `"INTEL-386" "Linux"`,
+		},
+		{
+			desc: "escaped",
+			entity: &HINFO{
+				CPU: `arm "v8"`,
+				OS:  "a\tb",
+			},
+			presented: `"arm \"v8\"" "a\009b"`,
+		},
+		{
+			desc: "too long",
+			entity: &HINFO{
+				CPU: strings.Repeat("a", 256),
+			},
+			shouldFail: true,
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *HINFO
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			unmarshalled = new(HINFO)
+			_, err = UnmarshalHINFO(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// acePrefix is the prefix that marks A-labels, the ASCII (punycode)
+// form of internationalized labels (RFC5890 section 2.3.2.1).
+const acePrefix = "xn--"
+
+// ParseIDN parses a domain name like `ParseName` does but also
+// accepts internationalized names, converting each label that isn't
+// plain ASCII (a U-label, e.g. `bücher`) to its A-label (e.g.
+// `xn--bcher-kva`) following IDNA2008 with the UTS #46 mapping for
+// lookups.
+//
+// ASCII labels are taken verbatim, so names that aren't hostnames
+// (e.g., `_sip._tcp.example.com`) are still accepted.
+func ParseIDN(s string) (n Name, err error) {
+	var (
+		parsed Name
+		ascii  string
+	)
+
+	parsed, _, err = splitName(s)
+	if err != nil {
+		return
+	}
+
+	for _, label := range parsed.labels {
+		if isASCII(label) {
+			n.labels = append(n.labels, label)
+			continue
+		}
+
+		ascii, err = idna.Lookup.ToASCII(label)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"invalid internationalized label %s in name %s",
+				label, s)
+			return
+		}
+
+		// the mapping turns full stops of other scripts (e.g.,
+		// `。`) into dots, so a label may become many.
+		ascii = strings.TrimSuffix(ascii, ".")
+		n.labels = append(n.labels, strings.Split(ascii, ".")...)
+	}
+
+	err = n.validate()
+	return
+}
+
+// Unicode renders the fully qualified name like `String` does but
+// with its A-labels decoded to Unicode, meant for displaying names to
+// users (e.g., `xn--bcher-kva.example.` is rendered as
+// `bücher.example.`).
+//
+// A-labels that aren't valid IDNA2008 labels are left as they are.
+func (n Name) Unicode() string {
+	var buf = new(bytes.Buffer)
+
+	if n.IsRoot() {
+		return "."
+	}
+
+	for _, label := range n.labels {
+		if strings.HasPrefix(asciiLower(label), acePrefix) {
+			decoded, err := idna.Display.ToUnicode(label)
+			if err == nil {
+				buf.WriteString(decoded)
+				buf.WriteByte('.')
+				continue
+			}
+		}
+
+		writeEscapedLabel(buf, []byte(label))
+		buf.WriteByte('.')
+	}
+
+	return buf.String()
+}
+
+// UnicodeNames decodes the A-labels of the domain names found in
+// `text`, the presentation format of messages and records (e.g., as
+// rendered by `Message.String`), for displaying them to users.
+//
+// Names are the fully qualified words of the text (those ending with
+// a dot), while quoted character-strings are left untouched.
+func UnicodeNames(text string) string {
+	var (
+		buf    strings.Builder
+		quoted bool
+	)
+
+	for ndx := 0; ndx < len(text); ndx++ {
+		c := text[ndx]
+
+		switch {
+		case quoted:
+			if c == '\\' && ndx+1 < len(text) {
+				buf.WriteByte(c)
+				ndx++
+				c = text[ndx]
+			} else if c == '"' {
+				quoted = false
+			}
+
+			buf.WriteByte(c)
+		case c == '"':
+			quoted = true
+			buf.WriteByte(c)
+		case isSpace(c):
+			buf.WriteByte(c)
+		default:
+			end := ndx
+			for end < len(text) && !isSpace(text[end]) {
+				end++
+			}
+
+			buf.WriteString(unicodeWord(text[ndx:end]))
+			ndx = end - 1
+		}
+	}
+
+	return buf.String()
+}
+
+// unicodeWord decodes the A-labels of a word of presentation format
+// text if it's a fully qualified name, possibly commented out (as in
+// the question section).
+func unicodeWord(word string) string {
+	var name = strings.TrimLeft(word, ";")
+
+	if !strings.HasSuffix(name, ".") || !strings.Contains(asciiLower(name), acePrefix) {
+		return word
+	}
+
+	n, err := ParseName(name)
+	if err != nil {
+		return word
+	}
+
+	return word[:len(word)-len(name)] + n.Unicode()
+}
+
+// isASCII tells whether `s` is made only of US-ASCII characters.
+func isASCII(s string) bool {
+	for ndx := 0; ndx < len(s); ndx++ {
+		if s[ndx] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSpace tells whether `c` separates the words of presentation
+// format text.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDN(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		input      string
+		expected   string
+		shouldFail bool
+	}{
+		{
+			desc:     "ascii",
+			input:    "www.example.com",
+			expected: "www.example.com.",
+		},
+		{
+			desc:     "ascii case and underscores are kept",
+			input:    "_sip._TCP.Example.com.",
+			expected: "_sip._TCP.Example.com.",
+		},
+		{
+			desc:     "u-label",
+			input:    "bücher.example",
+			expected: "xn--bcher-kva.example.",
+		},
+		{
+			desc:     "mapped to lowercase",
+			input:    "BÜCHER.example",
+			expected: "xn--bcher-kva.example.",
+		},
+		{
+			desc:     "many u-labels",
+			input:    "ουτοπία.δπθ.gr.",
+			expected: "xn--kxae4bafwg.xn--pxaix.gr.",
+		},
+		{
+			desc:     "ideographic full stop",
+			input:    "例え。テスト",
+			expected: "xn--r8jz45g.xn--zckzah.",
+		},
+		{
+			desc:     "a-label is kept",
+			input:    "xn--bcher-kva.example",
+			expected: "xn--bcher-kva.example.",
+		},
+		{
+			desc:       "disallowed character",
+			input:      "a☃\u0000.example",
+			shouldFail: true,
+		},
+		{
+			desc:       "empty label",
+			input:      "bücher..example",
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			name, err := ParseIDN(tc.input)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, name.String())
+		})
+	}
+}
+
+func TestNameUnicode(t *testing.T) {
+	assert.Equal(t, "bücher.example.", MustParseName("xn--bcher-kva.example").Unicode())
+	assert.Equal(t, "bücher.example.", MustParseName("XN--BCHER-KVA.example").Unicode())
+	assert.Equal(t, "ουτοπία.δπθ.gr.", MustParseName("xn--kxae4bafwg.xn--pxaix.gr").Unicode())
+	assert.Equal(t, `xn--invalid-.a\.b.`, MustParseName(`xn--invalid-.a\.b`).Unicode())
+	assert.Equal(t, ".", RootName.Unicode())
+}
+
+func TestUnicodeNames(t *testing.T) {
+	var testCases = []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{
+			desc:     "record",
+			input:    "xn--bcher-kva.example.\t300\tIN\tCNAME\txn--kxae4bafwg.gr.\n",
+			expected: "bücher.example.\t300\tIN\tCNAME\tουτοπία.gr.\n",
+		},
+		{
+			desc:     "question",
+			input:    ";xn--bcher-kva.example.\t\tIN\tA\n",
+			expected: ";bücher.example.\t\tIN\tA\n",
+		},
+		{
+			desc:     "character-strings are left alone",
+			input:    `xn--bcher-kva.example. 300 IN TXT "xn--bcher-kva.example." "\" xn--bcher-kva."`,
+			expected: `bücher.example. 300 IN TXT "xn--bcher-kva.example." "\" xn--bcher-kva."`,
+		},
+		{
+			desc:     "other words are left alone",
+			input:    ";; flags: qr rd ra; QUERY: 1, ANSWER: 0 xn--bcher-kva",
+			expected: ";; flags: qr rd ra; QUERY: 1, ANSWER: 0 xn--bcher-kva",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, UnicodeNames(tc.input))
+		})
+	}
+}
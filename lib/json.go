@@ -0,0 +1,443 @@
+package lib
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The types in this file implement the JSON representation of DNS
+// messages described in RFC8427, e.g.:
+//
+//	{
+//	  "ID": 1, "QR": 1, "Opcode": 0, "AA": 0, "TC": 0, "RD": 1,
+//	  "RA": 1, "AD": 0, "CD": 0, "RCODE": 0,
+//	  "QDCOUNT": 1, "ANCOUNT": 1, "NSCOUNT": 0, "ARCOUNT": 0,
+//	  "QNAME": "example.com.", "QTYPE": 1, "QTYPEname": "A",
+//	  "QCLASS": 1, "QCLASSname": "IN",
+//	  "answerRRs": [{
+//	    "NAME": "example.com.", "TYPE": 1, "TYPEname": "A",
+//	    "CLASS": 1, "CLASSname": "IN", "TTL": 300, "RDLENGTH": 4,
+//	    "RDATAHEX": "5DB8D822", "rdataA": "93.184.216.34"
+//	  }]
+//	}
+//
+// Messages can also be represented by their wire format only (see
+// `Message.MarshalJSONOctets`).
+
+// jsonFlag is a 1bit header flag. RFC8427 describes flags as booleans
+// while its examples use 0 and 1, so both are accepted.
+type jsonFlag uint8
+
+func (f *jsonFlag) UnmarshalJSON(b []byte) (err error) {
+	switch string(b) {
+	case "true":
+		*f = 1
+	case "false", "null":
+		*f = 0
+	default:
+		var value uint8
+
+		err = json.Unmarshal(b, &value)
+		if err != nil || value > 1 {
+			err = errors.Errorf("malformed flag %s", b)
+			return
+		}
+
+		*f = jsonFlag(value)
+	}
+
+	return
+}
+
+type jsonHeader struct {
+	ID      uint16   `json:"ID"`
+	QR      jsonFlag `json:"QR"`
+	Opcode  uint8    `json:"Opcode"`
+	AA      jsonFlag `json:"AA"`
+	TC      jsonFlag `json:"TC"`
+	RD      jsonFlag `json:"RD"`
+	RA      jsonFlag `json:"RA"`
+	AD      jsonFlag `json:"AD"`
+	CD      jsonFlag `json:"CD"`
+	RCODE   uint8    `json:"RCODE"`
+	QDCOUNT uint16   `json:"QDCOUNT"`
+	ANCOUNT uint16   `json:"ANCOUNT"`
+	NSCOUNT uint16   `json:"NSCOUNT"`
+	ARCOUNT uint16   `json:"ARCOUNT"`
+}
+
+type jsonQuestion struct {
+	QNAME      string `json:"QNAME"`
+	QTYPE      QType  `json:"QTYPE"`
+	QTYPEname  string `json:"QTYPEname,omitempty"`
+	QCLASS     QClass `json:"QCLASS"`
+	QCLASSname string `json:"QCLASSname,omitempty"`
+}
+
+type jsonRR struct {
+	NAME      string `json:"NAME"`
+	TYPE      QType  `json:"TYPE"`
+	TYPEname  string `json:"TYPEname,omitempty"`
+	CLASS     QClass `json:"CLASS"`
+	CLASSname string `json:"CLASSname,omitempty"`
+	TTL       uint32 `json:"TTL"`
+	RDLENGTH  uint16 `json:"RDLENGTH"`
+	RDATAHEX  string `json:"RDATAHEX"`
+}
+
+// jsonMessage holds the members of a message object. A single
+// question is represented by its members directly in the message
+// while any other number of them goes in `questionRRs`.
+type jsonMessage struct {
+	jsonHeader
+	*jsonQuestion
+
+	QuestionRRs   []*Question `json:"questionRRs,omitempty"`
+	AnswerRRs     []*RR       `json:"answerRRs,omitempty"`
+	AuthorityRRs  []*RR       `json:"authorityRRs,omitempty"`
+	AdditionalRRs []*RR       `json:"additionalRRs,omitempty"`
+
+	MessageOctetsHEX string `json:"messageOctetsHEX,omitempty"`
+}
+
+func (h Header) toJSON() jsonHeader {
+	return jsonHeader{
+		ID:      h.ID,
+		QR:      jsonFlag(h.QR),
+		Opcode:  uint8(h.Opcode),
+		AA:      jsonFlag(h.AA),
+		TC:      jsonFlag(h.TC),
+		RD:      jsonFlag(h.RD),
+		RA:      jsonFlag(h.RA),
+		AD:      jsonFlag((h.Z >> 1) & 1),
+		CD:      jsonFlag(h.Z & 1),
+		RCODE:   h.RCODE,
+		QDCOUNT: h.QDCOUNT,
+		ANCOUNT: h.ANCOUNT,
+		NSCOUNT: h.NSCOUNT,
+		ARCOUNT: h.ARCOUNT,
+	}
+}
+
+func (j jsonHeader) header() Header {
+	return Header{
+		ID:      j.ID,
+		QR:      byte(j.QR),
+		Opcode:  Opcode(j.Opcode),
+		AA:      byte(j.AA),
+		TC:      byte(j.TC),
+		RD:      byte(j.RD),
+		RA:      byte(j.RA),
+		Z:       byte(j.AD)<<1 | byte(j.CD),
+		RCODE:   j.RCODE,
+		QDCOUNT: j.QDCOUNT,
+		ANCOUNT: j.ANCOUNT,
+		NSCOUNT: j.NSCOUNT,
+		ARCOUNT: j.ARCOUNT,
+	}
+}
+
+// MarshalJSON renders the header as the header members of an RFC8427
+// message object.
+func (h Header) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.toJSON())
+}
+
+func (h *Header) UnmarshalJSON(b []byte) (err error) {
+	var j jsonHeader
+
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return
+	}
+
+	*h = j.header()
+	return
+}
+
+func (q Question) toJSON() *jsonQuestion {
+	return &jsonQuestion{
+		QNAME:      fqdn(q.QNAME),
+		QTYPE:      q.QTYPE,
+		QTYPEname:  q.QTYPE.String(),
+		QCLASS:     q.QCLASS,
+		QCLASSname: q.QCLASS.String(),
+	}
+}
+
+func (j jsonQuestion) question() (q Question, err error) {
+	q.QNAME, err = parseZoneName(j.QNAME, "")
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed QNAME %s",
+			j.QNAME)
+		return
+	}
+
+	q.QTYPE, q.QCLASS = j.QTYPE, j.QCLASS
+	return
+}
+
+// MarshalJSON renders the question as an RFC8427 question object.
+func (q Question) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.toJSON())
+}
+
+func (q *Question) UnmarshalJSON(b []byte) (err error) {
+	var j jsonQuestion
+
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return
+	}
+
+	*q, err = j.question()
+	return
+}
+
+// MarshalJSON renders the record as an RFC8427 resource record
+// object. Besides the RDATA in hexadecimal (`RDATAHEX`), records of
+// known types carry it in presentation format as well (e.g., `rdataMX`).
+func (r *RR) MarshalJSON() (res []byte, err error) {
+	var rdata = r.RDATA
+
+	if r.Data != nil {
+		rdata, err = r.Data.Marshal()
+		if err != nil {
+			err = errors.Wrapf(err,
+				"failed to marshal rdata")
+			return
+		}
+	}
+
+	res, err = json.Marshal(jsonRR{
+		NAME:      fqdn(r.NAME),
+		TYPE:      r.TYPE,
+		TYPEname:  r.TYPE.String(),
+		CLASS:     r.CLASS,
+		CLASSname: r.CLASS.String(),
+		TTL:       r.TTL,
+		RDLENGTH:  uint16(len(rdata)),
+		RDATAHEX:  strings.ToUpper(hex.EncodeToString(rdata)),
+	})
+	if err != nil {
+		return
+	}
+
+	if _, unknown := r.Data.(*UnknownRDATA); r.Data == nil || unknown {
+		return
+	}
+
+	presentation, err := json.Marshal(r.Data.String())
+	if err != nil {
+		return
+	}
+
+	// append the `rdata<TYPE>` member, whose name depends on the
+	// type of the record, to the object.
+	res = append(res[:len(res)-1], `,"rdata`...)
+	res = append(res, r.TYPE.String()...)
+	res = append(res, `":`...)
+	res = append(res, presentation...)
+	res = append(res, '}')
+
+	return
+}
+
+// UnmarshalJSON reads an RFC8427 resource record object. The RDATA is
+// taken from `RDATAHEX` if present or from the `rdata<TYPE>` member in
+// presentation format otherwise.
+func (r *RR) UnmarshalJSON(b []byte) (err error) {
+	var (
+		j       jsonRR
+		members map[string]json.RawMessage
+		raw     []byte
+	)
+
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &members)
+	if err != nil {
+		return
+	}
+
+	*r = RR{
+		TYPE:  j.TYPE,
+		CLASS: j.CLASS,
+		TTL:   j.TTL,
+	}
+
+	r.NAME, err = parseZoneName(j.NAME, "")
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed NAME %s",
+			j.NAME)
+		return
+	}
+
+	if _, ok := members["RDATAHEX"]; ok {
+		raw, err = hex.DecodeString(j.RDATAHEX)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"malformed RDATAHEX")
+			return
+		}
+
+		r.Data, err = unmarshalRDATA(r.TYPE, raw, 0, len(raw))
+		if err != nil {
+			return
+		}
+	} else {
+		presentation, ok := members["rdata"+r.TYPE.String()]
+		if !ok {
+			err = errors.Errorf(
+				"record must have either RDATAHEX or rdata%s",
+				r.TYPE)
+			return
+		}
+
+		r.Data, err = parseJSONRData(r.TYPE, presentation)
+		if err != nil {
+			return
+		}
+	}
+
+	if r.Data != nil {
+		raw, err = r.Data.Marshal()
+		if err != nil {
+			return
+		}
+	}
+
+	r.RDATA, r.RDLENGTH = raw, uint16(len(raw))
+	return
+}
+
+// parseJSONRData parses the RDATA given in presentation format.
+func parseJSONRData(t QType, b json.RawMessage) (data RData, err error) {
+	var (
+		s     string
+		entry *zoneEntry
+	)
+
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+
+	entry, err = newZoneLexer(strings.NewReader(s)).next()
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed rdata%s %s",
+			t, s)
+		return
+	}
+
+	data, err = parseRDATA(t, entry.tokens, "")
+	return
+}
+
+// MarshalJSON renders the message as an RFC8427 message object with
+// all of its members spelled out.
+func (m Message) MarshalJSON() ([]byte, error) {
+	var j = jsonMessage{
+		jsonHeader:    m.Header.toJSON(),
+		AnswerRRs:     m.Answers,
+		AuthorityRRs:  m.Authorities,
+		AdditionalRRs: m.Additionals,
+	}
+
+	if len(m.Questions) == 1 {
+		j.jsonQuestion = m.Questions[0].toJSON()
+	} else {
+		j.QuestionRRs = m.Questions
+	}
+
+	return json.Marshal(j)
+}
+
+// MarshalJSONOctets renders the message as an RFC8427 message object
+// that carries only its wire format, e.g.:
+//
+//	{"messageOctetsHEX": "000101000001000000000000076578616D706C6503636F6D0000010001"}
+func (m Message) MarshalJSONOctets() (res []byte, err error) {
+	msg, err := m.Marshal()
+	if err != nil {
+		return
+	}
+
+	res, err = json.Marshal(jsonMessage{
+		MessageOctetsHEX: strings.ToUpper(hex.EncodeToString(msg)),
+	})
+	return
+}
+
+// UnmarshalJSON reads an RFC8427 message object, either from its wire
+// format (`messageOctetsHEX`) when present or from its members.
+func (m *Message) UnmarshalJSON(b []byte) (err error) {
+	var (
+		j   = jsonMessage{jsonQuestion: new(jsonQuestion)}
+		msg []byte
+	)
+
+	err = json.Unmarshal(b, &j)
+	if err != nil {
+		return
+	}
+
+	if j.MessageOctetsHEX != "" {
+		msg, err = hex.DecodeString(j.MessageOctetsHEX)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"malformed messageOctetsHEX")
+			return
+		}
+
+		*m = Message{}
+		err = UnmarshalMessage(msg, m)
+		return
+	}
+
+	*m = Message{
+		Header:      j.header(),
+		Questions:   j.QuestionRRs,
+		Answers:     j.AnswerRRs,
+		Authorities: j.AuthorityRRs,
+		Additionals: j.AdditionalRRs,
+	}
+
+	if j.QNAME != "" {
+		var q Question
+
+		q, err = j.jsonQuestion.question()
+		if err != nil {
+			return
+		}
+
+		m.Questions = append([]*Question{&q}, m.Questions...)
+	}
+
+	// counts are optional, in which case they're taken from the
+	// sections.
+	for _, count := range []struct {
+		value  *uint16
+		actual int
+	}{
+		{&m.QDCOUNT, len(m.Questions)},
+		{&m.ANCOUNT, len(m.Answers)},
+		{&m.NSCOUNT, len(m.Authorities)},
+		{&m.ARCOUNT, len(m.Additionals)},
+	} {
+		if *count.value == 0 {
+			*count.value = uint16(count.actual)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jsonTestMessage() *Message {
+	return &Message{
+		Header: Header{
+			ID:      1,
+			QR:      1,
+			RD:      1,
+			RA:      1,
+			QDCOUNT: 1,
+			ANCOUNT: 2,
+			ARCOUNT: 1,
+		},
+		Questions: []*Question{
+			{QNAME: "example.com", QTYPE: QTypeMX, QCLASS: QClassIN},
+		},
+		Answers: []*RR{
+			{NAME: "example.com", TYPE: QTypeMX, CLASS: QClassIN, TTL: 300, Data: &MX{Preference: 10, Exchange: "mail.example.com"}},
+			{NAME: "example.com", TYPE: 731, CLASS: QClassIN, TTL: 300, Data: &UnknownRDATA{Raw: []byte{0xab, 0xcd}}},
+		},
+		Additionals: []*RR{
+			{NAME: "mail.example.com", TYPE: QTypeA, CLASS: QClassIN, TTL: 60, Data: &A{Address: []byte{192, 0, 2, 1}}},
+		},
+	}
+}
+
+func TestMessageJSON(t *testing.T) {
+	var m = jsonTestMessage()
+
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"ID": 1, "QR": 1, "Opcode": 0, "AA": 0, "TC": 0, "RD": 1, "RA": 1,
+		"AD": 0, "CD": 0, "RCODE": 0,
+		"QDCOUNT": 1, "ANCOUNT": 2, "NSCOUNT": 0, "ARCOUNT": 1,
+		"QNAME": "example.com.", "QTYPE": 15, "QTYPEname": "MX",
+		"QCLASS": 1, "QCLASSname": "IN",
+		"answerRRs": [
+			{
+				"NAME": "example.com.", "TYPE": 15, "TYPEname": "MX",
+				"CLASS": 1, "CLASSname": "IN", "TTL": 300, "RDLENGTH": 20,
+				"RDATAHEX": "000A046D61696C076578616D706C6503636F6D00",
+				"rdataMX": "10 mail.example.com."
+			},
+			{
+				"NAME": "example.com.", "TYPE": 731, "TYPEname": "TYPE731",
+				"CLASS": 1, "CLASSname": "IN", "TTL": 300, "RDLENGTH": 2,
+				"RDATAHEX": "ABCD"
+			}
+		],
+		"additionalRRs": [
+			{
+				"NAME": "mail.example.com.", "TYPE": 1, "TYPEname": "A",
+				"CLASS": 1, "CLASSname": "IN", "TTL": 60, "RDLENGTH": 4,
+				"RDATAHEX": "C0000201", "rdataA": "192.0.2.1"
+			}
+		]
+	}`, string(b))
+
+	var unmarshalled Message
+	require.NoError(t, json.Unmarshal(b, &unmarshalled))
+	assert.Equal(t, m.String(), unmarshalled.String())
+
+	expected, err := m.Marshal()
+	require.NoError(t, err)
+
+	actual, err := unmarshalled.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestMessageJSON_octets(t *testing.T) {
+	var m = jsonTestMessage()
+
+	b, err := m.MarshalJSONOctets()
+	require.NoError(t, err)
+
+	msg, err := m.Marshal()
+	require.NoError(t, err)
+
+	var unmarshalled Message
+	require.NoError(t, json.Unmarshal(b, &unmarshalled))
+
+	actual, err := unmarshalled.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, msg, actual)
+}
+
+func TestMessageJSON_unmarshal(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		json       string
+		expected   string
+		shouldFail bool
+	}{
+		{
+			desc: "rdata in presentation format and boolean flags",
+			json: `{
+				"ID": 2, "QR": true, "RD": false,
+				"questionRRs": [{"QNAME": "example.com", "QTYPE": 16, "QCLASS": 1}],
+				"answerRRs": [{
+					"NAME": "example.com", "TYPE": 16, "CLASS": 1, "TTL": 10,
+					"rdataTXT": "\"a b\" c"
+				}]
+			}`,
+			expected: ";; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 2\n" +
+				";; flags: qr; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 0\n\n" +
+				";; QUESTION SECTION:\n" +
+				";example.com.\t\tIN\tTXT\n\n" +
+				";; ANSWER SECTION:\n" +
+				"example.com.\t10\tIN\tTXT\t\"a b\" \"c\"\n",
+		},
+		{
+			desc:       "missing rdata",
+			json:       `{"answerRRs": [{"NAME": "example.com", "TYPE": 1, "CLASS": 1}]}`,
+			shouldFail: true,
+		},
+		{
+			desc:       "malformed rdata",
+			json:       `{"answerRRs": [{"NAME": "example.com", "TYPE": 1, "CLASS": 1, "rdataA": "::1"}]}`,
+			shouldFail: true,
+		},
+		{
+			desc:       "malformed flag",
+			json:       `{"QR": 2}`,
+			shouldFail: true,
+		},
+		{
+			desc:       "malformed octets",
+			json:       `{"messageOctetsHEX": "zz"}`,
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var m Message
+
+			err := json.Unmarshal([]byte(tc.json), &m)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, m.String())
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// locOrigin is the value of the latitude and longitude fields
+	// at the equator and at the prime meridian.
+	locOrigin = 1 << 31
+
+	// locDegree is the number of thousandths of a second of arc
+	// in a degree.
+	locDegree = 3600000
+
+	// locAltitudeBase is the value of the altitude field (in
+	// centimeters) at the WGS 84 reference spheroid.
+	locAltitudeBase = 10000000
+
+	// locMaxPrecision is the biggest size or precision (in
+	// centimeters) that can be encoded: 9 * 10^9.
+	locMaxPrecision = 9e9
+)
+
+// LOC represents the RDATA of a LOC record as described in RFC1876
+// section 2: the geographical location of a host, network or subnet.
+//
+//	  MSB                                           LSB
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	 0|        VERSION        |         SIZE          |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	 2|       HORIZ PRE       |       VERT PRE        |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	 4|                   LATITUDE                    |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	 6|                   LATITUDE                    |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	 8|                   LONGITUDE                   |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	10|                   LONGITUDE                   |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	12|                   ALTITUDE                    |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	14|                   ALTITUDE                    |
+//	  +--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//
+// SIZE, HORIZ PRE and VERT PRE are encoded as a pair of four bit
+// unsigned integers, the most significant being the base and the
+// least significant the power of ten by which to multiply the base
+// to obtain a value in centimeters (e.g., 0x12 means 1 * 10^2cm).
+//
+// Use `NewLOC` to create a record from degrees and meters and the
+// accessors (LatitudeDegrees, SizeMeters, ...) to read them back.
+type LOC struct {
+
+	// Version of the representation. Must be zero.
+	Version uint8
+
+	// Size is the diameter of a sphere enclosing the entity.
+	Size uint8
+
+	// HorizPre is the horizontal precision of the data (the
+	// diameter of the circle of error).
+	HorizPre uint8
+
+	// VertPre is the vertical precision of the data.
+	VertPre uint8
+
+	// Latitude is expressed in thousandths of a second of arc,
+	// with 2^31 being the equator (bigger values to the north).
+	Latitude uint32
+
+	// Longitude is expressed in thousandths of a second of arc,
+	// with 2^31 being the prime meridian (bigger values to the
+	// east).
+	Longitude uint32
+
+	// Altitude is expressed in centimeters from a base of 100000m
+	// below the WGS 84 reference spheroid.
+	Altitude uint32
+}
+
+// NewLOC creates a LOC record from a latitude and a longitude in
+// degrees (negative to the south and to the west), an altitude in
+// meters and the size and precisions in meters.
+func NewLOC(latitude, longitude, altitude, size, horizPre, vertPre float64) (l *LOC, err error) {
+	if latitude < -90 || latitude > 90 {
+		err = errors.Errorf(
+			"latitude must be within [-90, 90] - %f",
+			latitude)
+		return
+	}
+
+	if longitude < -180 || longitude > 180 {
+		err = errors.Errorf(
+			"longitude must be within [-180, 180] - %f",
+			longitude)
+		return
+	}
+
+	if altitude < -100000 || altitude > 42849672.95 {
+		err = errors.Errorf(
+			"altitude must be within [-100000, 42849672.95] - %f",
+			altitude)
+		return
+	}
+
+	l = &LOC{
+		Latitude:  uint32(int64(math.Round(latitude*locDegree)) + locOrigin),
+		Longitude: uint32(int64(math.Round(longitude*locDegree)) + locOrigin),
+		Altitude:  uint32(int64(math.Round(altitude*100)) + locAltitudeBase),
+	}
+
+	for _, field := range []struct {
+		name   string
+		meters float64
+		value  *uint8
+	}{
+		{"size", size, &l.Size},
+		{"horizontal precision", horizPre, &l.HorizPre},
+		{"vertical precision", vertPre, &l.VertPre},
+	} {
+		*field.value, err = encodeLOCPrecision(field.meters)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"malformed %s",
+				field.name)
+			l = nil
+			return
+		}
+	}
+
+	return
+}
+
+func unmarshalLOCRData(msg []byte, off, length int) (data RData, err error) {
+	var l = new(LOC)
+
+	_, err = UnmarshalLOC(msg[off:off+length], l)
+	if err != nil {
+		return
+	}
+
+	data = l
+	return
+}
+
+// UnmarshalLOC decodes the RDATA of a LOC record into `l`. `msg` must
+// contain exactly the RDATA of the record.
+//
+// Only version 0 of the representation is supported.
+func UnmarshalLOC(msg []byte, l *LOC) (n int, err error) {
+	if l == nil {
+		err = errors.Errorf("loc must be non-nil")
+		return
+	}
+
+	if len(msg) != 16 {
+		err = errors.Errorf(
+			"loc rdata must be 16 bytes long - %d",
+			len(msg))
+		return
+	}
+
+	l.Version = msg[0]
+	if l.Version != 0 {
+		err = errors.Errorf(
+			"unsupported loc version %d",
+			l.Version)
+		return
+	}
+
+	l.Size = msg[1]
+	l.HorizPre = msg[2]
+	l.VertPre = msg[3]
+	l.Latitude = binary.BigEndian.Uint32(msg[4:8])
+	l.Longitude = binary.BigEndian.Uint32(msg[8:12])
+	l.Altitude = binary.BigEndian.Uint32(msg[12:16])
+
+	for _, precision := range []uint8{l.Size, l.HorizPre, l.VertPre} {
+		_, err = decodeLOCPrecision(precision)
+		if err != nil {
+			return
+		}
+	}
+
+	n = len(msg)
+	return
+}
+
+// Marshal encodes the RDATA of the LOC record.
+func (l LOC) Marshal() (res []byte, err error) {
+	var buf = new(bytes.Buffer)
+
+	if l.Version != 0 {
+		err = errors.Errorf(
+			"unsupported loc version %d",
+			l.Version)
+		return
+	}
+
+	buf.WriteByte(l.Version)
+	buf.WriteByte(l.Size)
+	buf.WriteByte(l.HorizPre)
+	buf.WriteByte(l.VertPre)
+	binary.Write(buf, binary.BigEndian, l.Latitude)
+	binary.Write(buf, binary.BigEndian, l.Longitude)
+	binary.Write(buf, binary.BigEndian, l.Altitude)
+
+	res = buf.Bytes()
+	return
+}
+
+// LatitudeDegrees returns the latitude in degrees, negative values
+// being to the south of the equator.
+func (l LOC) LatitudeDegrees() float64 {
+	return float64(int64(l.Latitude)-locOrigin) / locDegree
+}
+
+// LongitudeDegrees returns the longitude in degrees, negative values
+// being to the west of the prime meridian.
+func (l LOC) LongitudeDegrees() float64 {
+	return float64(int64(l.Longitude)-locOrigin) / locDegree
+}
+
+// AltitudeMeters returns the altitude in meters relative to the
+// WGS 84 reference spheroid.
+func (l LOC) AltitudeMeters() float64 {
+	return float64(int64(l.Altitude)-locAltitudeBase) / 100
+}
+
+// SizeMeters returns the diameter of the sphere enclosing the entity
+// in meters.
+func (l LOC) SizeMeters() float64 {
+	cm, _ := decodeLOCPrecision(l.Size)
+	return float64(cm) / 100
+}
+
+// HorizPreMeters returns the horizontal precision in meters.
+func (l LOC) HorizPreMeters() float64 {
+	cm, _ := decodeLOCPrecision(l.HorizPre)
+	return float64(cm) / 100
+}
+
+// VertPreMeters returns the vertical precision in meters.
+func (l LOC) VertPreMeters() float64 {
+	cm, _ := decodeLOCPrecision(l.VertPre)
+	return float64(cm) / 100
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	42 21 54.000 N 71 6 18.000 W -24.00m 30m 10m 10m
+func (l LOC) String() string {
+	return fmt.Sprintf("%s %s %.2fm %s %s %s",
+		locCoordinate(l.Latitude, "N", "S"),
+		locCoordinate(l.Longitude, "E", "W"),
+		l.AltitudeMeters(),
+		locPrecisionString(l.Size),
+		locPrecisionString(l.HorizPre),
+		locPrecisionString(l.VertPre))
+}
+
+// locCoordinate renders a latitude or longitude as degrees, minutes,
+// seconds (with thousandths) and hemisphere.
+func locCoordinate(value uint32, positive, negative string) string {
+	var (
+		x          = int64(value) - locOrigin
+		hemisphere = positive
+	)
+
+	if x < 0 {
+		x = -x
+		hemisphere = negative
+	}
+
+	return fmt.Sprintf("%d %d %d.%03d %s",
+		x/locDegree,
+		(x%locDegree)/60000,
+		(x%60000)/1000,
+		x%1000,
+		hemisphere)
+}
+
+// locPrecisionString renders an encoded size or precision in meters,
+// omitting the decimals when they're zero.
+func locPrecisionString(precision uint8) string {
+	cm, _ := decodeLOCPrecision(precision)
+
+	if cm%100 == 0 {
+		return fmt.Sprintf("%dm", cm/100)
+	}
+
+	return fmt.Sprintf("%d.%02dm", cm/100, cm%100)
+}
+
+// decodeLOCPrecision converts the base and exponent representation
+// of sizes and precisions to centimeters.
+func decodeLOCPrecision(precision uint8) (cm uint64, err error) {
+	var (
+		base     = uint64(precision >> 4)
+		exponent = uint64(precision & masks[3])
+	)
+
+	if base > 9 || exponent > 9 {
+		err = errors.Errorf(
+			"malformed size or precision %#02x",
+			precision)
+		return
+	}
+
+	cm = base
+	for ; exponent > 0; exponent-- {
+		cm *= 10
+	}
+
+	return
+}
+
+// encodeLOCPrecision converts a size or precision in meters to the
+// base and exponent representation, truncating it to the closest
+// representable value.
+func encodeLOCPrecision(meters float64) (precision uint8, err error) {
+	var (
+		cm       = math.Round(meters * 100)
+		exponent uint8
+	)
+
+	if cm < 0 || cm > locMaxPrecision {
+		err = errors.Errorf(
+			"must be within [0, 90000000] meters - %f",
+			meters)
+		return
+	}
+
+	for cm >= 10 {
+		cm = math.Floor(cm / 10)
+		exponent++
+	}
+
+	precision = uint8(cm)<<4 | exponent
+	return
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLOCMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc                    string
+		latitude, longitude     float64
+		altitude                float64
+		size, horizPre, vertPre float64
+		encodedSize             uint8
+		presented               string
+		shouldFail              bool
+	}{
+		{
+			desc:        "north west",
+			latitude:    42.365,
+			longitude:   -71.105,
+			altitude:    -24,
+			size:        30,
+			horizPre:    10,
+			vertPre:     10,
+			encodedSize: 0x33,
+			presented:   "42 21 54.000 N 71 6 18.000 W -24.00m 30m 10m 10m",
+		},
+		{
+			desc:        "south east with defaults",
+			latitude:    -33.8565,
+			longitude:   151.2153,
+			altitude:    0,
+			size:        1,
+			horizPre:    10000,
+			vertPre:     10,
+			encodedSize: 0x12,
+			presented:   "33 51 23.400 S 151 12 55.080 E 0.00m 1m 10000m 10m",
+		},
+		{
+			desc:        "sub-meter size",
+			latitude:    0,
+			longitude:   0,
+			altitude:    10.5,
+			size:        0.05,
+			encodedSize: 0x50,
+			presented:   "0 0 0.000 N 0 0 0.000 E 10.50m 0.05m 0m 0m",
+		},
+		{
+			desc:       "latitude out of range",
+			latitude:   91,
+			shouldFail: true,
+		},
+		{
+			desc:       "precision out of range",
+			size:       1e9,
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			entity, err := NewLOC(tc.latitude, tc.longitude, tc.altitude,
+				tc.size, tc.horizPre, tc.vertPre)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.encodedSize, entity.Size)
+
+			msg, err := entity.Marshal()
+			require.NoError(t, err)
+			assert.Equal(t, 16, len(msg))
+
+			unmarshalled := new(LOC)
+			_, err = UnmarshalLOC(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, entity, unmarshalled)
+			assert.InDelta(t, tc.latitude, unmarshalled.LatitudeDegrees(), 1e-6)
+			assert.InDelta(t, tc.longitude, unmarshalled.LongitudeDegrees(), 1e-6)
+			assert.InDelta(t, tc.altitude, unmarshalled.AltitudeMeters(), 1e-6)
+			assert.InDelta(t, tc.size, unmarshalled.SizeMeters(), 1e-6)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestUnmarshalLOCRejectsMalformedRData(t *testing.T) {
+	var testCases = []struct {
+		desc string
+		msg  []byte
+	}{
+		{
+			desc: "short",
+			msg:  []byte{0, 0x12, 0x16, 0x13},
+		},
+		{
+			desc: "unknown version",
+			msg:  []byte{1, 0x12, 0x16, 0x13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			desc: "base above nine",
+			msg:  []byte{0, 0xa2, 0x16, 0x13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := UnmarshalLOC(tc.msg, new(LOC))
+			require.Error(t, err)
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package lib
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/binary"
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
@@ -25,14 +28,57 @@ type Message struct {
 	// retrieved when receiving answers from the
 	// server queried.
 	Answers []*RR
+
+	// Authorities holds the resource records that
+	// point toward an authority (e.g., NS records
+	// of a referral or the SOA of a negative answer).
+	Authorities []*RR
+
+	// Additionals holds the resource records that
+	// relate to the query but are not strictly
+	// answers for the question (e.g., glue).
+	Additionals []*RR
 }
 
+// Marshal encodes the message. Names are never compressed and the
+// counts of the header are written as they are.
 func (m Message) Marshal() (res []byte, err error) {
+	return m.AppendTo(nil)
+}
+
+// AppendTo appends the wire format of the message to `b` (see
+// `Marshal`).
+func (m Message) AppendTo(b []byte) (res []byte, err error) {
+	return MarshalOptions{}.MarshalAppend(b, &m)
+}
+
+// MarshalOptions configures how messages are encoded.
+type MarshalOptions struct {
+
+	// MaxSize is the maximum size in bytes of the encoded message
+	// (e.g., 512 for responses over UDP without EDNS), with zero
+	// meaning no limit.
+	//
+	// Messages that don't fit are truncated at RRset boundaries:
+	// the records of whole RRsets that would go past the limit are
+	// left out along with all of the ones after them, and the
+	// counts of the header are updated accordingly. TC is set
+	// unless all of the records left out are additional ones,
+	// which are just a hint (RFC2181 section 9).
+	MaxSize int
+}
+
+// MarshalAppend appends the wire format of the message `m` to `b`
+// respecting the options.
+func (o MarshalOptions) MarshalAppend(b []byte, m *Message) (res []byte, err error) {
 	var (
-		questionPayload []byte
+		start     = len(b)
+		mark      int
+		counts    [3]uint16
+		truncated bool
 	)
 
-	res, err = m.Header.Marshal()
+	res, err = m.Header.AppendTo(b)
 	if err != nil {
 		err = errors.Wrapf(err,
 			"failed to create header payload %+v",
@@ -41,74 +87,246 @@ func (m Message) Marshal() (res []byte, err error) {
 	}
 
 	for _, question := range m.Questions {
-		questionPayload, err = question.Marshal()
+		res, err = question.AppendTo(res)
 		if err != nil {
 			err = errors.Wrapf(err,
 				"failed to marshal question %+v",
 				question)
 			return
 		}
+	}
+
+	if o.MaxSize > 0 && len(res)-start > o.MaxSize {
+		err = errors.Errorf(
+			"questions don't fit in %d bytes",
+			o.MaxSize)
+		return
+	}
+
+	for ndx, section := range m.sections() {
+		for i, rr := range section.records {
+			if i == 0 || !sameRRset(section.records[i-1], rr) {
+				mark = len(res)
+			}
+
+			res, err = rr.AppendTo(res)
+			if err != nil {
+				err = errors.Wrapf(err,
+					"failed to marshal %s record %+v",
+					section.name, rr)
+				return
+			}
+
+			if o.MaxSize > 0 && len(res)-start > o.MaxSize {
+				res = res[:mark]
+				truncated = true
+
+				// drop the records of the RRset that
+				// were already counted.
+				for i > 0 && sameRRset(section.records[i-1], rr) {
+					i--
+				}
+
+				counts[ndx] = uint16(i)
+				break
+			}
+
+			counts[ndx] = uint16(i + 1)
+		}
 
-		res = append(res, questionPayload...)
+		if truncated {
+			if section.name != "additional" {
+				res[start+2] |= 1 << 1
+			}
+
+			break
+		}
+	}
+
+	if truncated {
+		binary.BigEndian.PutUint16(res[start+6:], counts[0])
+		binary.BigEndian.PutUint16(res[start+8:], counts[1])
+		binary.BigEndian.PutUint16(res[start+10:], counts[2])
 	}
 
 	return
 }
 
+// sameRRset tells whether both records belong to the same RRset,
+// that is, they share their owner, type and class.
+func sameRRset(a, b *RR) bool {
+	return a.TYPE == b.TYPE && a.CLASS == b.CLASS &&
+		strings.EqualFold(strings.TrimSuffix(a.NAME, "."), strings.TrimSuffix(b.NAME, "."))
+}
+
+// UnmarshalMessage decodes the message `msg` into `m`, strictly (see
+// `UnmarshalOptions`).
+//
+// Malformed messages make it fail with a *ParseError.
 func UnmarshalMessage(msg []byte, m *Message) (err error) {
+	return UnmarshalOptions{}.Unmarshal(msg, m)
+}
+
+// UnmarshalOptions configures how messages are decoded.
+type UnmarshalOptions struct {
+
+	// Lenient makes decoding tolerate messages that aren't well
+	// formed but can still be made sense of: bytes after the last
+	// record are ignored and RDATA that can't be decoded into its
+	// typed representation is kept as an *UnknownRDATA (unless its
+	// type may carry compressed names, see `compressibleTypes`).
+	//
+	// Otherwise, both make decoding fail.
+	Lenient bool
+}
+
+// Unmarshal decodes the message `msg` into `m` respecting the
+// options.
+func (o UnmarshalOptions) Unmarshal(msg []byte, m *Message) (err error) {
+	if m == nil {
+		err = errors.Errorf("message must be non-nil")
+		return
+	}
+
 	var (
 		header    = &Header{}
 		questions []*Question
-		rrs       []*RR
 		ndx       int = 0
 		bytesRead int = 0
 		n         int = 0
 	)
 
+	if len(msg) < 12 {
+		err = parseErrorf(SectionHeader, 0,
+			"message must have at least 12 bytes - %d",
+			len(msg))
+		return
+	}
+
 	n, err = UnmarshalHeader(msg[0:12], header)
 	if err != nil {
-		err = errors.Wrapf(err,
-			"failed to read header")
 		return
 	}
 
 	bytesRead += n
 
-	fmt.Printf("%+v\n", header)
+	// questions take at least 5 bytes and records 11, so counts
+	// that can't possibly fit are rejected before allocating for
+	// them.
+	if int(header.QDCOUNT)*5+
+		(int(header.ANCOUNT)+int(header.NSCOUNT)+int(header.ARCOUNT))*11 > len(msg)-bytesRead {
+		err = parseErrorf(SectionHeader, 0,
+			"counts don't fit in a message of %d bytes",
+			len(msg))
+		return
+	}
 
 	questions = make([]*Question, header.QDCOUNT)
 	for ndx, _ = range questions {
 		questions[ndx] = new(Question)
 
-		n, err = UnmarshalQuestion(msg[bytesRead:], questions[ndx])
+		n, err = unmarshalQuestion(msg, bytesRead, questions[ndx])
 		if err != nil {
-			err = errors.Wrapf(err,
-				"failed to read question %d",
-				ndx)
+			err = inSection(err, SectionQuestion, ndx)
 			return
 		}
 
 		bytesRead += n
 	}
 
-	rrs = make([]*RR, header.ANCOUNT)
-	for ndx, _ = range rrs {
-		rrs[ndx] = new(RR)
+	m.Header = *header
+	m.Questions = questions
 
-		n, err = UnmarshalRR(msg[bytesRead:], rrs[ndx])
-		if err != nil {
-			err = errors.Wrapf(err,
-				"failed to read answer %d",
-				ndx)
-			return
+	for _, section := range []struct {
+		section Section
+		count   uint16
+		records *[]*RR
+	}{
+		{SectionAnswer, header.ANCOUNT, &m.Answers},
+		{SectionAuthority, header.NSCOUNT, &m.Authorities},
+		{SectionAdditional, header.ARCOUNT, &m.Additionals},
+	} {
+		rrs := make([]*RR, section.count)
+		for ndx, _ = range rrs {
+			rrs[ndx] = new(RR)
+
+			n, err = unmarshalRR(msg, bytesRead, rrs[ndx], o.Lenient)
+			if err != nil {
+				err = inSection(err, section.section, ndx)
+				return
+			}
+
+			bytesRead += n
 		}
 
-		bytesRead += n
+		*section.records = rrs
 	}
 
-	m.Header = *header
-	m.Questions = questions
-	m.Answers = rrs
+	if bytesRead != len(msg) && !o.Lenient {
+		err = parseErrorf(0, bytesRead,
+			"%d trailing bytes after the last record",
+			len(msg)-bytesRead)
+		return
+	}
 
 	return
 }
+
+// messageSection names the records of one of the sections that
+// carry resource records.
+type messageSection struct {
+	name    string
+	records []*RR
+}
+
+// sections lists the answer, authority and additional sections in
+// the order in which they appear in the message.
+func (m Message) sections() []messageSection {
+	return []messageSection{
+		{"answer", m.Answers},
+		{"authority", m.Authorities},
+		{"additional", m.Additionals},
+	}
+}
+
+// String renders the message in the layout used by `dig`: the
+// header followed by each of the sections, e.g.:
+//
+//	;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 1
+//	;; flags: qr rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 0
+//
+//	;; QUESTION SECTION:
+//	;example.com.			IN	A
+//
+//	;; ANSWER SECTION:
+//	example.com.	300	IN	A	93.184.216.34
+//
+// Empty sections other than the question one are omitted.
+func (m Message) String() string {
+	var buf = new(bytes.Buffer)
+
+	buf.WriteString(m.Header.String())
+	buf.WriteString("\n\n;; QUESTION SECTION:\n")
+
+	for _, question := range m.Questions {
+		buf.WriteString(question.String())
+		buf.WriteByte('\n')
+	}
+
+	for _, section := range m.sections() {
+		if len(section.records) == 0 {
+			continue
+		}
+
+		buf.WriteString("\n;; ")
+		buf.WriteString(strings.ToUpper(section.name))
+		buf.WriteString(" SECTION:\n")
+
+		for _, rr := range section.records {
+			buf.WriteString(rr.String())
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.String()
+}
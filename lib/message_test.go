@@ -0,0 +1,303 @@
+package lib
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageMarshallingAndUnmarshalling(t *testing.T) {
+	var message = &Message{
+		Header: Header{
+			ID:      42,
+			QR:      1,
+			RD:      1,
+			RA:      1,
+			RCODE:   byte(RCODENoError),
+			QDCOUNT: 1,
+			ANCOUNT: 1,
+			NSCOUNT: 1,
+			ARCOUNT: 1,
+		},
+		Questions: []*Question{
+			{QNAME: "example.com", QTYPE: QTypeMX, QCLASS: QClassIN},
+		},
+		Answers: []*RR{
+			{
+				NAME: "example.com", TYPE: QTypeMX, CLASS: QClassIN, TTL: 300,
+				Data: &MX{Preference: 10, Exchange: "mail.example.com"},
+			},
+		},
+		Authorities: []*RR{
+			{
+				NAME: "example.com", TYPE: QTypeNS, CLASS: QClassIN, TTL: 3600,
+				Data: &NS{Host: "ns.example.com"},
+			},
+		},
+		Additionals: []*RR{
+			{
+				NAME: "mail.example.com", TYPE: QTypeA, CLASS: QClassIN, TTL: 60,
+				Data: &A{Address: net.ParseIP("192.0.2.1").To4()},
+			},
+		},
+	}
+
+	msg, err := message.Marshal()
+	require.NoError(t, err)
+
+	unmarshalled := new(Message)
+	err = UnmarshalMessage(msg, unmarshalled)
+	require.NoError(t, err)
+
+	assert.Equal(t, message.Header, unmarshalled.Header)
+	assert.Equal(t, message.Questions, unmarshalled.Questions)
+
+	for ndx, section := range message.sections() {
+		records := unmarshalled.sections()[ndx].records
+		require.Equal(t, len(section.records), len(records))
+
+		for i, rr := range section.records {
+			assert.Equal(t, rr.String(), records[i].String())
+		}
+	}
+
+	assert.Equal(t, `;; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 42
+;; flags: qr rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 1, ADDITIONAL: 1
+
+;; QUESTION SECTION:
+;example.com.		IN	MX
+
+;; ANSWER SECTION:
+example.com.	300	IN	MX	10 mail.example.com.
+
+;; AUTHORITY SECTION:
+example.com.	3600	IN	NS	ns.example.com.
+
+;; ADDITIONAL SECTION:
+mail.example.com.	60	IN	A	192.0.2.1
+`, unmarshalled.String())
+}
+
+func TestMessageAppendTo(t *testing.T) {
+	var message = truncationMessage()
+
+	msg, err := message.Marshal()
+	require.NoError(t, err)
+
+	res, err := message.AppendTo([]byte{0xca, 0xfe})
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0xca, 0xfe}, msg...), res)
+}
+
+// truncationMessage is a response whose answer section has two
+// RRsets of two records each, followed by a single authority record
+// and a single additional one, all of them taking 31 bytes.
+func truncationMessage() *Message {
+	var record = func(name string, last byte) *RR {
+		return &RR{
+			NAME: name, TYPE: QTypeA, CLASS: QClassIN, TTL: 300,
+			Data: &A{Address: net.IPv4(192, 0, 2, last).To4()},
+		}
+	}
+
+	return &Message{
+		Header: Header{
+			ID: 1, QR: 1, RD: 1, RA: 1,
+			QDCOUNT: 1, ANCOUNT: 4, NSCOUNT: 1, ARCOUNT: 1,
+		},
+		Questions: []*Question{
+			{QNAME: "www.example.com", QTYPE: QTypeA, QCLASS: QClassIN},
+		},
+		Answers: []*RR{
+			record("www.example.com", 1),
+			record("WWW.example.com.", 2),
+			record("web.example.com", 3),
+			record("web.example.com", 4),
+		},
+		Authorities: []*RR{
+			record("aaa.example.com", 5),
+		},
+		Additionals: []*RR{
+			record("bbb.example.com", 6),
+		},
+	}
+}
+
+func TestMarshalOptions_MaxSize(t *testing.T) {
+	// header (12) + question (21) + 31 bytes per record
+	const base = 12 + 21
+
+	var testCases = []struct {
+		desc       string
+		maxSize    int
+		tc         byte
+		counts     [3]uint16
+		shouldFail bool
+	}{
+		{
+			desc:    "no limit",
+			maxSize: 0,
+			counts:  [3]uint16{4, 1, 1},
+		},
+		{
+			desc:    "fits exactly",
+			maxSize: base + 6*31,
+			counts:  [3]uint16{4, 1, 1},
+		},
+		{
+			desc:    "additional records are dropped without tc",
+			maxSize: base + 6*31 - 1,
+			counts:  [3]uint16{4, 1, 0},
+		},
+		{
+			desc:    "authority records are dropped with tc",
+			maxSize: base + 5*31 - 1,
+			tc:      1,
+			counts:  [3]uint16{4, 0, 0},
+		},
+		{
+			desc:    "rrsets aren't split",
+			maxSize: base + 3*31,
+			tc:      1,
+			counts:  [3]uint16{2, 0, 0},
+		},
+		{
+			desc:    "rrsets that are the first to be cut",
+			maxSize: base + 31,
+			tc:      1,
+			counts:  [3]uint16{0, 0, 0},
+		},
+		{
+			desc:       "questions must fit",
+			maxSize:    base - 1,
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err := MarshalOptions{MaxSize: tc.maxSize}.MarshalAppend(nil, truncationMessage())
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			if tc.maxSize > 0 {
+				assert.True(t, len(msg) <= tc.maxSize)
+			}
+
+			unmarshalled := new(Message)
+			require.NoError(t, UnmarshalMessage(msg, unmarshalled))
+
+			assert.Equal(t, tc.tc, unmarshalled.TC)
+			assert.Equal(t, tc.counts, [3]uint16{
+				unmarshalled.ANCOUNT,
+				unmarshalled.NSCOUNT,
+				unmarshalled.ARCOUNT,
+			})
+			assert.Len(t, unmarshalled.Answers, int(tc.counts[0]))
+			assert.Len(t, unmarshalled.Authorities, int(tc.counts[1]))
+			assert.Len(t, unmarshalled.Additionals, int(tc.counts[2]))
+		})
+	}
+}
+
+func TestMessageAppendTo_doesNotAllocate(t *testing.T) {
+	var (
+		message = truncationMessage()
+		buf     = make([]byte, 0, 512)
+	)
+
+	message.Answers = append(message.Answers,
+		&RR{
+			NAME: "example.com", TYPE: QTypeMX, CLASS: QClassIN, TTL: 300,
+			Data: &MX{Preference: 10, Exchange: "mail.example.com"},
+		},
+		&RR{
+			NAME: "example.com", TYPE: QTypeTXT, CLASS: QClassIN, TTL: 300,
+			Data: &TXT{Strings: []string{"v=spf1 -all"}},
+		})
+	message.ANCOUNT += 2
+
+	allocs := testing.AllocsPerRun(100, func() {
+		var err error
+
+		buf, err = message.AppendTo(buf[:0])
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkMessageMarshal(b *testing.B) {
+	var message = truncationMessage()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for ndx := 0; ndx < b.N; ndx++ {
+		_, err := message.Marshal()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessageAppendTo(b *testing.B) {
+	var (
+		message = truncationMessage()
+		buf     = make([]byte, 0, 512)
+		err     error
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for ndx := 0; ndx < b.N; ndx++ {
+		buf, err = message.AppendTo(buf[:0])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRRStringWithoutTypedRData(t *testing.T) {
+	var rr = &RR{
+		NAME:  "example.com",
+		TYPE:  QTypeNULL,
+		CLASS: QClassCH,
+		TTL:   10,
+		RDATA: []byte{0xca, 0xfe},
+	}
+
+	assert.Equal(t, "example.com.\t10\tCH\tNULL\t\\# 2 CAFE", rr.String())
+
+	rr.TYPE = 65280
+	assert.Equal(t, "example.com.\t10\tCH\tTYPE65280\t\\# 2 CAFE", rr.String())
+}
+
+func TestParseQTypeAndQClass(t *testing.T) {
+	qtype, err := ParseQType("aaaa")
+	require.NoError(t, err)
+	assert.Equal(t, QTypeAAAA, qtype)
+
+	qtype, err = ParseQType("TYPE65")
+	require.NoError(t, err)
+	assert.Equal(t, QTypeHTTPS, qtype)
+
+	_, err = ParseQType("BOGUS")
+	require.Error(t, err)
+
+	_, err = ParseQType("TYPE65536")
+	require.Error(t, err)
+
+	qclass, err := ParseQClass("CLASS3")
+	require.NoError(t, err)
+	assert.Equal(t, QClassCH, qclass)
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// MINFO represents the RDATA of a MINFO record as described in
+// RFC1035 section 3.3.7: mailbox or mail list information.
+//
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                    RMAILBX                    /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                    EMAILBX                    /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type MINFO struct {
+
+	// RMailbx is the mailbox responsible for the mailing list
+	// or mailbox.
+	RMailbx string
+
+	// EMailbx is the mailbox that receives the error messages
+	// related to the mailing list or mailbox.
+	EMailbx string
+}
+
+func unmarshalMINFORData(msg []byte, off, length int) (data RData, err error) {
+	var m = new(MINFO)
+
+	_, err = unmarshalMINFO(msg, off, off+length, m)
+	if err != nil {
+		return
+	}
+
+	data = m
+	return
+}
+
+// UnmarshalMINFO decodes the RDATA of a MINFO record into `m`. `msg`
+// must contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalMINFO(msg []byte, m *MINFO) (n int, err error) {
+	return unmarshalMINFO(msg, 0, len(msg), m)
+}
+
+func unmarshalMINFO(msg []byte, off, end int, m *MINFO) (n int, err error) {
+	if m == nil {
+		err = errors.Errorf("minfo must be non-nil")
+		return
+	}
+
+	n, err = unmarshalNamePair(msg, off, end,
+		&m.RMailbx, &m.EMailbx, "rmailbx", "emailbx")
+	return
+}
+
+// Marshal encodes the RDATA of the MINFO record.
+func (m MINFO) Marshal() (res []byte, err error) {
+	return m.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the MINFO record to `b`.
+func (m MINFO) AppendTo(b []byte) (res []byte, err error) {
+	res, err = appendRDATAName(b, m.RMailbx)
+	if err != nil {
+		return
+	}
+
+	return appendRDATAName(res, m.EMailbx)
+}
+
+// String renders the RDATA in presentation format.
+func (m MINFO) String() string {
+	return fqdn(m.RMailbx) + " " + fqdn(m.EMailbx)
+}
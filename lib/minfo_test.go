@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMINFOMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		entity    *MINFO
+		presented string
+	}{
+		{
+			desc: "well formed",
+			entity: &MINFO{
+				RMailbx: "list-request.example.com",
+				EMailbx: "owner-list.example.com",
+			},
+			presented: "list-request.example.com. owner-list.example.com.",
+		},
+		{
+			desc: "errors to the root",
+			entity: &MINFO{
+				RMailbx: "admin.example.com",
+				EMailbx: ".",
+			},
+			presented: "admin.example.com. .",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *MINFO
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			require.NoError(t, err)
+
+			unmarshalled = new(MINFO)
+			_, err = UnmarshalMINFO(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestMINFOWithCompressedNames(t *testing.T) {
+	// example.com at offset 0 followed by a MINFO record whose
+	// names point back at it.
+	var msg = []byte{
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0xc0, 0, 0, 14, 0, 1, 0, 0, 0, 60, 0, 15,
+		4, 'l', 'i', 's', 't', 0xc0, 0,
+		5, 'o', 'w', 'n', 'e', 'r', 0xc0, 0,
+	}
+
+	var rr = new(RR)
+
+	n, err := unmarshalRR(msg, 13, rr, false)
+	require.NoError(t, err)
+	assert.Equal(t, len(msg)-13, n)
+
+	minfo, ok := rr.Data.(*MINFO)
+	require.True(t, ok)
+	assert.Equal(t, "list.example.com", minfo.RMailbx)
+	assert.Equal(t, "owner.example.com", minfo.EMailbx)
+}
+
+func TestUnmarshalMINFORejectsMalformedRData(t *testing.T) {
+	var testCases = []struct {
+		desc string
+		msg  []byte
+	}{
+		{
+			desc: "empty",
+			msg:  []byte{},
+		},
+		{
+			desc: "missing emailbx",
+			msg:  []byte{4, 'l', 'i', 's', 't', 0},
+		},
+		{
+			desc: "truncated emailbx",
+			msg:  []byte{4, 'l', 'i', 's', 't', 0, 5, 'o', 'w'},
+		},
+		{
+			desc: "trailing bytes",
+			msg:  []byte{4, 'l', 'i', 's', 't', 0, 0, 0xff},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := UnmarshalMINFO(tc.msg, new(MINFO))
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Middleware wraps a Handler, e.g., to log the requests it gets or to
+// act on the responses it writes.
+type Middleware func(next Handler) Handler
+
+// ServeMux is a Handler that routes requests to the handler of the
+// zone that's the longest suffix of the name asked, compared
+// case-insensitively (RFC4343), e.g., with handlers for `example.com`
+// and `sub.example.com`, `www.sub.example.com` goes to the second one.
+//
+// A zone may have handlers for specific types of records, which take
+// precedence over its handler for any type. Requests for names in no
+// zone go to `Default`, while requests that aren't standard queries of
+// a single question are answered with NOTIMP or FORMERR.
+type ServeMux struct {
+
+	// Default handles the requests for names that are in none of
+	// the zones, answering them with REFUSED if nil.
+	Default Handler
+
+	mu         sync.RWMutex
+	zones      map[string]*muxZone
+	middleware []Middleware
+}
+
+// muxZone holds the handlers registered for a zone.
+type muxZone struct {
+	handler Handler
+	types   map[QType]Handler
+}
+
+// NewServeMux creates a ServeMux without any zones.
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		zones: make(map[string]*muxZone),
+	}
+}
+
+// Handle registers the handler `h` for the requests for names in the
+// zone `zone` (`.` standing for every name).
+func (m *ServeMux) Handle(zone string, h Handler) (err error) {
+	return m.register(zone, 0, h)
+}
+
+// HandleFunc registers the function `f` as the handler for the zone
+// `zone` (see `Handle`).
+func (m *ServeMux) HandleFunc(zone string, f func(w ResponseWriter, r *Message)) (err error) {
+	return m.register(zone, 0, HandlerFunc(f))
+}
+
+// HandleType registers the handler `h` for the requests for records of
+// type `qtype` of names in the zone `zone`.
+func (m *ServeMux) HandleType(zone string, qtype QType, h Handler) (err error) {
+	if qtype == 0 {
+		err = errors.Errorf("qtype must be specified")
+		return
+	}
+
+	return m.register(zone, qtype, h)
+}
+
+// Use appends middleware to the chain that wraps every request routed
+// by the mux, with the first one being the outermost.
+func (m *ServeMux) Use(middleware ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.middleware = append(m.middleware, middleware...)
+}
+
+func (m *ServeMux) register(zone string, qtype QType, h Handler) (err error) {
+	if h == nil {
+		err = errors.Errorf("handler must be non-nil")
+		return
+	}
+
+	name, err := ParseName(zone)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"invalid zone %s",
+			zone)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.zones == nil {
+		m.zones = make(map[string]*muxZone)
+	}
+
+	key := name.Canonical().text()
+
+	z, ok := m.zones[key]
+	if !ok {
+		z = &muxZone{types: make(map[QType]Handler)}
+		m.zones[key] = z
+	}
+
+	if qtype == 0 {
+		z.handler = h
+	} else {
+		z.types[qtype] = h
+	}
+
+	return
+}
+
+// Handler returns the handler that the request `r` is routed to,
+// along with the zone it was matched against (empty if none).
+func (m *ServeMux) Handler(r *Message) (h Handler, zone string) {
+	if r.Opcode != OpcodeQuery {
+		return ErrorHandler(RCODENotImplemented), ""
+	}
+
+	if len(r.Questions) != 1 {
+		return ErrorHandler(RCODEFormatError), ""
+	}
+
+	var question = r.Questions[0]
+
+	name, err := ParseName(question.QNAME)
+	if err != nil {
+		return ErrorHandler(RCODEFormatError), ""
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for name = name.Canonical(); ; name = name.Parent() {
+		if z, ok := m.zones[name.text()]; ok {
+			if h, ok := z.types[question.QTYPE]; ok {
+				return h, name.text()
+			}
+
+			if z.handler != nil {
+				return z.handler, name.text()
+			}
+		}
+
+		if name.IsRoot() {
+			break
+		}
+	}
+
+	if m.Default != nil {
+		return m.Default, ""
+	}
+
+	return ErrorHandler(RCODERefused), ""
+}
+
+// ServeDNS routes the request `r` through the middleware to the
+// handler picked by `Handler`.
+func (m *ServeMux) ServeDNS(w ResponseWriter, r *Message) {
+	var h, _ = m.Handler(r)
+
+	m.mu.RLock()
+	for ndx := len(m.middleware) - 1; ndx >= 0; ndx-- {
+		h = m.middleware[ndx](h)
+	}
+	m.mu.RUnlock()
+
+	h.ServeDNS(w, r)
+}
+
+// ErrorHandler returns a handler that answers every request with the
+// response code `rcode`.
+func ErrorHandler(rcode RCODE) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		reply := NewReply(r)
+		reply.RCODE = byte(rcode)
+
+		w.WriteMsg(reply)
+	})
+}
+
+// HandleMessage hands the request `req` to `h` in memory, without going
+// through a transport, returning the last response written (nil if
+// none). Handlers see it as a request that came over UDP from the
+// loopback, but their responses aren't truncated.
+func HandleMessage(ctx context.Context, h Handler, req *Message) (res *Message) {
+	var w = &messageWriter{ctx: ctx}
+
+	h.ServeDNS(w, req)
+	return w.res
+}
+
+// messageWriter is the ResponseWriter of `HandleMessage`, keeping the
+// responses written instead of sending them.
+type messageWriter struct {
+	ctx context.Context
+	res *Message
+}
+
+var loopback = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+
+func (w *messageWriter) Context() context.Context { return w.ctx }
+func (w *messageWriter) Network() string          { return "udp" }
+func (w *messageWriter) LocalAddr() net.Addr      { return loopback }
+func (w *messageWriter) RemoteAddr() net.Addr     { return loopback }
+
+func (w *messageWriter) WriteMsg(m *Message) (err error) {
+	var reply = withCounts(m)
+
+	w.res = &reply
+	return
+}
@@ -0,0 +1,209 @@
+package lib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedHandler answers with its name in the TXT record of the answer.
+func namedHandler(name string) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		reply := NewReply(r)
+		reply.Answers = []*RR{
+			{
+				NAME: r.Questions[0].QNAME, TYPE: QTypeTXT, CLASS: QClassIN,
+				Data: &TXT{Strings: []string{name}},
+			},
+		}
+
+		w.WriteMsg(reply)
+	})
+}
+
+func TestServeMux(t *testing.T) {
+	var mux = NewServeMux()
+
+	require.NoError(t, mux.Handle("example.com", namedHandler("example")))
+	require.NoError(t, mux.Handle("Sub.Example.com.", namedHandler("sub")))
+	require.NoError(t, mux.HandleType("example.com", QTypeMX, namedHandler("example-mx")))
+	require.NoError(t, mux.HandleType("org", QTypeA, namedHandler("org-a")))
+	require.NoError(t, mux.Handle("arpa", ErrorHandler(RCODENameError)))
+
+	var testCases = []struct {
+		desc    string
+		qname   string
+		qtype   QType
+		zone    string
+		handler string
+		rcode   RCODE
+	}{
+		{
+			desc:    "apex of a zone",
+			qname:   "example.com",
+			qtype:   QTypeA,
+			zone:    "example.com",
+			handler: "example",
+		},
+		{
+			desc:    "name below a zone",
+			qname:   "www.example.com",
+			qtype:   QTypeA,
+			zone:    "example.com",
+			handler: "example",
+		},
+		{
+			desc:    "longest zone",
+			qname:   "www.sub.example.com.",
+			qtype:   QTypeA,
+			zone:    "sub.example.com",
+			handler: "sub",
+		},
+		{
+			desc:    "different case",
+			qname:   "WWW.SUB.EXAMPLE.COM",
+			qtype:   QTypeA,
+			zone:    "sub.example.com",
+			handler: "sub",
+		},
+		{
+			desc:    "type of a zone",
+			qname:   "example.com",
+			qtype:   QTypeMX,
+			zone:    "example.com",
+			handler: "example-mx",
+		},
+		{
+			desc:    "type registered for a shorter zone",
+			qname:   "sub.example.com",
+			qtype:   QTypeMX,
+			zone:    "sub.example.com",
+			handler: "sub",
+		},
+		{
+			desc:    "zone with only types",
+			qname:   "example.org",
+			qtype:   QTypeA,
+			zone:    "org",
+			handler: "org-a",
+		},
+		{
+			desc:  "type not handled",
+			qname: "example.org",
+			qtype: QTypeMX,
+			rcode: RCODERefused,
+		},
+		{
+			desc:  "suffix that isn't a zone",
+			qname: "ample.com",
+			qtype: QTypeA,
+			rcode: RCODERefused,
+		},
+		{
+			desc:  "zone with an error handler",
+			qname: "1.2.0.192.in-addr.arpa",
+			qtype: QTypePTR,
+			zone:  "arpa",
+			rcode: RCODENameError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var req = &Message{
+				Header:    Header{ID: 9, RD: 1, QDCOUNT: 1},
+				Questions: []*Question{{QNAME: tc.qname, QTYPE: tc.qtype, QCLASS: QClassIN}},
+			}
+
+			_, zone := mux.Handler(req)
+			assert.Equal(t, tc.zone, zone)
+
+			res := HandleMessage(context.Background(), mux, req)
+			require.NotNil(t, res)
+			assert.Equal(t, uint16(9), res.ID)
+			assert.Equal(t, byte(1), res.QR)
+			assert.Equal(t, byte(tc.rcode), res.RCODE)
+
+			if tc.handler == "" {
+				assert.Empty(t, res.Answers)
+				return
+			}
+
+			require.Len(t, res.Answers, 1)
+			assert.Equal(t, []string{tc.handler}, res.Answers[0].Data.(*TXT).Strings)
+		})
+	}
+}
+
+func TestServeMux_root(t *testing.T) {
+	var mux = NewServeMux()
+
+	require.NoError(t, mux.Handle(".", namedHandler("root")))
+
+	_, zone := mux.Handler(testQuery(1, "anything.example"))
+	assert.Equal(t, ".", zone)
+
+	_, zone = mux.Handler(testQuery(1, "."))
+	assert.Equal(t, ".", zone)
+}
+
+func TestServeMux_malformedRequests(t *testing.T) {
+	var (
+		mux    = NewServeMux()
+		notify = testQuery(1, "example.com")
+		empty  = &Message{Header: Header{ID: 1}}
+	)
+
+	require.NoError(t, mux.Handle(".", namedHandler("root")))
+
+	notify.Opcode = OpcodeNotify
+
+	assert.Equal(t, byte(RCODENotImplemented), HandleMessage(context.Background(), mux, notify).RCODE)
+	assert.Equal(t, byte(RCODEFormatError), HandleMessage(context.Background(), mux, empty).RCODE)
+}
+
+func TestServeMux_Default(t *testing.T) {
+	var mux = &ServeMux{Default: namedHandler("default")}
+
+	res := HandleMessage(context.Background(), mux, testQuery(1, "example.com"))
+	require.Len(t, res.Answers, 1)
+	assert.Equal(t, []string{"default"}, res.Answers[0].Data.(*TXT).Strings)
+}
+
+func TestServeMux_Use(t *testing.T) {
+	var (
+		mux   = NewServeMux()
+		order []string
+	)
+
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(w ResponseWriter, r *Message) {
+				order = append(order, name)
+				next.ServeDNS(w, r)
+			})
+		}
+	}
+
+	require.NoError(t, mux.Handle("example.com", namedHandler("example")))
+	mux.Use(tag("first"), tag("second"))
+	mux.Use(tag("third"))
+
+	HandleMessage(context.Background(), mux, testQuery(1, "example.com"))
+	assert.Equal(t, []string{"first", "second", "third"}, order)
+
+	// requests that aren't routed to a zone go through it too.
+	order = nil
+	HandleMessage(context.Background(), mux, testQuery(1, "example.org"))
+	assert.Equal(t, []string{"first", "second", "third"}, order)
+}
+
+func TestServeMux_invalidRegistrations(t *testing.T) {
+	var mux = NewServeMux()
+
+	assert.Error(t, mux.Handle("a..b", namedHandler("x")))
+	assert.Error(t, mux.Handle("example.com", nil))
+	assert.Error(t, mux.HandleType("example.com", 0, namedHandler("x")))
+}
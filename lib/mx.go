@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// MX represents the RDATA of an MX record as described in RFC1035
+// section 3.3.9.
+//
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                  PREFERENCE                   |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                   EXCHANGE                    /
+//	/                                               /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type MX struct {
+
+	// Preference given to this record among others at the same
+	// owner, lower values being preferred.
+	Preference uint16
+
+	// Exchange is the domain name of a host willing to act as a
+	// mail exchange for the owner name.
+	Exchange string
+}
+
+func unmarshalMXRData(msg []byte, off, length int) (data RData, err error) {
+	var m = new(MX)
+
+	_, err = unmarshalMX(msg, off, off+length, m)
+	if err != nil {
+		return
+	}
+
+	data = m
+	return
+}
+
+// UnmarshalMX decodes the RDATA of an MX record into `m`. `msg` must
+// contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalMX(msg []byte, m *MX) (n int, err error) {
+	return unmarshalMX(msg, 0, len(msg), m)
+}
+
+func unmarshalMX(msg []byte, off, end int, m *MX) (n int, err error) {
+	if m == nil {
+		err = errors.Errorf("mx must be non-nil")
+		return
+	}
+
+	var ndx int = off
+
+	if end-ndx < 2 {
+		err = errors.Errorf(
+			"mx rdata must be at least 2 bytes long - %d",
+			end-ndx)
+		return
+	}
+
+	m.Preference = binary.BigEndian.Uint16(msg[ndx : ndx+2])
+	ndx += 2
+
+	m.Exchange, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read exchange")
+		return
+	}
+
+	ndx += n
+
+	err = checkRDATAEnd(ndx, end)
+	if err != nil {
+		return
+	}
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the RDATA of the MX record.
+func (m MX) Marshal() (res []byte, err error) {
+	return m.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the MX record to `b`.
+func (m MX) AppendTo(b []byte) (res []byte, err error) {
+	res = binary.BigEndian.AppendUint16(b, m.Preference)
+	return appendRDATAName(res, m.Exchange)
+}
+
+// String renders the RDATA in presentation format.
+func (m MX) String() string {
+	return strconv.Itoa(int(m.Preference)) + " " + fqdn(m.Exchange)
+}
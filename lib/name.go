@@ -0,0 +1,365 @@
+package lib
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Name is a domain name, stored as its sequence of labels (from the
+// leftmost one to the one right before the root) so that labels may
+// carry any octet, dots included.
+//
+// The zero value is the root name.
+//
+// The structs of the library (e.g., `Question`, `RR`) keep names as
+// strings in presentation format - escaped as in `Name.String` - with
+// the trailing dot being optional.
+type Name struct {
+	labels []string
+}
+
+// RootName is the root of the domain name space (`.`).
+var RootName = Name{}
+
+// NewName creates a name from its labels, which are taken verbatim
+// (no escape sequences are interpreted).
+func NewName(labels ...string) (n Name, err error) {
+	n = Name{labels: append([]string(nil), labels...)}
+
+	err = n.validate()
+	return
+}
+
+// ParseName parses a domain name in presentation format (RFC1035
+// section 5.1), where `\X` stands for the character X (e.g., `\.` for
+// a dot that is part of a label) and `\DDD` for the octet whose
+// decimal value is DDD.
+//
+// The trailing dot is optional: names are always taken as fully
+// qualified. Both `.` and the empty string denote the root.
+func ParseName(s string) (n Name, err error) {
+	n, _, err = parseName(s)
+	return
+}
+
+// MustParseName is like ParseName but panics if `s` is not a valid
+// name. It's meant for names known at compile time.
+func MustParseName(s string) Name {
+	n, err := ParseName(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return n
+}
+
+// parseName parses a name in presentation format, also telling whether
+// it was written as absolute (with a trailing dot).
+func parseName(s string) (n Name, absolute bool, err error) {
+	n, absolute, err = splitName(s)
+	if err != nil {
+		return
+	}
+
+	err = n.validate()
+	return
+}
+
+// splitName breaks a name in presentation format into its labels,
+// interpreting escape sequences but leaving the limits on the lengths
+// of labels and names unchecked.
+func splitName(s string) (n Name, absolute bool, err error) {
+	var (
+		label []byte
+		c     byte
+		size  int
+	)
+
+	if s == "" || s == "." {
+		absolute = s == "."
+		return
+	}
+
+	for ndx := 0; ndx < len(s); ndx++ {
+		switch s[ndx] {
+		case '.':
+			if len(label) == 0 {
+				err = errors.Errorf(
+					"can't have empty label in name %s",
+					s)
+				return
+			}
+
+			n.labels = append(n.labels, string(label))
+			label = nil
+
+			if ndx == len(s)-1 {
+				absolute = true
+			}
+		case '\\':
+			c, size, err = unescapeByte(s[ndx:])
+			if err != nil {
+				err = errors.Wrapf(err,
+					"malformed name %s",
+					s)
+				return
+			}
+
+			label = append(label, c)
+			ndx += size - 1
+		default:
+			label = append(label, s[ndx])
+		}
+	}
+
+	if !absolute {
+		n.labels = append(n.labels, string(label))
+	}
+
+	return
+}
+
+// validate makes sure that the labels and the name respect the limits
+// of RFC1035 section 2.3.4.
+func (n Name) validate() (err error) {
+	var length = 1
+
+	for _, label := range n.labels {
+		if len(label) == 0 {
+			err = errors.Errorf("can't have empty label")
+			return
+		}
+
+		if len(label) > maxLabelLength {
+			err = errors.Errorf(
+				"label %s exceeds %d octets",
+				escapeLabel(label), maxLabelLength)
+			return
+		}
+
+		length += len(label) + 1
+	}
+
+	if length > maxNameLength {
+		err = errors.Errorf(
+			"name %s exceeds %d octets",
+			n, maxNameLength)
+	}
+
+	return
+}
+
+// Labels returns the labels of the name, leftmost first. The root
+// name has no labels.
+func (n Name) Labels() []string {
+	return append([]string(nil), n.labels...)
+}
+
+// IsRoot tells whether the name is the root (`.`).
+func (n Name) IsRoot() bool {
+	return len(n.labels) == 0
+}
+
+// String renders the fully qualified name in presentation format,
+// escaping dots within labels, the characters that are special in
+// master files and non-printable octets, e.g.:
+//
+//	a\.b.example.com.
+func (n Name) String() string {
+	if n.IsRoot() {
+		return "."
+	}
+
+	return n.text() + "."
+}
+
+// text renders the name as the structs of the library hold it: in
+// presentation format without the trailing dot, with the root being
+// `.`.
+func (n Name) text() string {
+	var buf = new(bytes.Buffer)
+
+	if n.IsRoot() {
+		return "."
+	}
+
+	for ndx, label := range n.labels {
+		if ndx > 0 {
+			buf.WriteByte('.')
+		}
+
+		writeEscapedLabel(buf, []byte(label))
+	}
+
+	return buf.String()
+}
+
+// escapeLabel renders a single label in presentation format.
+func escapeLabel(label string) string {
+	var buf = new(bytes.Buffer)
+
+	writeEscapedLabel(buf, []byte(label))
+	return buf.String()
+}
+
+// Marshal encodes the name in its uncompressed wire format.
+func (n Name) Marshal() (res []byte, err error) {
+	return n.AppendTo(nil)
+}
+
+// AppendTo appends the uncompressed wire format of the name to `b`.
+func (n Name) AppendTo(b []byte) (res []byte, err error) {
+	err = n.validate()
+	if err != nil {
+		return
+	}
+
+	res = b
+	for _, label := range n.labels {
+		res = append(res, uint8(len(label)))
+		res = append(res, label...)
+	}
+
+	res = append(res, 0)
+	return
+}
+
+// UnmarshalName decodes the uncompressed name at the beginning of
+// `msg`. Names that may be compressed must be decoded as part of the
+// message they came in (see `UnmarshalMessage`).
+func UnmarshalName(msg []byte, n *Name) (size int, err error) {
+	if n == nil {
+		err = errors.Errorf("name must be non-nil")
+		return
+	}
+
+	var text string
+
+	text, size, err = unmarshalName(msg, 0)
+	if err != nil {
+		err = parseErrorf(0, 0,
+			"malformed name: %s",
+			err)
+		return
+	}
+
+	*n, err = ParseName(text)
+	return
+}
+
+// Canonical returns the name with its uppercase US-ASCII letters
+// replaced by lowercase ones (RFC4034 section 6.2).
+func (n Name) Canonical() Name {
+	var canonical = Name{labels: make([]string, len(n.labels))}
+
+	for ndx, label := range n.labels {
+		canonical.labels[ndx] = asciiLower(label)
+	}
+
+	return canonical
+}
+
+// Equal tells whether both names are the same, comparing them
+// case-insensitively (RFC4343).
+func (n Name) Equal(other Name) bool {
+	if len(n.labels) != len(other.labels) {
+		return false
+	}
+
+	for ndx, label := range n.labels {
+		if asciiLower(label) != asciiLower(other.labels[ndx]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Compare compares names following the canonical ordering of RFC4034
+// section 6.1, returning -1, 0 or 1 if `n` sorts before, the same as
+// or after `other`.
+//
+// Names are sorted by their most significant (rightmost) labels first,
+// with labels compared case-insensitively as octet strings, e.g.:
+//
+//	example.
+//	a.example.
+//	Z.a.example.
+//	zABC.a.EXAMPLE.
+//	z.example.
+//	\001.z.example.
+//	*.z.example.
+//	\200.z.example.
+func (n Name) Compare(other Name) int {
+	var (
+		i = len(n.labels) - 1
+		j = len(other.labels) - 1
+	)
+
+	for ; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		cmp := strings.Compare(asciiLower(n.labels[i]), asciiLower(other.labels[j]))
+		if cmp != 0 {
+			return cmp
+		}
+	}
+
+	switch {
+	case i >= 0:
+		return 1
+	case j >= 0:
+		return -1
+	}
+
+	return 0
+}
+
+// Parent returns the name without its leftmost label. The parent of
+// the root is the root itself.
+func (n Name) Parent() Name {
+	if n.IsRoot() {
+		return n
+	}
+
+	return Name{labels: n.labels[1:]}
+}
+
+// Child returns the name prefixed by the label `label`, taken
+// verbatim.
+func (n Name) Child(label string) (child Name, err error) {
+	child = Name{labels: append([]string{label}, n.labels...)}
+
+	err = child.validate()
+	return
+}
+
+// Concat returns the name followed by `suffix` (e.g., `www` and
+// `example.com` result in `www.example.com`).
+func (n Name) Concat(suffix Name) (res Name, err error) {
+	res = Name{labels: make([]string, 0, len(n.labels)+len(suffix.labels))}
+	res.labels = append(res.labels, n.labels...)
+	res.labels = append(res.labels, suffix.labels...)
+
+	err = res.validate()
+	return
+}
+
+// IsSubdomainOf tells whether the name is equal to or below `parent`
+// (e.g., `www.example.com` is a subdomain of both `www.example.com`
+// and `example.com`, but not of `ample.com`).
+func (n Name) IsSubdomainOf(parent Name) bool {
+	var offset = len(n.labels) - len(parent.labels)
+
+	if offset < 0 {
+		return false
+	}
+
+	return Name{labels: n.labels[offset:]}.Equal(parent)
+}
+
+// IsChildOf tells whether the name is immediately below `parent`
+// (e.g., `www.example.com` is a child of `example.com`).
+func (n Name) IsChildOf(parent Name) bool {
+	return len(n.labels) == len(parent.labels)+1 && n.IsSubdomainOf(parent)
+}
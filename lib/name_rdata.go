@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// NameRDATA represents the RDATA of the obsolete (or experimental)
+// RFC1035 types whose RDATA is a single domain name: MD and MF
+// (section 3.3.4 and 3.3.5), MB (section 3.3.3), MG (section 3.3.6)
+// and MR (section 3.3.8).
+//
+// As that name may be compressed, it must be decoded rather than kept
+// as opaque RDATA, which wouldn't make sense outside of the original
+// message (RFC3597 section 4).
+type NameRDATA struct {
+	Name string
+}
+
+func unmarshalNameRData(msg []byte, off, length int) (data RData, err error) {
+	var d = new(NameRDATA)
+
+	_, err = unmarshalNameRDATA(msg, off, off+length, d)
+	if err != nil {
+		return
+	}
+
+	data = d
+	return
+}
+
+// UnmarshalNameRDATA decodes the RDATA of an MD, MF, MB, MG or MR
+// record into `d`. `msg` must contain exactly the (uncompressed)
+// RDATA of the record.
+func UnmarshalNameRDATA(msg []byte, d *NameRDATA) (n int, err error) {
+	return unmarshalNameRDATA(msg, 0, len(msg), d)
+}
+
+func unmarshalNameRDATA(msg []byte, off, end int, d *NameRDATA) (n int, err error) {
+	if d == nil {
+		err = errors.Errorf("name rdata must be non-nil")
+		return
+	}
+
+	d.Name, n, err = unmarshalRDATAName(msg, off, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read name")
+		return
+	}
+
+	err = checkRDATAEnd(off+n, end)
+	return
+}
+
+// Marshal encodes the RDATA without compressing the name.
+func (d NameRDATA) Marshal() (res []byte, err error) {
+	return d.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA to `b`.
+func (d NameRDATA) AppendTo(b []byte) (res []byte, err error) {
+	return appendRDATAName(b, d.Name)
+}
+
+// String renders the RDATA in presentation format.
+func (d NameRDATA) String() string {
+	return fqdn(d.Name)
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameRDATAMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		entity    *NameRDATA
+		presented string
+	}{
+		{
+			desc:      "well formed",
+			entity:    &NameRDATA{Name: "mail.example.com"},
+			presented: "mail.example.com.",
+		},
+		{
+			desc:      "root",
+			entity:    &NameRDATA{Name: "."},
+			presented: ".",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *NameRDATA
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			require.NoError(t, err)
+
+			unmarshalled = new(NameRDATA)
+			_, err = UnmarshalNameRDATA(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestNameRDATAWithCompressedNames(t *testing.T) {
+	for _, qtype := range []QType{QTypeMD, QTypeMF, QTypeMB, QTypeMG, QTypeMR} {
+		t.Run(qtype.String(), func(t *testing.T) {
+			// example at offset 0 followed by a record whose
+			// RDATA points back at it.
+			var msg = []byte{
+				7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0,
+				0xc0, 0, 0, byte(qtype), 0, 1, 0, 0, 0, 60, 0, 6,
+				3, 'm', 'b', 'x', 0xc0, 0,
+			}
+
+			var rr = new(RR)
+
+			_, err := unmarshalRR(msg, 9, rr, false)
+			require.NoError(t, err)
+			assert.Equal(t, &NameRDATA{Name: "mbx.example"}, rr.Data)
+
+			// re-encoding must expand the name rather than
+			// keep the pointer.
+			res, err := rr.Marshal()
+			require.NoError(t, err)
+			assert.Equal(t, []byte{3, 'm', 'b', 'x', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0}, res[len(res)-13:])
+		})
+	}
+}
+
+func TestUnmarshalNameRDATARejectsTrailingBytes(t *testing.T) {
+	var msg = []byte{1, 'a', 0, 0xff}
+
+	_, err := UnmarshalNameRDATA(msg, new(NameRDATA))
+	require.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseName(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		input      string
+		labels     []string
+		presented  string
+		shouldFail bool
+	}{
+		{
+			desc:      "root",
+			input:     ".",
+			presented: ".",
+		},
+		{
+			desc:      "empty is root",
+			input:     "",
+			presented: ".",
+		},
+		{
+			desc:      "relative",
+			input:     "www.example.com",
+			labels:    []string{"www", "example", "com"},
+			presented: "www.example.com.",
+		},
+		{
+			desc:      "fully qualified",
+			input:     "www.example.com.",
+			labels:    []string{"www", "example", "com"},
+			presented: "www.example.com.",
+		},
+		{
+			desc:      "escaped dot",
+			input:     `first\.last.example.com`,
+			labels:    []string{"first.last", "example", "com"},
+			presented: `first\.last.example.com.`,
+		},
+		{
+			desc:      "decimal escapes",
+			input:     `a\032b\000.com`,
+			labels:    []string{"a b\x00", "com"},
+			presented: `a\032b\000.com.`,
+		},
+		{
+			desc:      "case is preserved",
+			input:     "WWW.Example.COM",
+			labels:    []string{"WWW", "Example", "COM"},
+			presented: "WWW.Example.COM.",
+		},
+		{
+			desc:       "empty label",
+			input:      "www..com",
+			shouldFail: true,
+		},
+		{
+			desc:       "leading dot",
+			input:      ".com",
+			shouldFail: true,
+		},
+		{
+			desc:       "malformed escape",
+			input:      `a\25`,
+			shouldFail: true,
+		},
+		{
+			desc:       "escape out of range",
+			input:      `a\256`,
+			shouldFail: true,
+		},
+		{
+			desc:   "label of 63 octets",
+			input:  strings.Repeat("a", 63) + ".com",
+			labels: []string{strings.Repeat("a", 63), "com"},
+		},
+		{
+			desc:       "label of 64 octets",
+			input:      strings.Repeat("a", 64) + ".com",
+			shouldFail: true,
+		},
+		{
+			desc:       "label exceeding the limit through escapes",
+			input:      strings.Repeat(`\097`, 64),
+			shouldFail: true,
+		},
+		{
+			desc:   "name of 255 octets",
+			input:  strings.Repeat(strings.Repeat("a", 62)+".", 4) + "a",
+			labels: append(strings.Split(strings.Repeat(strings.Repeat("a", 62)+".", 4), ".")[:4], "a"),
+		},
+		{
+			desc:       "name of 256 octets",
+			input:      strings.Repeat(strings.Repeat("a", 62)+".", 4) + "ab",
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			name, err := ParseName(tc.input)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.labels, name.Labels())
+
+			if tc.presented != "" {
+				assert.Equal(t, tc.presented, name.String())
+			}
+
+			wire, err := name.Marshal()
+			require.NoError(t, err)
+
+			var unmarshalled Name
+			n, err := UnmarshalName(wire, &unmarshalled)
+			require.NoError(t, err)
+			assert.Equal(t, len(wire), n)
+			assert.Equal(t, name.Labels(), unmarshalled.Labels())
+		})
+	}
+}
+
+func TestNewName(t *testing.T) {
+	name, err := NewName("a.b", "example", "com")
+	require.NoError(t, err)
+	assert.Equal(t, `a\.b.example.com.`, name.String())
+
+	_, err = NewName("", "com")
+	require.Error(t, err)
+
+	_, err = NewName(strings.Repeat("a", 64))
+	require.Error(t, err)
+}
+
+func TestNameEqual(t *testing.T) {
+	assert.True(t, MustParseName("WWW.Example.com").Equal(MustParseName("www.example.COM.")))
+	assert.True(t, MustParseName(`\065.com`).Equal(MustParseName("a.com")))
+	assert.True(t, RootName.Equal(MustParseName(".")))
+	assert.False(t, MustParseName("a.b.com").Equal(MustParseName(`a\.b.com`)))
+	assert.False(t, MustParseName("example.com").Equal(MustParseName("example.org")))
+	assert.False(t, MustParseName(`\200.com`).Equal(MustParseName(`\232.com`)))
+}
+
+func TestNameCompare(t *testing.T) {
+	// RFC4034 section 6.1
+	var expected = []string{
+		"example.",
+		"a.example.",
+		"yljkjljk.a.example.",
+		"Z.a.example.",
+		"zABC.a.EXAMPLE.",
+		"z.example.",
+		`\001.z.example.`,
+		"*.z.example.",
+		`\200.z.example.`,
+	}
+
+	var names []Name
+	for ndx := len(expected) - 1; ndx >= 0; ndx-- {
+		names = append(names, MustParseName(expected[ndx]))
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].Compare(names[j]) < 0
+	})
+
+	var actual []string
+	for _, name := range names {
+		actual = append(actual, name.String())
+	}
+
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, 0, MustParseName("A.example").Compare(MustParseName("a.EXAMPLE")))
+	assert.Equal(t, -1, RootName.Compare(MustParseName("com")))
+}
+
+func TestNameHierarchy(t *testing.T) {
+	var (
+		www     = MustParseName("www.example.com")
+		example = MustParseName("Example.COM")
+	)
+
+	assert.True(t, www.IsSubdomainOf(example))
+	assert.True(t, www.IsSubdomainOf(www))
+	assert.True(t, www.IsSubdomainOf(RootName))
+	assert.False(t, example.IsSubdomainOf(www))
+	assert.False(t, www.IsSubdomainOf(MustParseName("ample.com")))
+	assert.False(t, MustParseName(`www\.example.com`).IsSubdomainOf(example))
+
+	assert.True(t, www.IsChildOf(example))
+	assert.False(t, www.IsChildOf(MustParseName("com")))
+	assert.False(t, www.IsChildOf(www))
+
+	assert.True(t, www.Parent().Equal(example))
+	assert.True(t, RootName.Parent().IsRoot())
+
+	child, err := example.Child("a.b")
+	require.NoError(t, err)
+	assert.Equal(t, `a\.b.Example.COM.`, child.String())
+
+	concat, err := MustParseName("www").Concat(example)
+	require.NoError(t, err)
+	assert.True(t, concat.Equal(www))
+
+	_, err = MustParseName(strings.Repeat(strings.Repeat("a", 62)+".", 4)).Concat(MustParseName("com"))
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// NS represents the RDATA of an NS record (RFC1035 section 3.3.11):
+// the domain name of a host that should be authoritative for the
+// class and domain specified.
+type NS struct {
+	Host string
+}
+
+func unmarshalNSRData(msg []byte, off, length int) (data RData, err error) {
+	var ns = new(NS)
+
+	_, err = unmarshalNS(msg, off, off+length, ns)
+	if err != nil {
+		return
+	}
+
+	data = ns
+	return
+}
+
+// UnmarshalNS decodes the RDATA of an NS record into `ns`. `msg`
+// must contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalNS(msg []byte, ns *NS) (n int, err error) {
+	return unmarshalNS(msg, 0, len(msg), ns)
+}
+
+func unmarshalNS(msg []byte, off, end int, ns *NS) (n int, err error) {
+	if ns == nil {
+		err = errors.Errorf("ns must be non-nil")
+		return
+	}
+
+	ns.Host, n, err = unmarshalRDATAName(msg, off, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read host")
+		return
+	}
+
+	err = checkRDATAEnd(off+n, end)
+	return
+}
+
+// Marshal encodes the RDATA of the NS record.
+func (ns NS) Marshal() (res []byte, err error) {
+	return ns.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the NS record to `b`.
+func (ns NS) AppendTo(b []byte) (res []byte, err error) {
+	return appendRDATAName(b, ns.Host)
+}
+
+// String renders the RDATA in presentation format.
+func (ns NS) String() string {
+	return fqdn(ns.Host)
+}
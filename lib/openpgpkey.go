@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// OPENPGPKEY represents the RDATA of an OPENPGPKEY record as
+// described in RFC7929 section 2.1: a single OpenPGP Transferable
+// Public Key in its binary form.
+type OPENPGPKEY struct {
+	PublicKey []byte
+}
+
+func unmarshalOPENPGPKEYRData(msg []byte, off, length int) (data RData, err error) {
+	var k = new(OPENPGPKEY)
+
+	_, err = UnmarshalOPENPGPKEY(msg[off:off+length], k)
+	if err != nil {
+		return
+	}
+
+	data = k
+	return
+}
+
+// UnmarshalOPENPGPKEY decodes the RDATA of an OPENPGPKEY record into
+// `k`. `msg` must contain exactly the RDATA of the record.
+func UnmarshalOPENPGPKEY(msg []byte, k *OPENPGPKEY) (n int, err error) {
+	if k == nil {
+		err = errors.Errorf("openpgpkey must be non-nil")
+		return
+	}
+
+	if len(msg) == 0 {
+		err = errors.Errorf("openpgpkey rdata must be non-empty")
+		return
+	}
+
+	k.PublicKey = append([]byte{}, msg...)
+
+	n = len(msg)
+	return
+}
+
+// Marshal encodes the RDATA of the OPENPGPKEY record.
+func (k OPENPGPKEY) Marshal() (res []byte, err error) {
+	if len(k.PublicKey) == 0 {
+		err = errors.Errorf("public key must be non-empty")
+		return
+	}
+
+	res = k.PublicKey
+	return
+}
+
+// String renders the RDATA in presentation format - the base64
+// encoding of the key.
+func (k OPENPGPKEY) String() string {
+	return base64.StdEncoding.EncodeToString(k.PublicKey)
+}
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOPENPGPKEYMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		entity     *OPENPGPKEY
+		presented  string
+		shouldFail bool
+	}{
+		{
+			desc:       "empty key",
+			entity:     &OPENPGPKEY{},
+			shouldFail: true,
+		},
+		{
+			desc: "key",
+			entity: &OPENPGPKEY{
+				PublicKey: []byte{0x99, 0x01, 0x0d, 0x04},
+			},
+			presented: "mQENBA==",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *OPENPGPKEY
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			unmarshalled = new(OPENPGPKEY)
+			_, err = UnmarshalOPENPGPKEY(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"fmt"
+)
+
+// ParseError is the error returned by the decoders when a message is
+// malformed (e.g., it's cut short or a name points outside of it),
+// telling where in the message decoding stopped.
+type ParseError struct {
+
+	// Section is the section of the message that was being
+	// decoded, if known.
+	Section Section
+
+	// Offset is the offset within the message of the malformed
+	// data (e.g., the start of the record that doesn't fit).
+	Offset int
+
+	// Reason describes what's wrong with the data.
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	if e.Section == 0 {
+		return fmt.Sprintf("malformed message at offset %d: %s",
+			e.Offset, e.Reason)
+	}
+
+	return fmt.Sprintf("malformed %s section at offset %d: %s",
+		e.Section, e.Offset, e.Reason)
+}
+
+// parseErrorf creates a *ParseError with its reason formatted from
+// `format` and `args`.
+func parseErrorf(section Section, off int, format string, args ...interface{}) error {
+	return &ParseError{
+		Section: section,
+		Offset:  off,
+		Reason:  fmt.Sprintf(format, args...),
+	}
+}
+
+// inSection attributes an error found while decoding the element
+// `index` (e.g., the second answer) of a section to that section.
+func inSection(err error, section Section, index int) error {
+	perr, ok := err.(*ParseError)
+	if !ok {
+		return err
+	}
+
+	return &ParseError{
+		Section: section,
+		Offset:  perr.Offset,
+		Reason:  fmt.Sprintf("%s %d: %s", section, index, perr.Reason),
+	}
+}
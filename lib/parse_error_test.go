@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// malformedHeader is the header of a response with the given counts
+// of questions and answers.
+func malformedHeader(qdcount, ancount byte) []byte {
+	return []byte{0x00, 0x01, 0x81, 0x80, 0x00, qdcount, 0x00, ancount, 0x00, 0x00, 0x00, 0x00}
+}
+
+// rootQuestion is a question for the A records of the root.
+var rootQuestion = []byte{0, 0x00, 0x01, 0x00, 0x01}
+
+func TestUnmarshalMessage_malformed(t *testing.T) {
+	var testCases = []struct {
+		desc            string
+		msg             []byte
+		section         Section
+		offset          int
+		lenientSucceeds bool
+	}{
+		{
+			desc:    "empty",
+			msg:     []byte{},
+			section: SectionHeader,
+		},
+		{
+			desc:    "short header",
+			msg:     compressedResponse[:11],
+			section: SectionHeader,
+		},
+		{
+			desc:    "counts that can't fit",
+			msg:     compressedResponse[:40],
+			section: SectionHeader,
+		},
+		{
+			desc:    "forward pointer in question",
+			msg:     append(malformedHeader(1, 0), 0xc0, 0x20, 0x00, 0x01, 0x00, 0x01),
+			section: SectionQuestion,
+			offset:  12,
+		},
+		{
+			desc:    "question without qclass",
+			msg:     append(malformedHeader(1, 0), 1, 'a', 0, 0x00, 0x01, 0x00),
+			section: SectionQuestion,
+			offset:  12,
+		},
+		{
+			desc: "record without rdlength",
+			msg: append(append(malformedHeader(1, 1), rootQuestion...),
+				1, 'a', 0, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00),
+			section: SectionAnswer,
+			offset:  17,
+		},
+		{
+			desc: "rdata past the end",
+			msg: append(append(malformedHeader(1, 1), rootQuestion...),
+				0, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0a,
+				192, 0, 2, 1),
+			section: SectionAnswer,
+			offset:  28,
+		},
+		{
+			desc: "malformed rdata",
+			msg: append(append(malformedHeader(1, 1), rootQuestion...),
+				0, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x03,
+				192, 0, 2),
+			section:         SectionAnswer,
+			offset:          28,
+			lenientSucceeds: true,
+		},
+		{
+			desc: "malformed rdata with compressed names",
+			msg: append(append(malformedHeader(1, 1), rootQuestion...),
+				0, 0x00, 0x0f, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x05,
+				0x00, 0x0a, 0xc0, 0x0c, 0xff),
+			section: SectionAnswer,
+			offset:  28,
+		},
+		{
+			desc:            "trailing bytes",
+			msg:             append(append([]byte{}, compressedResponse...), 0xca, 0xfe),
+			offset:          len(compressedResponse),
+			lenientSucceeds: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var perr *ParseError
+
+			err := UnmarshalMessage(tc.msg, new(Message))
+			require.Error(t, err)
+			require.True(t, errors.As(err, &perr), "%v is not a *ParseError", err)
+			assert.Equal(t, tc.section, perr.Section)
+			assert.Equal(t, tc.offset, perr.Offset)
+
+			err = UnmarshalOptions{Lenient: true}.Unmarshal(tc.msg, new(Message))
+			if tc.lenientSucceeds {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalOptions_lenientKeepsMalformedRData(t *testing.T) {
+	var (
+		m   Message
+		msg = append(append(malformedHeader(1, 1), rootQuestion...),
+			0, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x03,
+			192, 0, 2)
+	)
+
+	err := UnmarshalOptions{Lenient: true}.Unmarshal(msg, &m)
+	require.NoError(t, err)
+	require.Len(t, m.Answers, 1)
+	assert.Equal(t, &UnknownRDATA{Raw: []byte{192, 0, 2}}, m.Answers[0].Data)
+}
+
+func TestDecoders_shortInput(t *testing.T) {
+	for n := 0; n < len(compressedResponse); n++ {
+		var msg []byte
+
+		if n > 12 {
+			msg = compressedResponse[12:n]
+		}
+
+		_, err := UnmarshalHeader(compressedResponse[:n], new(Header))
+		if n != 12 {
+			assert.IsType(t, &ParseError{}, err)
+		}
+
+		_, err = UnmarshalQuestion(msg, new(Question))
+		if len(msg) < 17 {
+			assert.IsType(t, &ParseError{}, err)
+		}
+
+		_, err = UnmarshalRR(msg, new(RR))
+		assert.Error(t, err)
+
+		_, err = UnmarshalName(msg, new(Name))
+		if len(msg) < 13 {
+			assert.IsType(t, &ParseError{}, err)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	assert.Equal(t,
+		"malformed answer section at offset 28: answer 0: malformed rdata",
+		(&ParseError{Section: SectionAnswer, Offset: 28, Reason: "answer 0: malformed rdata"}).Error())
+
+	assert.Equal(t,
+		"malformed message at offset 7: 2 trailing bytes after the last record",
+		(&ParseError{Offset: 7, Reason: "2 trailing bytes after the last record"}).Error())
+}
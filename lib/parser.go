@@ -0,0 +1,366 @@
+package lib
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Section identifies one of the sections of a message. The zero value
+// stands for none in particular.
+type Section uint8
+
+const (
+	SectionHeader Section = iota + 1
+	SectionQuestion
+	SectionAnswer
+	SectionAuthority
+	SectionAdditional
+)
+
+var sectionNames = map[Section]string{
+	SectionHeader:     "header",
+	SectionQuestion:   "question",
+	SectionAnswer:     "answer",
+	SectionAuthority:  "authority",
+	SectionAdditional: "additional",
+}
+
+func (s Section) String() string {
+	if name, ok := sectionNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Parser walks through the sections of a message lazily, decoding one
+// question or record at a time into structs supplied by the caller
+// that refer back to the original buffer instead of copying from it.
+//
+// Contrary to `UnmarshalMessage`, walking a message doesn't allocate,
+// which makes it suitable for inspecting high rates of messages (e.g.,
+// in a sniffer). A Parser may be reused for many messages:
+//
+//	var (
+//		p  Parser
+//		rr RawRR
+//	)
+//
+//	for _, msg := range msgs {
+//		header, err := p.Start(msg)
+//		...
+//		for {
+//			ok, err := p.NextRR(&rr)
+//			if err != nil || !ok {
+//				break
+//			}
+//			...
+//		}
+//	}
+//
+// The structs filled by the Parser are only valid as long as the
+// buffer isn't modified.
+type Parser struct {
+	msg     []byte
+	off     int
+	section Section
+	counts  [SectionAdditional + 1]uint16
+	read    [SectionAdditional + 1]uint16
+}
+
+// RawName is a domain name as it sits in a message, possibly
+// compressed. It's only valid along with the message it came in.
+type RawName struct {
+	msg []byte
+	off int
+}
+
+// RawQuestion is a question as read by a Parser.
+type RawQuestion struct {
+	Name   RawName
+	QTYPE  QType
+	QCLASS QClass
+}
+
+// RawRR is a resource record as read by a Parser. RDATA is a slice of
+// the message, with any names in it possibly compressed.
+type RawRR struct {
+	Section  Section
+	Name     RawName
+	TYPE     QType
+	CLASS    QClass
+	TTL      uint32
+	RDLENGTH uint16
+	RDATA    []byte
+
+	// index is the position of the record within its section,
+	// while off and rdata are the offsets of the record and of its
+	// RDATA within the message.
+	index int
+	off   int
+	rdata int
+}
+
+// Start resets the parser to walk the message `msg`, returning its
+// header.
+func (p *Parser) Start(msg []byte) (h Header, err error) {
+	*p = Parser{msg: msg}
+
+	if len(msg) < 12 {
+		err = parseErrorf(SectionHeader, 0,
+			"message must have at least 12 bytes - %d",
+			len(msg))
+		return
+	}
+
+	_, err = UnmarshalHeader(msg[:12], &h)
+	if err != nil {
+		return
+	}
+
+	p.off = 12
+	p.section = SectionQuestion
+	p.counts[SectionQuestion] = h.QDCOUNT
+	p.counts[SectionAnswer] = h.ANCOUNT
+	p.counts[SectionAuthority] = h.NSCOUNT
+	p.counts[SectionAdditional] = h.ARCOUNT
+	return
+}
+
+// NextQuestion reads the next question into `q`, with `ok` being
+// false once all of them have been read.
+func (p *Parser) NextQuestion(q *RawQuestion) (ok bool, err error) {
+	var n int
+
+	if p.section != SectionQuestion || p.read[SectionQuestion] == p.counts[SectionQuestion] {
+		return
+	}
+
+	n, err = skipName(p.msg, p.off)
+	if err != nil {
+		err = parseErrorf(SectionQuestion, p.off,
+			"question %d: %s",
+			p.read[SectionQuestion], err)
+		return
+	}
+
+	if len(p.msg)-p.off-n < 4 {
+		err = parseErrorf(SectionQuestion, p.off,
+			"question %d goes past the end of the message",
+			p.read[SectionQuestion])
+		return
+	}
+
+	q.Name = RawName{msg: p.msg, off: p.off}
+	p.off += n
+
+	q.QTYPE = QType(binary.BigEndian.Uint16(p.msg[p.off : p.off+2]))
+	q.QCLASS = QClass(binary.BigEndian.Uint16(p.msg[p.off+2 : p.off+4]))
+	p.off += 4
+
+	p.read[SectionQuestion]++
+	ok = true
+	return
+}
+
+// NextRR reads the next resource record of the answer, authority or
+// additional sections into `r`, with `ok` being false once all of
+// them have been read. Questions that weren't read are skipped.
+func (p *Parser) NextRR(r *RawRR) (ok bool, err error) {
+	var (
+		q RawQuestion
+		n int
+	)
+
+	for p.section == SectionQuestion {
+		ok, err = p.NextQuestion(&q)
+		if err != nil {
+			return
+		}
+
+		if !ok {
+			p.section = SectionAnswer
+		}
+	}
+
+	for p.section <= SectionAdditional && p.read[p.section] == p.counts[p.section] {
+		p.section++
+	}
+
+	if p.section > SectionAdditional {
+		return
+	}
+
+	n, err = skipName(p.msg, p.off)
+	if err != nil {
+		err = parseErrorf(p.section, p.off,
+			"%s %d: %s",
+			p.section, p.read[p.section], err)
+		return
+	}
+
+	var ndx = p.off + n
+
+	if len(p.msg)-ndx < 10 {
+		err = parseErrorf(p.section, p.off,
+			"%s %d goes past the end of the message",
+			p.section, p.read[p.section])
+		return
+	}
+
+	r.Section = p.section
+	r.Name = RawName{msg: p.msg, off: p.off}
+	r.TYPE = QType(binary.BigEndian.Uint16(p.msg[ndx : ndx+2]))
+	r.CLASS = QClass(binary.BigEndian.Uint16(p.msg[ndx+2 : ndx+4]))
+	r.TTL = binary.BigEndian.Uint32(p.msg[ndx+4 : ndx+8])
+	r.RDLENGTH = binary.BigEndian.Uint16(p.msg[ndx+8 : ndx+10])
+	ndx += 10
+
+	if len(p.msg)-ndx < int(r.RDLENGTH) {
+		err = parseErrorf(p.section, ndx,
+			"rdata of %s %d goes past the end of the message",
+			p.section, p.read[p.section])
+		return
+	}
+
+	r.RDATA = p.msg[ndx : ndx+int(r.RDLENGTH)]
+	r.index = int(p.read[p.section])
+	r.off = p.off
+	r.rdata = ndx
+
+	p.off = ndx + int(r.RDLENGTH)
+	p.read[p.section]++
+	ok = true
+	return
+}
+
+// Decode fully decodes the question into `q`.
+func (r *RawQuestion) Decode(q *Question) (err error) {
+	if q == nil {
+		err = errors.Errorf("question must be non-nil")
+		return
+	}
+
+	q.QNAME = r.Name.Name().text()
+	q.QTYPE = r.QTYPE
+	q.QCLASS = r.QCLASS
+	return
+}
+
+// Decode fully decodes the record into `rr`, as `UnmarshalMessage`
+// would, typed RDATA included.
+func (r *RawRR) Decode(rr *RR) (err error) {
+	_, err = unmarshalRR(r.Name.msg, r.off, rr, false)
+	if err != nil {
+		err = inSection(err, r.Section, r.index)
+	}
+
+	return
+}
+
+// Data decodes the typed representation of the RDATA of the record
+// (see `RR.Data`).
+func (r *RawRR) Data() (data RData, err error) {
+	data, err = unmarshalRDATA(r.TYPE, r.Name.msg, r.rdata, len(r.RDATA))
+	if err != nil {
+		err = parseErrorf(r.Section, r.rdata,
+			"%s %d: %s",
+			r.Section, r.index, err)
+	}
+
+	return
+}
+
+// label returns the label found at the offset `ndx` of the message,
+// after following any pointers, along with the offset of the label
+// that follows it. The label is nil once the root is reached.
+//
+// The name must have been validated with `skipName`.
+func (n RawName) label(ndx int) (label []byte, next int) {
+	for n.msg[ndx]>>6 == 3 {
+		ndx = int(binary.BigEndian.Uint16(n.msg[ndx:ndx+2]) & 0x3fff)
+	}
+
+	size := int(n.msg[ndx])
+	if size == 0 {
+		return nil, ndx
+	}
+
+	return n.msg[ndx+1 : ndx+1+size], ndx + 1 + size
+}
+
+// Name decodes the name.
+func (n RawName) Name() (res Name) {
+	for label, ndx := n.label(n.off); label != nil; label, ndx = n.label(ndx) {
+		res.labels = append(res.labels, string(label))
+	}
+
+	return
+}
+
+// AppendText appends the fully qualified name in presentation format
+// (as `Name.String` renders it) to `b`.
+func (n RawName) AppendText(b []byte) []byte {
+	var label, ndx = n.label(n.off)
+
+	if label == nil {
+		return append(b, '.')
+	}
+
+	for ; label != nil; label, ndx = n.label(ndx) {
+		b = appendEscapedLabel(b, label)
+		b = append(b, '.')
+	}
+
+	return b
+}
+
+// String renders the fully qualified name in presentation format.
+func (n RawName) String() string {
+	return string(n.AppendText(nil))
+}
+
+// Equal tells whether the name is the same as `other`, comparing them
+// case-insensitively (RFC4343).
+func (n RawName) Equal(other Name) bool {
+	var (
+		label, ndx = n.label(n.off)
+		count      int
+	)
+
+	for ; label != nil; label, ndx = n.label(ndx) {
+		if count == len(other.labels) || !equalFoldASCII(label, other.labels[count]) {
+			return false
+		}
+
+		count++
+	}
+
+	return count == len(other.labels)
+}
+
+// equalFoldASCII tells whether `a` and `b` are the same, ignoring the
+// case of US-ASCII letters.
+func equalFoldASCII(a []byte, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for ndx := 0; ndx < len(a); ndx++ {
+		if lowerASCII(a[ndx]) != lowerASCII(b[ndx]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lowerASCII lowercases `c` if it's an uppercase US-ASCII letter.
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
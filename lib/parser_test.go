@@ -0,0 +1,259 @@
+package lib
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compressedResponse is a response to `example.com. IN A` with names
+// compressed as servers usually send them.
+var compressedResponse = []byte{
+	// header: id 42, qr rd ra, 1 question, 2 answers, 1 authority
+	0x00, 0x2a, 0x81, 0x80, 0x00, 0x01, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00,
+
+	// question (offset 12)
+	7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+	0x00, 0x01, 0x00, 0x01,
+
+	// answer: example.com. 300 IN A 93.184.216.34
+	0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x04,
+	93, 184, 216, 34,
+
+	// answer: example.com. 300 IN A 93.184.216.35
+	0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x04,
+	93, 184, 216, 35,
+
+	// authority: example.com. 3600 IN NS a.iana-servers.net.
+	0xc0, 0x0c, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x14,
+	1, 'a', 12, 'i', 'a', 'n', 'a', '-', 's', 'e', 'r', 'v', 'e', 'r', 's',
+	3, 'n', 'e', 't', 0,
+}
+
+func TestParser(t *testing.T) {
+	var (
+		p        Parser
+		q        RawQuestion
+		rr       RawRR
+		expected = new(Message)
+	)
+
+	require.NoError(t, UnmarshalMessage(compressedResponse, expected))
+
+	header, err := p.Start(compressedResponse)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Header, header)
+
+	ok, err := p.NextQuestion(&q)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "example.com.", q.Name.String())
+	assert.True(t, q.Name.Equal(MustParseName("EXAMPLE.com")))
+	assert.False(t, q.Name.Equal(MustParseName("www.example.com")))
+	assert.False(t, q.Name.Equal(MustParseName("com")))
+
+	var question Question
+	require.NoError(t, q.Decode(&question))
+	assert.Equal(t, *expected.Questions[0], question)
+
+	ok, err = p.NextQuestion(&q)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var records []*RR
+	var sections []Section
+	for {
+		ok, err = p.NextRR(&rr)
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+
+		var decoded = new(RR)
+		require.NoError(t, rr.Decode(decoded))
+		assert.Equal(t, decoded.NAME, rr.Name.Name().text())
+
+		data, err := rr.Data()
+		require.NoError(t, err)
+		assert.Equal(t, decoded.Data, data)
+
+		records = append(records, decoded)
+		sections = append(sections, rr.Section)
+	}
+
+	assert.Equal(t, append(expected.Answers, expected.Authorities...), records)
+	assert.Equal(t, []Section{SectionAnswer, SectionAnswer, SectionAuthority}, sections)
+
+	ok, err = p.NextRR(&rr)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestParser_skipsQuestions(t *testing.T) {
+	var (
+		p  Parser
+		rr RawRR
+	)
+
+	_, err := p.Start(compressedResponse)
+	require.NoError(t, err)
+
+	ok, err := p.NextRR(&rr)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, SectionAnswer, rr.Section)
+	assert.Equal(t, QTypeA, rr.TYPE)
+	assert.Equal(t, uint32(300), rr.TTL)
+	assert.Equal(t, []byte{93, 184, 216, 34}, rr.RDATA)
+}
+
+func TestParser_malformed(t *testing.T) {
+	var testCases = []struct {
+		desc string
+		msg  []byte
+	}{
+		{
+			desc: "short header",
+			msg:  compressedResponse[:11],
+		},
+		{
+			desc: "truncated question",
+			msg:  compressedResponse[:20],
+		},
+		{
+			desc: "truncated record",
+			msg:  compressedResponse[:40],
+		},
+		{
+			desc: "truncated rdata",
+			msg:  compressedResponse[:len(compressedResponse)-1],
+		},
+		{
+			desc: "forward pointer",
+			msg: append(append([]byte{}, compressedResponse[:29]...),
+				0xc0, 0x30, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x04,
+				93, 184, 216, 34),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var (
+				p  Parser
+				rr RawRR
+			)
+
+			_, err := p.Start(tc.msg)
+			for err == nil {
+				var ok bool
+
+				ok, err = p.NextRR(&rr)
+				if !ok && err == nil {
+					t.Fatal("expected the message to be rejected")
+				}
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParser_doesNotAllocate(t *testing.T) {
+	var (
+		p    Parser
+		q    RawQuestion
+		rr   RawRR
+		buf  = make([]byte, 0, maxNameLength*4)
+		name = MustParseName("example.com")
+	)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, err := p.Start(compressedResponse)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for ok, _ := p.NextQuestion(&q); ok; ok, _ = p.NextQuestion(&q) {
+			buf = q.Name.AppendText(buf[:0])
+		}
+
+		for ok, _ := p.NextRR(&rr); ok; ok, _ = p.NextRR(&rr) {
+			buf = rr.Name.AppendText(buf[:0])
+			if !rr.Name.Equal(name) {
+				t.Fatal("unexpected name")
+			}
+		}
+	})
+
+	assert.Equal(t, float64(0), allocs)
+}
+
+// largeResponse is a response with many compressed records, as the
+// ones seen when resolving popular names.
+func largeResponse(b *testing.B) []byte {
+	var message = &Message{
+		Header: Header{ID: 1, QR: 1, RD: 1, RA: 1, QDCOUNT: 1, ANCOUNT: 16},
+		Questions: []*Question{
+			{QNAME: "www.example.com", QTYPE: QTypeA, QCLASS: QClassIN},
+		},
+	}
+
+	for ndx := 0; ndx < 16; ndx++ {
+		message.Answers = append(message.Answers, &RR{
+			NAME: "www.example.com", TYPE: QTypeA, CLASS: QClassIN, TTL: 300,
+			Data: &A{Address: net.IPv4(192, 0, 2, byte(ndx)).To4()},
+		})
+	}
+
+	msg, err := message.Marshal()
+	require.NoError(b, err)
+
+	return msg
+}
+
+func BenchmarkUnmarshalMessage(b *testing.B) {
+	var msg = largeResponse(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for ndx := 0; ndx < b.N; ndx++ {
+		var m Message
+
+		err := UnmarshalMessage(msg, &m)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	var (
+		msg = largeResponse(b)
+		p   Parser
+		rr  RawRR
+	)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for ndx := 0; ndx < b.N; ndx++ {
+		_, err := p.Start(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for {
+			ok, err := p.NextRR(&rr)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if !ok {
+				break
+			}
+		}
+	}
+}
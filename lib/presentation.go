@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// fqdn renders a domain name as stored in the library's structs
+// (in presentation format, possibly without the trailing dot) in its
+// fully qualified form.
+func fqdn(name string) string {
+	n, err := ParseName(name)
+	if err != nil {
+		if strings.HasSuffix(name, ".") {
+			return name
+		}
+
+		return name + "."
+	}
+
+	return n.String()
+}
+
+// writeEscapedLabel writes a label to `buf` escaping the characters
+// that are special in master files (RFC1035 section 5.1) with a
+// backslash and the non-printable ones as `\DDD`.
+func writeEscapedLabel(buf *bytes.Buffer, label []byte) {
+	var scratch [4 * maxLabelLength]byte
+
+	buf.Write(appendEscapedLabel(scratch[:0], label))
+}
+
+// appendEscapedLabel is like writeEscapedLabel but appends the
+// escaped label to `b`.
+func appendEscapedLabel(b []byte, label []byte) []byte {
+	for _, c := range label {
+		switch {
+		case strings.IndexByte(`."();@$\`, c) >= 0:
+			b = append(b, '\\', c)
+		case c <= ' ' || c > '~':
+			b = append(b, '\\', '0'+c/100, '0'+c/10%10, '0'+c%10)
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return b
+}
+
+// quoteCharacterString renders a <character-string> in the
+// presentation format described in RFC1035 section 5.1: the
+// string is wrapped in quotes, `"` and `\` are escaped with a
+// backslash and non-printable octets are rendered as `\DDD`.
+func quoteCharacterString(b []byte) string {
+	var buf = new(bytes.Buffer)
+
+	buf.WriteByte('"')
+	writeEscaped(buf, b)
+	buf.WriteByte('"')
+
+	return buf.String()
+}
+
+// writeEscaped writes the octets of `b` to `buf` escaping those
+// that can't appear verbatim inside a quoted character-string.
+func writeEscaped(buf *bytes.Buffer, b []byte) {
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(buf, "\\%03d", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
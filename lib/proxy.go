@@ -0,0 +1,212 @@
+package lib
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Strategy decides the order in which a Proxy tries its upstreams.
+type Strategy uint8
+
+const (
+	// StrategySequential tries the upstreams in the order they were
+	// given, failing over to the next one.
+	StrategySequential Strategy = iota
+
+	// StrategyRandom starts with a random upstream, failing over to
+	// the others in random order.
+	StrategyRandom
+
+	// StrategyFastest starts with the upstream with the lowest
+	// average latency (an exponentially weighted moving average),
+	// with the ones never tried going first.
+	StrategyFastest
+)
+
+var strategyNames = map[Strategy]string{
+	StrategySequential: "sequential",
+	StrategyRandom:     "random",
+	StrategyFastest:    "fastest",
+}
+
+func (s Strategy) String() string {
+	if name, ok := strategyNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// ParseStrategy parses the name of a strategy (e.g., `fastest`).
+func ParseStrategy(s string) (strategy Strategy, err error) {
+	for strategy, name := range strategyNames {
+		if name == s {
+			return strategy, nil
+		}
+	}
+
+	err = errors.Errorf("unknown strategy %s", s)
+	return
+}
+
+const (
+	// ewmaWeight is the weight of the latest latency in the moving
+	// average of an upstream.
+	ewmaWeight = 0.3
+)
+
+// ProxyConfig configures a Proxy.
+type ProxyConfig struct {
+
+	// Upstreams are the addresses of the servers that queries are
+	// forwarded to.
+	Upstreams []string
+
+	// Strategy decides which upstream is tried first.
+	Strategy Strategy
+
+	// Timeout is how long an exchange with an upstream may take
+	// before failing over to the next one (see `ClientConfig`).
+	Timeout time.Duration
+}
+
+// Proxy is a Handler that forwards requests to a set of upstream
+// servers, relaying their responses back with the ID of the request.
+//
+// Upstreams that fail to answer, or that answer with SERVFAIL or
+// REFUSED, are failed over to the next one in the order set by the
+// strategy. Clients get the last response received if all of them fail
+// that way, or SERVFAIL if none answered.
+type Proxy struct {
+	strategy  Strategy
+	upstreams []*upstream
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// upstream is a server that a Proxy forwards requests to.
+type upstream struct {
+	address string
+	client  Client
+
+	mu sync.Mutex
+
+	// latency is the moving average of how long the upstream takes
+	// to answer, zero if it wasn't tried yet.
+	latency time.Duration
+}
+
+// NewProxy creates a Proxy that forwards requests to the upstreams of
+// `cfg`.
+func NewProxy(cfg ProxyConfig) (p *Proxy, err error) {
+	if len(cfg.Upstreams) == 0 {
+		err = errors.Errorf("at least one upstream must be specified")
+		return
+	}
+
+	p = &Proxy{
+		strategy: cfg.Strategy,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, address := range cfg.Upstreams {
+		var u = &upstream{address: address}
+
+		u.client, err = NewClient(ClientConfig{
+			Address: address,
+			Timeout: cfg.Timeout,
+		})
+		if err != nil {
+			err = errors.Wrapf(err,
+				"failed to create client for upstream %s",
+				address)
+			return
+		}
+
+		p.upstreams = append(p.upstreams, u)
+	}
+
+	return
+}
+
+// ServeDNS forwards the request `r` to the upstreams.
+func (p *Proxy) ServeDNS(w ResponseWriter, r *Message) {
+	var res *Message
+
+	for _, u := range p.order() {
+		received, rtt, err := u.client.Exchange(w.Context(), r)
+		u.observe(rtt, err)
+
+		if err != nil {
+			continue
+		}
+
+		res = received
+		if res.RCODE != byte(RCODEServerFailure) && res.RCODE != byte(RCODERefused) {
+			break
+		}
+	}
+
+	if res == nil {
+		res = NewReply(r)
+		res.RCODE = byte(RCODEServerFailure)
+	}
+
+	res.ID = r.ID
+	w.WriteMsg(res)
+}
+
+// order returns the upstreams in the order they should be tried.
+func (p *Proxy) order() (upstreams []*upstream) {
+	upstreams = append(upstreams, p.upstreams...)
+
+	switch p.strategy {
+	case StrategyRandom:
+		p.mu.Lock()
+		p.rand.Shuffle(len(upstreams), func(i, j int) {
+			upstreams[i], upstreams[j] = upstreams[j], upstreams[i]
+		})
+		p.mu.Unlock()
+	case StrategyFastest:
+		latencies := make(map[*upstream]time.Duration, len(upstreams))
+		for _, u := range upstreams {
+			latencies[u] = u.averageLatency()
+		}
+
+		sort.SliceStable(upstreams, func(i, j int) bool {
+			return latencies[upstreams[i]] < latencies[upstreams[j]]
+		})
+	}
+
+	return
+}
+
+// observe updates the average latency of the upstream with the one of
+// an exchange, failed exchanges counting as taking the whole timeout.
+func (u *upstream) observe(rtt time.Duration, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err != nil && rtt < u.client.timeout {
+		rtt = u.client.timeout
+	}
+
+	if u.latency == 0 {
+		u.latency = rtt
+		return
+	}
+
+	u.latency = time.Duration(ewmaWeight*float64(rtt) + (1-ewmaWeight)*float64(u.latency))
+}
+
+func (u *upstream) averageLatency() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.latency
+}
@@ -0,0 +1,255 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startUpstream serves `handler` on the same port over UDP and TCP,
+// returning its address.
+func startUpstream(t *testing.T, handler Handler) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	require.NoError(t, err)
+
+	server := &Server{Handler: handler}
+	go server.ServeUDP(conn)
+	go server.ServeTCP(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return conn.LocalAddr().String()
+}
+
+// deadUpstream returns the address of a socket that never answers.
+func deadUpstream(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String()
+}
+
+// countingHandler counts the requests it gets before passing them on.
+type countingHandler struct {
+	next Handler
+
+	mu    sync.Mutex
+	count int
+}
+
+func (h *countingHandler) ServeDNS(w ResponseWriter, r *Message) {
+	h.mu.Lock()
+	h.count++
+	h.mu.Unlock()
+
+	h.next.ServeDNS(w, r)
+}
+
+func (h *countingHandler) requests() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+func testProxy(t *testing.T, strategy Strategy, upstreams ...string) *Proxy {
+	proxy, err := NewProxy(ProxyConfig{
+		Upstreams: upstreams,
+		Strategy:  strategy,
+		Timeout:   200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	return proxy
+}
+
+func TestProxy(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		upstreams func(t *testing.T) []string
+		rcode     RCODE
+		answer    string
+	}{
+		{
+			desc: "first upstream answers",
+			upstreams: func(t *testing.T) []string {
+				return []string{
+					startUpstream(t, namedHandler("first")),
+					startUpstream(t, namedHandler("second")),
+				}
+			},
+			answer: "first",
+		},
+		{
+			desc: "fails over a dead upstream",
+			upstreams: func(t *testing.T) []string {
+				return []string{
+					deadUpstream(t),
+					startUpstream(t, namedHandler("second")),
+				}
+			},
+			answer: "second",
+		},
+		{
+			desc: "fails over servfail",
+			upstreams: func(t *testing.T) []string {
+				return []string{
+					startUpstream(t, ErrorHandler(RCODEServerFailure)),
+					startUpstream(t, namedHandler("second")),
+				}
+			},
+			answer: "second",
+		},
+		{
+			desc: "relays nxdomain",
+			upstreams: func(t *testing.T) []string {
+				return []string{
+					startUpstream(t, ErrorHandler(RCODENameError)),
+					startUpstream(t, namedHandler("second")),
+				}
+			},
+			rcode: RCODENameError,
+		},
+		{
+			desc: "relays the last failure",
+			upstreams: func(t *testing.T) []string {
+				return []string{
+					startUpstream(t, ErrorHandler(RCODEServerFailure)),
+					startUpstream(t, ErrorHandler(RCODERefused)),
+				}
+			},
+			rcode: RCODERefused,
+		},
+		{
+			desc: "no upstream answers",
+			upstreams: func(t *testing.T) []string {
+				return []string{deadUpstream(t)}
+			},
+			rcode: RCODEServerFailure,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			proxy := testProxy(t, StrategySequential, tc.upstreams(t)...)
+
+			res := HandleMessage(context.Background(), proxy, testQuery(4242, "example.com"))
+			require.NotNil(t, res)
+
+			assert.Equal(t, uint16(4242), res.ID)
+			assert.Equal(t, byte(1), res.QR)
+			assert.Equal(t, byte(tc.rcode), res.RCODE)
+
+			if tc.answer == "" {
+				assert.Empty(t, res.Answers)
+				return
+			}
+
+			require.Len(t, res.Answers, 1)
+			assert.Equal(t, []string{tc.answer}, res.Answers[0].Data.(*TXT).Strings)
+		})
+	}
+}
+
+func TestProxy_overServer(t *testing.T) {
+	var (
+		upstream = startUpstream(t, addressHandler(40))
+		proxy    = testProxy(t, StrategySequential, upstream)
+	)
+
+	udpAddr, tcpAddr, _ := startServer(t, &Server{Handler: proxy})
+
+	// over UDP the answer doesn't fit, so clients are told to retry
+	// over TCP, which the proxy relays in full.
+	res, err := exchange(t, "udp", udpAddr, testQuery(7, "example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(7), res.ID)
+	assert.Equal(t, byte(1), res.TC)
+
+	res, err = exchange(t, "tcp", tcpAddr, testQuery(8, "example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(8), res.ID)
+	assert.Equal(t, byte(0), res.TC)
+	assert.Len(t, res.Answers, 40)
+}
+
+func TestProxy_StrategyRandom(t *testing.T) {
+	var (
+		first  = &countingHandler{next: namedHandler("first")}
+		second = &countingHandler{next: namedHandler("second")}
+		proxy  = testProxy(t, StrategyRandom,
+			startUpstream(t, first),
+			startUpstream(t, second))
+	)
+
+	for ndx := 0; ndx < 40; ndx++ {
+		res := HandleMessage(context.Background(), proxy, testQuery(1, "example.com"))
+		require.Len(t, res.Answers, 1)
+	}
+
+	assert.Equal(t, 40, first.requests()+second.requests())
+	assert.NotZero(t, first.requests())
+	assert.NotZero(t, second.requests())
+}
+
+func TestProxy_StrategyFastest(t *testing.T) {
+	var (
+		slow = &countingHandler{next: HandlerFunc(func(w ResponseWriter, r *Message) {
+			time.Sleep(30 * time.Millisecond)
+			namedHandler("slow").ServeDNS(w, r)
+		})}
+		fast  = &countingHandler{next: namedHandler("fast")}
+		proxy = testProxy(t, StrategyFastest,
+			startUpstream(t, slow),
+			startUpstream(t, fast))
+	)
+
+	// upstreams never tried go first, so each gets a request before
+	// the fastest one takes all the others.
+	for ndx := 0; ndx < 10; ndx++ {
+		HandleMessage(context.Background(), proxy, testQuery(1, "example.com"))
+	}
+
+	assert.Equal(t, 1, slow.requests())
+	assert.Equal(t, 9, fast.requests())
+}
+
+func TestUpstream_observe(t *testing.T) {
+	var u = &upstream{client: Client{timeout: time.Second}}
+
+	u.observe(100*time.Millisecond, nil)
+	assert.Equal(t, 100*time.Millisecond, u.averageLatency())
+
+	u.observe(200*time.Millisecond, nil)
+	assert.Equal(t, 130*time.Millisecond, u.averageLatency())
+
+	u.observe(10*time.Millisecond, assert.AnError)
+	assert.Equal(t, 391*time.Millisecond, u.averageLatency())
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, strategy := range []Strategy{StrategySequential, StrategyRandom, StrategyFastest} {
+		parsed, err := ParseStrategy(strategy.String())
+		require.NoError(t, err)
+		assert.Equal(t, strategy, parsed)
+	}
+
+	_, err := ParseStrategy("roundrobin")
+	assert.Error(t, err)
+}
+
+func TestNewProxy_invalid(t *testing.T) {
+	_, err := NewProxy(ProxyConfig{})
+	assert.Error(t, err)
+
+	_, err = NewProxy(ProxyConfig{Upstreams: []string{"not an address"}})
+	assert.Error(t, err)
+}
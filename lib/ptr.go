@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PTR represents the RDATA of a PTR record (RFC1035 section 3.3.12):
+// a domain name which points to some location in the domain name
+// space.
+type PTR struct {
+	Target string
+}
+
+func unmarshalPTRRData(msg []byte, off, length int) (data RData, err error) {
+	var p = new(PTR)
+
+	_, err = unmarshalPTR(msg, off, off+length, p)
+	if err != nil {
+		return
+	}
+
+	data = p
+	return
+}
+
+// UnmarshalPTR decodes the RDATA of a PTR record into `p`. `msg`
+// must contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalPTR(msg []byte, p *PTR) (n int, err error) {
+	return unmarshalPTR(msg, 0, len(msg), p)
+}
+
+func unmarshalPTR(msg []byte, off, end int, p *PTR) (n int, err error) {
+	if p == nil {
+		err = errors.Errorf("ptr must be non-nil")
+		return
+	}
+
+	p.Target, n, err = unmarshalRDATAName(msg, off, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read target")
+		return
+	}
+
+	err = checkRDATAEnd(off+n, end)
+	return
+}
+
+// Marshal encodes the RDATA of the PTR record.
+func (p PTR) Marshal() (res []byte, err error) {
+	return p.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the PTR record to `b`.
+func (p PTR) AppendTo(b []byte) (res []byte, err error) {
+	return appendRDATAName(b, p.Target)
+}
+
+// String renders the RDATA in presentation format.
+func (p PTR) String() string {
+	return fqdn(p.Target)
+}
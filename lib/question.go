@@ -1,8 +1,9 @@
 package lib
 
 import (
-	"bytes"
 	"encoding/binary"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -67,6 +68,40 @@ const (
 	// Mail exchange
 	QTypeMX
 	QTypeTXT
+
+	// Responsible person
+	QTypeRP
+
+	// AFS database location
+	QTypeAFSDB
+
+	// IPv6 host address
+	QTypeAAAA QType = 28
+
+	// Location information
+	QTypeLOC QType = 29
+
+	// Service location
+	QTypeSRV QType = 33
+
+	// SSH public key fingerprint
+	QTypeSSHFP QType = 44
+
+	// TLS certificate association (DANE)
+	QTypeTLSA QType = 52
+
+	// OpenPGP public key
+	QTypeOPENPGPKEY QType = 61
+
+	// General purpose service binding
+	QTypeSVCB QType = 64
+
+	// Service binding for HTTP origins
+	QTypeHTTPS QType = 65
+
+	// Incremental zone transfer
+	QTypeIXFR QType = 251
+
 	QTypeAXFR  QType = 252
 	QTypeMAILB QType = 253
 	QTypeMAILA QType = 254
@@ -87,74 +122,213 @@ const (
 	QClassCH
 	QClassHS
 
+	// No class, used by dynamic updates to delete records (RFC2136)
+	QClassNone QClass = 254
+
 	// Any class
 	QClassWildcard QClass = 255
 )
 
-func (q Question) Marshal() (res []byte, err error) {
-	var (
-		buf    = new(bytes.Buffer)
-		labels []string
-	)
+var qTypeNames = map[QType]string{
+	QTypeA:          "A",
+	QTypeNS:         "NS",
+	QTypeMD:         "MD",
+	QTypeMF:         "MF",
+	QTypeCNAME:      "CNAME",
+	QTypeSOA:        "SOA",
+	QTypeMB:         "MB",
+	QTypeMG:         "MG",
+	QTypeMR:         "MR",
+	QTypeNULL:       "NULL",
+	QTypeWKS:        "WKS",
+	QTypePTR:        "PTR",
+	QTypeHINFO:      "HINFO",
+	QTypeMINFO:      "MINFO",
+	QTypeMX:         "MX",
+	QTypeTXT:        "TXT",
+	QTypeRP:         "RP",
+	QTypeAFSDB:      "AFSDB",
+	QTypeAAAA:       "AAAA",
+	QTypeLOC:        "LOC",
+	QTypeSRV:        "SRV",
+	QTypeSSHFP:      "SSHFP",
+	QTypeTLSA:       "TLSA",
+	QTypeOPENPGPKEY: "OPENPGPKEY",
+	QTypeSVCB:       "SVCB",
+	QTypeHTTPS:      "HTTPS",
+	QTypeIXFR:       "IXFR",
+	QTypeAXFR:       "AXFR",
+	QTypeMAILB:      "MAILB",
+	QTypeMAILA:      "MAILA",
+	QTypeWildcard:   "ANY",
+}
 
-	labels = strings.Split(q.QNAME, ".")
-	if len(labels) < 2 {
-		err = errors.Errorf(
-			"malformed qname %s",
-			q.QNAME)
+var qClassNames = map[QClass]string{
+	QClassIN:       "IN",
+	QClassCS:       "CS",
+	QClassCH:       "CH",
+	QClassHS:       "HS",
+	QClassNone:     "NONE",
+	QClassWildcard: "ANY",
+}
+
+// String returns the mnemonic of the type, falling back to the
+// generic `TYPENNN` form (RFC3597 section 5) for types without one.
+func (t QType) String() string {
+	if name, ok := qTypeNames[t]; ok {
+		return name
+	}
+
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// String returns the mnemonic of the class, falling back to the
+// generic `CLASSNNN` form (RFC3597 section 5) for classes without one.
+func (c QClass) String() string {
+	if name, ok := qClassNames[c]; ok {
+		return name
+	}
+
+	return "CLASS" + strconv.Itoa(int(c))
+}
+
+// ParseQType parses either the mnemonic of a type (case-insensitive)
+// or its generic `TYPENNN` form.
+func ParseQType(s string) (t QType, err error) {
+	var value uint64
+
+	s = strings.ToUpper(s)
+	for t, name := range qTypeNames {
+		if name == s {
+			return t, nil
+		}
+	}
+
+	if !strings.HasPrefix(s, "TYPE") {
+		err = errors.Errorf("unknown type %s", s)
 		return
 	}
 
-	for _, label := range labels {
-		if len(label) == 0 {
-			err = errors.Errorf("can't have empty label")
-			return
+	value, err = strconv.ParseUint(s[len("TYPE"):], 10, 16)
+	if err != nil {
+		err = errors.Errorf("malformed type %s", s)
+		return
+	}
+
+	t = QType(value)
+	return
+}
+
+// ParseQClass parses either the mnemonic of a class (case-insensitive)
+// or its generic `CLASSNNN` form.
+func ParseQClass(s string) (c QClass, err error) {
+	var value uint64
+
+	s = strings.ToUpper(s)
+	for c, name := range qClassNames {
+		if name == s {
+			return c, nil
 		}
+	}
 
-		buf.WriteByte(uint8(len(label)))
-		buf.Write([]byte(label))
+	if !strings.HasPrefix(s, "CLASS") {
+		err = errors.Errorf("unknown class %s", s)
+		return
 	}
 
-	buf.WriteByte(0)
+	value, err = strconv.ParseUint(s[len("CLASS"):], 10, 16)
+	if err != nil {
+		err = errors.Errorf("malformed class %s", s)
+		return
+	}
+
+	c = QClass(value)
+	return
+}
+
+// String renders the question as in the question section of `dig`'s
+// output, e.g.:
+//
+//	;example.com.		IN	A
+func (q Question) String() string {
+	return fmt.Sprintf(";%s\t\t%s\t%s", fqdn(q.QNAME), q.QCLASS, q.QTYPE)
+}
 
-	binary.Write(buf, binary.BigEndian, q.QTYPE)
-	binary.Write(buf, binary.BigEndian, q.QCLASS)
+// Marshal encodes the question. QNAME may be any name in presentation
+// format, fully qualified or not (e.g., `.`, `com`, `com.` or
+// `www.example.com`), with internationalized labels being encoded as
+// A-labels (see `ParseIDN`).
+func (q Question) Marshal() (res []byte, err error) {
+	return q.AppendTo(nil)
+}
+
+// AppendTo appends the wire format of the question to `b` (see
+// `Marshal`).
+func (q Question) AppendTo(b []byte) (res []byte, err error) {
+	var name Name
 
-	res = buf.Bytes()
+	// the root must be spelled out (`.`) so that a question that
+	// was never filled isn't taken as one for the root.
+	if q.QNAME == "" {
+		err = errors.Errorf(
+			"qname must be specified (`.` for the root)")
+		return
+	}
+
+	if isASCII(q.QNAME) {
+		res, err = appendName(b, q.QNAME)
+	} else {
+		name, err = ParseIDN(q.QNAME)
+		if err == nil {
+			res, err = name.AppendTo(b)
+		}
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed qname %s",
+			q.QNAME)
+		return
+	}
+
+	res = binary.BigEndian.AppendUint16(res, uint16(q.QTYPE))
+	res = binary.BigEndian.AppendUint16(res, uint16(q.QCLASS))
 	return
 }
 
-// 1. pick a number --> indicates how many bytes we can read ahead
-//    --> if ZERO: then we know it comes a QTYPE and a QCLASS
-// 2.
+// UnmarshalQuestion decodes the question that sits at the beginning
+// of `msg`. The case of the name is preserved, so that responses can
+// be checked to echo the one asked byte for byte.
 func UnmarshalQuestion(msg []byte, q *Question) (n int, err error) {
+	return unmarshalQuestion(msg, 0, q)
+}
+
+// unmarshalQuestion decodes the question that starts at the offset
+// `off` of the message `msg`, its name possibly being compressed.
+func unmarshalQuestion(msg []byte, off int, q *Question) (n int, err error) {
 	if q == nil {
 		err = errors.Errorf("question must be non-nil")
 		return
 	}
 
-	var (
-		ndx    int = 0
-		size   int = 0
-		labels     = []string{}
-	)
-
-	for {
-		size = int(msg[ndx])
-		if size == 0 {
-			ndx += 1
-			break
-		}
-
-		labels = append(labels, string(msg[ndx+1:ndx+size+1]))
-		ndx += (size + 1)
+	n, err = skipName(msg, off)
+	if err != nil {
+		err = parseErrorf(SectionQuestion, off,
+			"malformed qname: %s",
+			err)
+		return
 	}
 
-	q.QNAME = strings.Join(labels, ".")
-	q.QTYPE = QType(uint16(msg[ndx+1]) | uint16(msg[ndx]<<8))
-	q.QCLASS = QClass(uint16(msg[ndx+3]) | uint16(msg[ndx+2]<<8))
+	if len(msg)-off-n < 4 {
+		err = parseErrorf(SectionQuestion, off,
+			"question goes past the end of the message")
+		return
+	}
 
-	n = ndx + 3 + 1
+	q.QNAME = RawName{msg: msg, off: off}.Name().text()
+	q.QTYPE = QType(binary.BigEndian.Uint16(msg[off+n : off+n+2]))
+	q.QCLASS = QClass(binary.BigEndian.Uint16(msg[off+n+2 : off+n+4]))
 
+	n += 4
 	return
 }
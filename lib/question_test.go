@@ -11,6 +11,7 @@ func TestQuestionMarshallingAndUnmarshalling(t *testing.T) {
 	var testCases = []struct {
 		desc       string
 		entity     *Question
+		qname      string
 		shouldFail bool
 	}{
 		{
@@ -33,6 +34,57 @@ func TestQuestionMarshallingAndUnmarshalling(t *testing.T) {
 				QCLASS: QClassIN,
 			},
 		},
+		{
+			desc: "root",
+			entity: &Question{
+				QNAME:  ".",
+				QTYPE:  QTypeNS,
+				QCLASS: QClassIN,
+			},
+		},
+		{
+			desc: "single label",
+			entity: &Question{
+				QNAME:  "com",
+				QTYPE:  QTypeNS,
+				QCLASS: QClassIN,
+			},
+		},
+		{
+			desc: "fully qualified single label",
+			entity: &Question{
+				QNAME:  "com.",
+				QTYPE:  QTypeNS,
+				QCLASS: QClassIN,
+			},
+			qname: "com",
+		},
+		{
+			desc: "fully qualified",
+			entity: &Question{
+				QNAME:  "localhost.localdomain.",
+				QTYPE:  QTypeA,
+				QCLASS: QClassIN,
+			},
+			qname: "localhost.localdomain",
+		},
+		{
+			desc: "internationalized",
+			entity: &Question{
+				QNAME:  "bücher.example",
+				QTYPE:  QTypeA,
+				QCLASS: QClassIN,
+			},
+			qname: "xn--bcher-kva.example",
+		},
+		{
+			desc: "mixed case",
+			entity: &Question{
+				QNAME:  "wWw.ExAmPlE.CoM",
+				QTYPE:  QTypeA,
+				QCLASS: QClassIN,
+			},
+		},
 	}
 
 	var (
@@ -55,9 +107,37 @@ func TestQuestionMarshallingAndUnmarshalling(t *testing.T) {
 			_, err = UnmarshalQuestion(msg, unmarshalled)
 			require.NoError(t, err)
 
-			assert.Equal(t, tc.entity.QNAME, unmarshalled.QNAME)
+			if tc.qname == "" {
+				tc.qname = tc.entity.QNAME
+			}
+
+			assert.Equal(t, tc.qname, unmarshalled.QNAME)
 			assert.Equal(t, tc.entity.QCLASS, unmarshalled.QCLASS)
 			assert.Equal(t, tc.entity.QTYPE, unmarshalled.QTYPE)
 		})
 	}
 }
+
+func TestQuestionMarshal_wireFormat(t *testing.T) {
+	var testCases = []struct {
+		qname    string
+		expected []byte
+	}{
+		{".", []byte{0, 0, 2, 0, 1}},
+		{"com", []byte{3, 'c', 'o', 'm', 0, 0, 2, 0, 1}},
+		{"com.", []byte{3, 'c', 'o', 'm', 0, 0, 2, 0, 1}},
+		{"CoM", []byte{3, 'C', 'o', 'M', 0, 0, 2, 0, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.qname, func(t *testing.T) {
+			msg, err := Question{
+				QNAME:  tc.qname,
+				QTYPE:  QTypeNS,
+				QCLASS: QClassIN,
+			}.Marshal()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, msg)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// maxCharacterStringLength is the maximum number of octets that a
+// <character-string> can carry, given its single length octet.
+const maxCharacterStringLength = 255
+
+// RData is implemented by the typed representations of the RDATA
+// field of resource records.
+//
+// When decoding a message, the RDATA of the record types that have
+// a typed representation is decoded and made available at `RR.Data`.
+// Records of types that the library doesn't know get their RDATA
+// preserved as an *UnknownRDATA.
+type RData interface {
+
+	// Marshal encodes the RDATA in its wire format.
+	// Names are never compressed.
+	Marshal() ([]byte, error)
+
+	// String renders the RDATA in presentation (master file)
+	// format.
+	String() string
+}
+
+// rdataAppender is implemented by the RDATA types that can append
+// their wire format to a buffer without allocating (see
+// `RR.AppendTo`).
+type rdataAppender interface {
+	AppendTo(b []byte) ([]byte, error)
+}
+
+// rdataUnmarshaler decodes the `length` bytes of RDATA found at the
+// offset `off` of the message `msg`.
+//
+// The whole message is provided so that names compressed with
+// pointers can be expanded.
+type rdataUnmarshaler func(msg []byte, off, length int) (RData, error)
+
+// rdataUnmarshalers maps the record types that have a typed RDATA
+// representation to the functions that decode them.
+var rdataUnmarshalers = map[QType]rdataUnmarshaler{
+	QTypeA:          unmarshalARData,
+	QTypeNS:         unmarshalNSRData,
+	QTypeMD:         unmarshalNameRData,
+	QTypeMF:         unmarshalNameRData,
+	QTypeCNAME:      unmarshalCNAMERData,
+	QTypeSOA:        unmarshalSOARData,
+	QTypeMB:         unmarshalNameRData,
+	QTypeMG:         unmarshalNameRData,
+	QTypeMR:         unmarshalNameRData,
+	QTypePTR:        unmarshalPTRRData,
+	QTypeHINFO:      unmarshalHINFORData,
+	QTypeMINFO:      unmarshalMINFORData,
+	QTypeMX:         unmarshalMXRData,
+	QTypeTXT:        unmarshalTXTRData,
+	QTypeRP:         unmarshalRPRData,
+	QTypeAFSDB:      unmarshalAFSDBRData,
+	QTypeAAAA:       unmarshalAAAARData,
+	QTypeLOC:        unmarshalLOCRData,
+	QTypeSRV:        unmarshalSRVRData,
+	QTypeSSHFP:      unmarshalSSHFPRData,
+	QTypeTLSA:       unmarshalTLSARData,
+	QTypeOPENPGPKEY: unmarshalOPENPGPKEYRData,
+	QTypeSVCB:       unmarshalSVCBRData,
+	QTypeHTTPS:      unmarshalSVCBRData,
+}
+
+// unmarshalRDATA decodes the RDATA of a record of type `t`.
+//
+// Types without a typed representation are decoded as *UnknownRDATA.
+// All of the RFC1035 types that may carry compressed names have one,
+// so that their names are always expanded.
+func unmarshalRDATA(t QType, msg []byte, off, length int) (data RData, err error) {
+	unmarshaler, ok := rdataUnmarshalers[t]
+	if !ok {
+		unmarshaler = unmarshalUnknownRData
+	}
+
+	if off+length > len(msg) {
+		err = errors.Errorf(
+			"rdata at offset %d goes past the end of the message",
+			off)
+		return
+	}
+
+	data, err = unmarshaler(msg, off, length)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to decode rdata of type %d",
+			t)
+		return
+	}
+
+	return
+}
+
+// unmarshalRDATAName reads the possibly compressed name at the offset
+// `off` of the message, making sure that it doesn't go past `end`,
+// the end of the RDATA it belongs to.
+func unmarshalRDATAName(msg []byte, off, end int) (name string, n int, err error) {
+	if off >= end {
+		err = errors.Errorf(
+			"missing name at offset %d",
+			off)
+		return
+	}
+
+	name, n, err = unmarshalName(msg[:end], off)
+	return
+}
+
+// unmarshalNamePair reads the two possibly compressed names that make
+// up the whole RDATA of records like RP and MINFO, going from `off` to
+// `end`. `firstDesc` and `secondDesc` describe the names in errors.
+func unmarshalNamePair(msg []byte, off, end int, first, second *string, firstDesc, secondDesc string) (n int, err error) {
+	var ndx int = off
+
+	*first, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read %s",
+			firstDesc)
+		return
+	}
+
+	ndx += n
+
+	*second, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read %s",
+			secondDesc)
+		return
+	}
+
+	ndx += n
+
+	err = checkRDATAEnd(ndx, end)
+	if err != nil {
+		return
+	}
+
+	n = ndx - off
+	return
+}
+
+// unmarshalCharacterString reads the <character-string> (a length
+// octet followed by that many octets) at the offset `off` of the
+// message, making sure that it doesn't go past `end`.
+func unmarshalCharacterString(msg []byte, off, end int) (s []byte, n int, err error) {
+	if off >= end {
+		err = errors.Errorf(
+			"missing character-string at offset %d",
+			off)
+		return
+	}
+
+	n = int(msg[off]) + 1
+	if off+n > end {
+		err = errors.Errorf(
+			"character-string at offset %d goes past the end of the rdata",
+			off)
+		return
+	}
+
+	s = msg[off+1 : off+n]
+	return
+}
+
+// appendRDATAName appends the uncompressed wire format of `name` to
+// `b`.
+func appendRDATAName(b []byte, name string) (res []byte, err error) {
+	res, err = appendName(b, name)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed name %s",
+			name)
+	}
+
+	return
+}
+
+// appendCharacterString appends `s` as a <character-string> to `b`.
+func appendCharacterString(b []byte, s string) (res []byte, err error) {
+	if len(s) > maxCharacterStringLength {
+		err = errors.Errorf(
+			"character-string exceeds %d octets - %d",
+			maxCharacterStringLength, len(s))
+		return
+	}
+
+	res = append(b, uint8(len(s)))
+	res = append(res, s...)
+	return
+}
+
+// checkRDATAEnd makes sure that decoding the RDATA that finishes at
+// `end` consumed all of it.
+func checkRDATAEnd(ndx, end int) (err error) {
+	if ndx != end {
+		err = errors.Errorf(
+			"%d trailing bytes after rdata",
+			end-ndx)
+	}
+
+	return
+}
+
+// canonicalRData returns a copy of `data` in the canonical form of
+// RFC4034 section 6.2: the domain names embedded in the RDATA of the
+// types listed there are lowercased. Other types are returned as is.
+func canonicalRData(data RData) RData {
+	switch data := data.(type) {
+	case *NS:
+		return &NS{Host: canonicalName(data.Host)}
+	case *CNAME:
+		return &CNAME{Target: canonicalName(data.Target)}
+	case *PTR:
+		return &PTR{Target: canonicalName(data.Target)}
+	case *NameRDATA:
+		return &NameRDATA{Name: canonicalName(data.Name)}
+	case *SOA:
+		soa := *data
+		soa.MName, soa.RName = canonicalName(soa.MName), canonicalName(soa.RName)
+		return &soa
+	case *MINFO:
+		return &MINFO{
+			RMailbx: canonicalName(data.RMailbx),
+			EMailbx: canonicalName(data.EMailbx),
+		}
+	case *MX:
+		return &MX{Preference: data.Preference, Exchange: canonicalName(data.Exchange)}
+	case *RP:
+		return &RP{Mbox: canonicalName(data.Mbox), Txt: canonicalName(data.Txt)}
+	case *AFSDB:
+		return &AFSDB{Subtype: data.Subtype, Hostname: canonicalName(data.Hostname)}
+	case *SRV:
+		srv := *data
+		srv.Target = canonicalName(srv.Target)
+		return &srv
+	}
+
+	return data
+}
@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRDataMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		qtype      QType
+		entity     RData
+		presented  string
+		shouldFail bool
+	}{
+		{
+			desc:      "a",
+			qtype:     QTypeA,
+			entity:    &A{Address: net.ParseIP("93.184.216.34").To4()},
+			presented: "93.184.216.34",
+		},
+		{
+			desc:       "a with ipv6 address",
+			qtype:      QTypeA,
+			entity:     &A{Address: net.ParseIP("2001:db8::1")},
+			shouldFail: true,
+		},
+		{
+			desc:      "aaaa",
+			qtype:     QTypeAAAA,
+			entity:    &AAAA{Address: net.ParseIP("2001:db8::1")},
+			presented: "2001:db8::1",
+		},
+		{
+			desc:      "ns",
+			qtype:     QTypeNS,
+			entity:    &NS{Host: "a.iana-servers.net"},
+			presented: "a.iana-servers.net.",
+		},
+		{
+			desc:      "cname",
+			qtype:     QTypeCNAME,
+			entity:    &CNAME{Target: "www.example.com"},
+			presented: "www.example.com.",
+		},
+		{
+			desc:      "ptr",
+			qtype:     QTypePTR,
+			entity:    &PTR{Target: "host.example.com"},
+			presented: "host.example.com.",
+		},
+		{
+			desc:  "soa",
+			qtype: QTypeSOA,
+			entity: &SOA{
+				MName:   "ns.example.com",
+				RName:   "hostmaster.example.com",
+				Serial:  2018010101,
+				Refresh: 7200,
+				Retry:   3600,
+				Expire:  1209600,
+				Minimum: 300,
+			},
+			presented: "ns.example.com. hostmaster.example.com. 2018010101 7200 3600 1209600 300",
+		},
+		{
+			desc:      "mx",
+			qtype:     QTypeMX,
+			entity:    &MX{Preference: 10, Exchange: "mail.example.com"},
+			presented: "10 mail.example.com.",
+		},
+		{
+			desc:      "txt",
+			qtype:     QTypeTXT,
+			entity:    &TXT{Strings: []string{"v=spf1 -all", `say "hi"`, "\x00"}},
+			presented: `"v=spf1 -all" "say \"hi\"" "\000"`,
+		},
+		{
+			desc:       "txt without strings",
+			qtype:      QTypeTXT,
+			entity:     &TXT{},
+			shouldFail: true,
+		},
+		{
+			desc:      "srv",
+			qtype:     QTypeSRV,
+			entity:    &SRV{Priority: 10, Weight: 60, Port: 5060, Target: "bigbox.example.com"},
+			presented: "10 60 5060 bigbox.example.com.",
+		},
+		{
+			desc:  "tlsa",
+			qtype: QTypeTLSA,
+			entity: &TLSA{
+				Usage:                  TLSAUsageDANEEE,
+				Selector:               TLSASelectorSPKI,
+				MatchingType:           TLSAMatchingTypeSHA256,
+				CertificateAssociation: []byte{0x0d, 0x6f, 0xce},
+			},
+			presented: "3 1 1 0D6FCE",
+		},
+		{
+			desc:  "sshfp",
+			qtype: QTypeSSHFP,
+			entity: &SSHFP{
+				Algorithm:   SSHFPAlgorithmEd25519,
+				Type:        SSHFPTypeSHA256,
+				Fingerprint: []byte{0xca, 0xfe},
+			},
+			presented: "4 2 CAFE",
+		},
+		{
+			desc:      "openpgpkey",
+			qtype:     QTypeOPENPGPKEY,
+			entity:    &OPENPGPKEY{PublicKey: []byte{0x99, 0x01, 0x0d, 0x04}},
+			presented: "mQENBA==",
+		},
+		{
+			desc:  "https",
+			qtype: QTypeHTTPS,
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params:   []SVCParam{NewSVCParamALPN("h2")},
+			},
+			presented: `1 . alpn="h2"`,
+		},
+		{
+			desc:      "escaped names",
+			qtype:     QTypeCNAME,
+			entity:    &CNAME{Target: `a\032b.\(c\)\;\001`},
+			presented: `a\032b.\(c\)\;\001.`,
+		},
+		{
+			desc:      "dots within labels",
+			qtype:     QTypeCNAME,
+			entity:    &CNAME{Target: `first\.last.example.com`},
+			presented: `first\.last.example.com.`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err := tc.entity.Marshal()
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			unmarshalled, err := unmarshalRDATA(tc.qtype, msg, 0, len(msg))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+
+			// the rdata must not keep referencing the message,
+			// whose buffer callers may reuse.
+			for ndx := range msg {
+				msg[ndx] = 0xff
+			}
+
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
@@ -0,0 +1,715 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultResolverTimeout is how long the resolver waits for each
+	// server it queries by default.
+	defaultResolverTimeout = 2 * time.Second
+
+	// defaultResolverDepth is how many lookups may nest by default.
+	defaultResolverDepth = 8
+
+	// defaultResolverQueries is how many queries a resolution may
+	// send by default.
+	defaultResolverQueries = 100
+
+	// maxMinimisedQueries is how many queries with minimised names a
+	// lookup may send before asking for the full name, protecting
+	// against names with many labels (RFC9156 section 2.3).
+	maxMinimisedQueries = 10
+)
+
+// rootHints holds the name servers of the root zone and their
+// addresses (https://www.internic.net/domain/named.root).
+const rootHints = `
+.			3600000	NS	a.root-servers.net.
+.			3600000	NS	b.root-servers.net.
+.			3600000	NS	c.root-servers.net.
+.			3600000	NS	d.root-servers.net.
+.			3600000	NS	e.root-servers.net.
+.			3600000	NS	f.root-servers.net.
+.			3600000	NS	g.root-servers.net.
+.			3600000	NS	h.root-servers.net.
+.			3600000	NS	i.root-servers.net.
+.			3600000	NS	j.root-servers.net.
+.			3600000	NS	k.root-servers.net.
+.			3600000	NS	l.root-servers.net.
+.			3600000	NS	m.root-servers.net.
+a.root-servers.net.	3600000	A	198.41.0.4
+a.root-servers.net.	3600000	AAAA	2001:503:ba3e::2:30
+b.root-servers.net.	3600000	A	170.247.170.2
+b.root-servers.net.	3600000	AAAA	2801:1b8:10::b
+c.root-servers.net.	3600000	A	192.33.4.12
+c.root-servers.net.	3600000	AAAA	2001:500:2::c
+d.root-servers.net.	3600000	A	199.7.91.13
+d.root-servers.net.	3600000	AAAA	2001:500:2d::d
+e.root-servers.net.	3600000	A	192.203.230.10
+e.root-servers.net.	3600000	AAAA	2001:500:a8::e
+f.root-servers.net.	3600000	A	192.5.5.241
+f.root-servers.net.	3600000	AAAA	2001:500:2f::f
+g.root-servers.net.	3600000	A	192.112.36.4
+g.root-servers.net.	3600000	AAAA	2001:500:12::d0d
+h.root-servers.net.	3600000	A	198.97.190.53
+h.root-servers.net.	3600000	AAAA	2001:500:1::53
+i.root-servers.net.	3600000	A	192.36.148.17
+i.root-servers.net.	3600000	AAAA	2001:7fe::53
+j.root-servers.net.	3600000	A	192.58.128.30
+j.root-servers.net.	3600000	AAAA	2001:503:c27::2:30
+k.root-servers.net.	3600000	A	193.0.14.129
+k.root-servers.net.	3600000	AAAA	2001:7fd::1
+l.root-servers.net.	3600000	A	199.7.83.42
+l.root-servers.net.	3600000	AAAA	2001:500:9f::42
+m.root-servers.net.	3600000	A	202.12.27.33
+m.root-servers.net.	3600000	AAAA	2001:dc3::35
+`
+
+// RootHints returns the records of the built-in root hints: the NS
+// records of the root zone and the addresses of the servers they
+// point to.
+func RootHints() []*RR {
+	rrs, err := ParseZone(strings.NewReader(rootHints), ".", "root hints")
+	if err != nil {
+		panic(err)
+	}
+
+	return rrs
+}
+
+// ResolverConfig configures a Resolver.
+type ResolverConfig struct {
+
+	// Hints are the NS records of the root zone along with the
+	// addresses of the servers they point to (e.g., read from a
+	// `named.root` file with `ParseZoneFile`), the built-in root
+	// hints if empty.
+	Hints []*RR
+
+	// Port is the port that servers are queried at, 53 if empty.
+	Port string
+
+	// Timeout is how long each server may take to answer before the
+	// next one is tried, 2 seconds if zero.
+	Timeout time.Duration
+
+	// MaxDepth is how many lookups may nest, e.g., to find the
+	// addresses of name servers that come without glue, 8 if zero.
+	MaxDepth int
+
+	// MaxQueries is how many queries a resolution may send in total,
+	// 100 if zero.
+	MaxQueries int
+
+	// DisableMinimisation sends the full name asked to every server,
+	// instead of revealing to each server one label more than the
+	// zone it's a server of (QNAME minimisation, RFC9156).
+	DisableMinimisation bool
+
+	// Trace, if set, is called with each exchange with a server as
+	// it completes, from the goroutine resolving.
+	Trace func(step ResolverStep)
+}
+
+// ResolverStep is an exchange of a resolution with a server.
+type ResolverStep struct {
+
+	// Zone is the zone the server was asked as a server of.
+	Zone string
+
+	// Server is the name of the server and Address the address it
+	// was asked at.
+	Server  string
+	Address string
+
+	// Question is what the server was asked.
+	Question Question
+
+	// Depth is how many lookups the one of the exchange is nested
+	// in, e.g., 1 for the ones finding the address of a name server
+	// that came without glue.
+	Depth int
+
+	// Response is the response of the server, which took RTT to
+	// arrive, or nil if the exchange failed with Err.
+	Response *Message
+	RTT      time.Duration
+	Err      error
+}
+
+// Resolver resolves names iteratively (RFC1034 section 5.3.3): it
+// starts from the servers of the root zone and follows the referrals
+// they give down to the servers that are authoritative for the name,
+// asking each of them without recursion.
+//
+// Servers are only told one label more than the zone they're servers
+// of (RFC9156): they're asked for the NS records of that name (or its A
+// records, if they fail to answer) to find out whether it's a zone cut,
+// with the full name only asked to the servers of the zone it's in.
+//
+// Addresses that come along with referrals (glue) are only trusted
+// for names within the zone of the server that sent them, with the
+// addresses of other name servers resolved from the root as well.
+// Aliases are followed across zones. It's safe for concurrent use.
+type Resolver struct {
+	servers    []*nameserver
+	port       string
+	timeout    time.Duration
+	maxDepth   int
+	maxQueries int
+	minimise   bool
+	trace      func(step ResolverStep)
+}
+
+// nameserver is a server that a zone is delegated to, along with its
+// addresses, if known.
+type nameserver struct {
+	host  Name
+	addrs []string
+}
+
+// resolution holds the state shared by the lookups of a resolution.
+type resolution struct {
+	queries int
+}
+
+// NewResolver creates a resolver that starts from the hints of `cfg`.
+func NewResolver(cfg ResolverConfig) (r *Resolver, err error) {
+	r = &Resolver{
+		port:       cfg.Port,
+		timeout:    cfg.Timeout,
+		maxDepth:   cfg.MaxDepth,
+		maxQueries: cfg.MaxQueries,
+		minimise:   !cfg.DisableMinimisation,
+		trace:      cfg.Trace,
+	}
+
+	if r.port == "" {
+		r.port = "53"
+	}
+
+	if r.timeout == 0 {
+		r.timeout = defaultResolverTimeout
+	}
+
+	if r.maxDepth == 0 {
+		r.maxDepth = defaultResolverDepth
+	}
+
+	if r.maxQueries == 0 {
+		r.maxQueries = defaultResolverQueries
+	}
+
+	var hints = cfg.Hints
+	if len(hints) == 0 {
+		hints = RootHints()
+	}
+
+	r.servers = delegation(hints, hints, Name{}, Name{})
+	if len(r.servers) == 0 {
+		err = errors.Errorf("hints have no name servers for the root zone")
+		return
+	}
+
+	for _, ns := range r.servers {
+		if len(ns.addrs) > 0 {
+			return
+		}
+	}
+
+	err = errors.Errorf("hints have no addresses for the root name servers")
+	return
+}
+
+// Resolve looks up the records of type `qtype` of the name `name`,
+// returning the response of the server authoritative for it with the
+// aliases that lead to them prepended to its answers, and with `name`
+// as its question.
+//
+// Negative answers (NXDOMAIN and NODATA) come as responses as well,
+// while errors mean that no server could give an answer.
+func (r *Resolver) Resolve(ctx context.Context, name string, qtype QType) (res *Message, err error) {
+	qname, err := ParseIDN(name)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"invalid name %s",
+			name)
+		return
+	}
+
+	return r.resolve(ctx, &resolution{}, qname, qtype, 0)
+}
+
+// resolve looks up the records of type `qtype` of `qname`, following
+// aliases.
+func (r *Resolver) resolve(ctx context.Context, state *resolution, qname Name, qtype QType, depth int) (res *Message, err error) {
+	var (
+		name    = qname
+		answers []*RR
+		visited = make(map[string]bool)
+	)
+
+	if depth > r.maxDepth {
+		err = errors.Errorf(
+			"maximum depth of %d lookups reached resolving %s",
+			r.maxDepth, qname)
+		return
+	}
+
+	for {
+		var (
+			key  = name.Canonical().text()
+			zone Name
+		)
+
+		if visited[key] || len(visited) > maxCNAMEChain {
+			err = errors.Errorf(
+				"aliases of %s loop or are too long",
+				qname)
+			return
+		}
+		visited[key] = true
+
+		res, zone, err = r.lookup(ctx, state, name, qtype, depth)
+		if err != nil {
+			return
+		}
+
+		chain, target, done := followAnswers(res, zone, name, qtype)
+		answers = append(answers, chain...)
+
+		if done {
+			break
+		}
+
+		name = target
+	}
+
+	res.Answers = answers
+	res.ANCOUNT = uint16(len(answers))
+	res.Questions = []*Question{{QNAME: qname.text(), QTYPE: qtype, QCLASS: QClassIN}}
+	res.QDCOUNT = 1
+
+	return
+}
+
+// lookup follows referrals from the root down to the servers of the
+// zone of `name`, returning their response and the zone.
+func (r *Resolver) lookup(ctx context.Context, state *resolution, name Name, qtype QType, depth int) (res *Message, zone Name, err error) {
+	var (
+		servers   = r.rootServers()
+		minimise  = r.minimise
+		minimised int
+
+		// revealed is the name up to which the servers of the
+		// zone were told about.
+		revealed Name
+	)
+
+	for {
+		if child, ok := minimisedName(name, revealed); minimise && ok && minimised < maxMinimisedQueries {
+			var (
+				cut  Name
+				next []*nameserver
+			)
+
+			minimised++
+
+			cut, next, minimise, err = r.reveal(ctx, state, zone, servers, child, depth)
+			if err != nil {
+				err = errors.Wrapf(err,
+					"failed to query the servers of %s for %s",
+					zone, child)
+				return
+			}
+
+			if next != nil {
+				zone, servers, revealed = cut, next, cut
+			} else if minimise {
+				revealed = child
+			}
+
+			continue
+		}
+
+		res, err = r.query(ctx, state, zone, servers, name, qtype, depth)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"failed to query the servers of %s for %s",
+				zone, name)
+			return
+		}
+
+		if res.RCODE == byte(RCODENameError) || len(res.Answers) > 0 {
+			return
+		}
+
+		cut, next := referral(res, zone, name)
+		if next == nil {
+			if negativeSOAOf(res, name) == nil {
+				err = errors.Errorf(
+					"servers of %s gave no answer nor referral for %s",
+					zone, name)
+			}
+
+			return
+		}
+
+		zone, servers, revealed = cut, next, cut
+	}
+}
+
+// minimisedName returns the name that's one label longer than
+// `revealed` on the way to `name`, unless that's `name` itself.
+func minimisedName(name, revealed Name) (child Name, ok bool) {
+	var labels = len(revealed.labels) + 1
+
+	if len(name.labels) <= labels {
+		return
+	}
+
+	return Name{labels: name.labels[len(name.labels)-labels:]}, true
+}
+
+// reveal asks the servers of `zone` whether `child`, which is a label
+// longer than what they were told about, is a zone cut, returning the
+// zone and its servers if so. Answers that say the name exists, or
+// that it exists as an empty non-terminal (NODATA), mean it isn't.
+//
+// Minimising is to stop (`minimise` being false) when the answer gives
+// no clue, e.g., the name is an alias or doesn't exist. Some servers
+// answer NXDOMAIN for empty non-terminals (RFC9156 section 2.3), so the
+// full name is asked anyway.
+func (r *Resolver) reveal(ctx context.Context, state *resolution, zone Name, servers []*nameserver, child Name, depth int) (cut Name, next []*nameserver, minimise bool, err error) {
+	var res *Message
+
+	res, err = r.query(ctx, state, zone, servers, child, QTypeNS, depth)
+	if err != nil {
+		if state.queries >= r.maxQueries || ctx.Err() != nil {
+			return
+		}
+
+		// some servers fail to answer queries for NS records
+		// below the apex.
+		res, err = r.query(ctx, state, zone, servers, child, QTypeA, depth)
+		if err != nil {
+			return
+		}
+	}
+
+	if res.RCODE == byte(RCODENameError) {
+		return
+	}
+
+	cut, next = referral(res, zone, child)
+	if next != nil {
+		return cut, next, true, nil
+	}
+
+	// servers of both the zone and the child one answer with the
+	// NS records of the child.
+	next = delegation(res.Answers, res.Additionals, child, zone)
+	if len(next) > 0 {
+		return child, next, true, nil
+	}
+
+	for _, rr := range res.Answers {
+		if rr.TYPE == QTypeCNAME {
+			return
+		}
+	}
+
+	if len(res.Answers) == 0 && negativeSOAOf(res, child) == nil {
+		return
+	}
+
+	return cut, nil, true, nil
+}
+
+// rootServers returns a copy of the servers of the root zone, so that
+// lookups can fill in addresses without racing.
+func (r *Resolver) rootServers() (servers []*nameserver) {
+	for _, ns := range r.servers {
+		copied := *ns
+		servers = append(servers, &copied)
+	}
+
+	return
+}
+
+// query asks `servers` of `zone` for the records of type `qtype` of `name`,
+// trying the ones with known addresses first, and returns the first
+// response that's an answer (NOERROR or NXDOMAIN). The addresses of
+// the others are resolved as needed.
+func (r *Resolver) query(ctx context.Context, state *resolution, zone Name, servers []*nameserver, name Name, qtype QType, depth int) (res *Message, err error) {
+	sort.SliceStable(servers, func(i, j int) bool {
+		return len(servers[i].addrs) > 0 && len(servers[j].addrs) == 0
+	})
+
+	err = errors.Errorf("no servers")
+
+	for _, ns := range servers {
+		if len(ns.addrs) == 0 {
+			ns.addrs, err = r.addressesOf(ctx, state, ns.host, depth+1)
+			if err != nil {
+				if state.queries >= r.maxQueries || ctx.Err() != nil {
+					return
+				}
+
+				continue
+			}
+		}
+
+		for _, addr := range ns.addrs {
+			res, err = r.exchange(ctx, state, ResolverStep{
+				Zone:     zone.String(),
+				Server:   ns.host.String(),
+				Address:  addr,
+				Question: Question{QNAME: name.text(), QTYPE: qtype, QCLASS: QClassIN},
+				Depth:    depth,
+			})
+			if err != nil {
+				if state.queries >= r.maxQueries || ctx.Err() != nil {
+					return
+				}
+
+				continue
+			}
+
+			if res.RCODE == byte(RCODENoError) || res.RCODE == byte(RCODENameError) {
+				return
+			}
+
+			err = errors.Errorf(
+				"server %s (%s) answered %s",
+				ns.host, addr, RCODE(res.RCODE))
+		}
+	}
+
+	return
+}
+
+// addressesOf resolves the addresses of the name server `host`,
+// preferring IPv4 ones.
+func (r *Resolver) addressesOf(ctx context.Context, state *resolution, host Name, depth int) (addrs []string, err error) {
+	for _, qtype := range []QType{QTypeA, QTypeAAAA} {
+		var res *Message
+
+		res, err = r.resolve(ctx, state, host, qtype, depth)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"failed to resolve name server %s",
+				host)
+			return
+		}
+
+		addrs = addressesIn(res.Answers, host, res.Answers)
+		if len(addrs) > 0 {
+			return
+		}
+	}
+
+	err = errors.Errorf("name server %s has no addresses", host)
+	return
+}
+
+// exchange asks the server of `step` its question, tracing the
+// exchange.
+func (r *Resolver) exchange(ctx context.Context, state *resolution, step ResolverStep) (res *Message, err error) {
+	var question = step.Question
+
+	if state.queries >= r.maxQueries {
+		err = errors.Errorf(
+			"maximum of %d queries reached",
+			r.maxQueries)
+		return
+	}
+	state.queries++
+
+	// every query goes out with an ID of its own picked at random
+	// (see `Client.Exchange`), even if through a new client.
+	client, err := NewClient(ClientConfig{
+		Address: net.JoinHostPort(step.Address, r.port),
+		Timeout: r.timeout,
+	})
+	if err != nil {
+		return
+	}
+
+	res, step.RTT, err = client.Exchange(ctx, &Message{
+		Header:    Header{Opcode: OpcodeQuery},
+		Questions: []*Question{&question},
+	})
+
+	if r.trace != nil {
+		step.Response, step.Err = res, err
+		r.trace(step)
+	}
+
+	return
+}
+
+// referral returns the zone that the response `res` from a server of
+// `zone` delegates `name` to, along with its name servers. Only
+// delegations to zones below `zone` that `name` is in are followed,
+// and only the glue within `zone` is used.
+func referral(res *Message, zone, name Name) (cut Name, servers []*nameserver) {
+	for _, rr := range res.Authorities {
+		if rr.TYPE != QTypeNS {
+			continue
+		}
+
+		owner, err := ParseName(rr.NAME)
+		if err != nil || owner.Equal(zone) ||
+			!owner.IsSubdomainOf(zone) || !name.IsSubdomainOf(owner) {
+			continue
+		}
+
+		cut = owner
+		break
+	}
+
+	if cut.IsRoot() {
+		return
+	}
+
+	return cut, delegation(res.Authorities, res.Additionals, cut, zone)
+}
+
+// delegation returns the name servers that the NS records of `cut` in
+// `rrs` point to, with the addresses in `glue` of the ones within
+// `bailiwick`.
+func delegation(rrs, glue []*RR, cut, bailiwick Name) (servers []*nameserver) {
+	for _, rr := range rrs {
+		data, ok := rr.Data.(*NS)
+		if !ok {
+			continue
+		}
+
+		owner, err := ParseName(rr.NAME)
+		if err != nil || !owner.Equal(cut) {
+			continue
+		}
+
+		host, err := ParseName(data.Host)
+		if err != nil {
+			continue
+		}
+
+		var ns = &nameserver{host: host}
+		if host.IsSubdomainOf(bailiwick) {
+			ns.addrs = addressesIn(glue, host, nil)
+		}
+
+		servers = append(servers, ns)
+	}
+
+	return
+}
+
+// addressesIn returns the addresses of `host` in `rrs`, IPv4 ones
+// first. Addresses of the names that `aliases` (CNAMEs) lead to from
+// `host` count as its own.
+func addressesIn(rrs []*RR, host Name, aliases []*RR) (addrs []string) {
+	var (
+		names = map[string]bool{host.Canonical().text(): true}
+		ipv6  []string
+	)
+
+	for _, rr := range aliases {
+		data, ok := rr.Data.(*CNAME)
+		if !ok {
+			continue
+		}
+
+		target, err := ParseName(data.Target)
+		if err == nil {
+			names[target.Canonical().text()] = true
+		}
+	}
+
+	for _, rr := range rrs {
+		owner, err := ParseName(rr.NAME)
+		if err != nil || !names[owner.Canonical().text()] {
+			continue
+		}
+
+		switch data := rr.Data.(type) {
+		case *A:
+			addrs = append(addrs, data.Address.String())
+		case *AAAA:
+			ipv6 = append(ipv6, data.Address.String())
+		}
+	}
+
+	return append(addrs, ipv6...)
+}
+
+// followAnswers returns the records of the response `res` from a
+// server of `zone` that answer for `name`, following the aliases
+// within the zone. Aliases that lead to names the response doesn't
+// answer for are returned along with the name they lead to, which is
+// to be looked up (`done` being false).
+func followAnswers(res *Message, zone, name Name, qtype QType) (answers []*RR, target Name, done bool) {
+	var followed bool
+
+	for chain := 0; chain <= maxCNAMEChain; chain++ {
+		var (
+			found bool
+			cname *RR
+		)
+
+		for _, rr := range res.Answers {
+			owner, err := ParseName(rr.NAME)
+			if err != nil || !owner.Equal(name) || !owner.IsSubdomainOf(zone) {
+				continue
+			}
+
+			if rr.TYPE == qtype || qtype == QTypeWildcard {
+				answers = append(answers, rr)
+				found = true
+			} else if rr.TYPE == QTypeCNAME && cname == nil {
+				cname = rr
+			}
+		}
+
+		if found {
+			return answers, name, true
+		}
+
+		if cname == nil {
+			break
+		}
+
+		data, ok := cname.Data.(*CNAME)
+		if !ok {
+			break
+		}
+
+		next, err := ParseName(data.Target)
+		if err != nil {
+			break
+		}
+
+		answers = append(answers, cname)
+		name = next
+		followed = true
+	}
+
+	if !followed {
+		return answers, name, true
+	}
+
+	// the name an alias leads to is known not to exist (or to lack
+	// the type) only if it's in the zone and the response says so.
+	negative := res.RCODE == byte(RCODENameError) || negativeSOAOf(res, name) != nil
+
+	return answers, name, name.IsSubdomainOf(zone) && negative
+}
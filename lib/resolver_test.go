@@ -0,0 +1,546 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHierarchy holds the zones served by each loopback address of a
+// fake hierarchy rooted at 127.0.0.10.
+var testHierarchy = map[string]map[string]string{
+	"127.0.0.10": {
+		".": `
+.			SOA	a.root-servers.test. hostmaster.root-servers.test. 1 7200 900 604800 300
+.			NS	a.root-servers.test.
+a.root-servers.test.	A	127.0.0.10
+com.			NS	ns.com.
+ns.com.			A	127.0.0.11
+net.			NS	ns.net.
+ns.net.			A	127.0.0.12
+`,
+	},
+	"127.0.0.11": {
+		"com": `
+@			SOA	ns hostmaster 1 7200 900 604800 300
+@			NS	ns
+ns			A	127.0.0.11
+example			NS	ns1.example
+example			NS	ns.example.net.
+ns1.example		A	127.0.0.13
+glueless		NS	ns.example.net.
+poisoned		NS	ns.example.net.
+loop			NS	ns.loop.net.
+strict			NS	ns1.example.com.
+`,
+	},
+	"127.0.0.12": {
+		"net": `
+@			SOA	ns hostmaster 1 7200 900 604800 300
+@			NS	ns
+ns			A	127.0.0.12
+example			NS	ns.example
+ns.example		A	127.0.0.14
+loop			NS	ns.loop.com.
+`,
+	},
+	"127.0.0.13": {
+		"example.com": `
+@			SOA	ns1 hostmaster 1 7200 900 604800 300
+@			NS	ns1
+@			NS	ns.example.net.
+ns1			A	127.0.0.13
+www			A	192.0.2.1
+alias			CNAME	www
+external		CNAME	www.example.net.
+dangling		CNAME	nope
+x.y			A	192.0.2.5
+a.b.ent			A	192.0.2.6
+`,
+		"strict.com": `
+@			SOA	ns1.example.com. hostmaster 1 7200 900 604800 300
+@			NS	ns1.example.com.
+a.b			A	192.0.2.7
+`,
+	},
+	"127.0.0.14": {
+		"example.net": `
+@			SOA	ns hostmaster 1 7200 900 604800 300
+@			NS	ns
+ns			A	127.0.0.14
+www			A	192.0.2.2
+`,
+		"glueless.com": `
+@			SOA	ns.example.net. hostmaster 1 7200 900 604800 300
+@			NS	ns.example.net.
+www			A	192.0.2.3
+`,
+		"poisoned.com": `
+@			SOA	ns.example.net. hostmaster 1 7200 900 604800 300
+@			NS	ns.example.net.
+www			A	192.0.2.4
+`,
+	},
+}
+
+// poisonGlue adds an address for a name outside of the zone of the
+// server to its referrals, which resolvers must not trust.
+func poisonGlue(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		res := HandleMessage(w.Context(), next, r)
+
+		if strings.HasSuffix(r.Questions[0].QNAME, "poisoned.com") {
+			res.Additionals = append(res.Additionals, &RR{
+				NAME: "ns.example.net", TYPE: QTypeA, CLASS: QClassIN, TTL: 3600,
+				Data: &A{Address: net.IPv4(127, 0, 0, 66).To4()},
+			})
+		}
+
+		w.WriteMsg(res)
+	})
+}
+
+// breakEmptyNonTerminals answers NXDOMAIN instead of NODATA for the
+// names within ent.example.com, like some broken servers do for empty
+// non-terminals.
+func breakEmptyNonTerminals(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		res := HandleMessage(w.Context(), next, r)
+
+		if strings.HasSuffix(r.Questions[0].QNAME, "ent.example.com") && len(res.Answers) == 0 {
+			res.RCODE = byte(RCODENameError)
+		}
+
+		w.WriteMsg(res)
+	})
+}
+
+// refuseNS answers REFUSED to queries for the NS records of names
+// below strict.com, like some broken servers do.
+func refuseNS(next Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		if r.Questions[0].QTYPE == QTypeNS && strings.HasSuffix(r.Questions[0].QNAME, ".strict.com") {
+			ErrorHandler(RCODERefused).ServeDNS(w, r)
+			return
+		}
+
+		next.ServeDNS(w, r)
+	})
+}
+
+// startHierarchy serves the zones of `hierarchy` on a port shared by
+// all of its addresses, returning the port.
+func startHierarchy(t *testing.T, hierarchy map[string]map[string]string) string {
+	for attempt := 0; attempt < 10; attempt++ {
+		probe, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		_, port, _ := net.SplitHostPort(probe.LocalAddr().String())
+		probe.Close()
+
+		if serveHierarchy(t, hierarchy, port) {
+			return port
+		}
+	}
+
+	t.Fatal("no port free on every address of the hierarchy")
+	return ""
+}
+
+func serveHierarchy(t *testing.T, hierarchy map[string]map[string]string, port string) bool {
+	var servers []*Server
+
+	for addr, zones := range hierarchy {
+		var mux = NewServeMux()
+
+		for origin, text := range zones {
+			rrs, err := ParseZone(strings.NewReader("$TTL 3600\n"+text), origin, "test")
+			require.NoError(t, err)
+
+			zone, err := NewZone(origin, rrs)
+			require.NoError(t, err)
+
+			require.NoError(t, mux.Handle(origin, zone))
+		}
+
+		mux.Use(poisonGlue, breakEmptyNonTerminals, refuseNS)
+
+		conn, err := net.ListenPacket("udp", net.JoinHostPort(addr, port))
+		if err != nil {
+			if _, err = net.ListenPacket("udp", net.JoinHostPort(addr, "0")); err != nil {
+				t.Skipf("can't listen on %s: %v", addr, err)
+			}
+
+			for _, s := range servers {
+				s.Close()
+			}
+
+			return false
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(addr, port))
+		if err != nil {
+			conn.Close()
+
+			for _, s := range servers {
+				s.Close()
+			}
+
+			return false
+		}
+
+		server := &Server{Handler: mux}
+		go server.ServeUDP(conn)
+		go server.ServeTCP(listener)
+
+		servers = append(servers, server)
+		t.Cleanup(func() { server.Close() })
+	}
+
+	return true
+}
+
+func testResolver(t *testing.T, cfg ResolverConfig) *Resolver {
+	cfg.Hints = testRecords(t,
+		". 3600 IN NS a.root-servers.test.",
+		"a.root-servers.test. 3600 IN A 127.0.0.10")
+	cfg.Port = startHierarchy(t, testHierarchy)
+	cfg.Timeout = 200 * time.Millisecond
+
+	resolver, err := NewResolver(cfg)
+	require.NoError(t, err)
+
+	return resolver
+}
+
+func TestResolver(t *testing.T) {
+	var resolver = testResolver(t, ResolverConfig{})
+
+	var testCases = []struct {
+		desc    string
+		qname   string
+		qtype   QType
+		rcode   RCODE
+		answers []string
+	}{
+		{
+			desc:    "glue",
+			qname:   "www.example.com",
+			qtype:   QTypeA,
+			answers: []string{"www.example.com. 3600 IN A 192.0.2.1"},
+		},
+		{
+			desc:  "ns of a zone",
+			qname: "example.com",
+			qtype: QTypeNS,
+			answers: []string{
+				"example.com. 3600 IN NS ns1.example.com.",
+				"example.com. 3600 IN NS ns.example.net.",
+			},
+		},
+		{
+			desc:    "name server without glue",
+			qname:   "www.glueless.com",
+			qtype:   QTypeA,
+			answers: []string{"www.glueless.com. 3600 IN A 192.0.2.3"},
+		},
+		{
+			desc:    "glue out of bailiwick",
+			qname:   "www.poisoned.com",
+			qtype:   QTypeA,
+			answers: []string{"www.poisoned.com. 3600 IN A 192.0.2.4"},
+		},
+		{
+			desc:  "cname within a zone",
+			qname: "alias.example.com",
+			qtype: QTypeA,
+			answers: []string{
+				"alias.example.com. 3600 IN CNAME www.example.com.",
+				"www.example.com. 3600 IN A 192.0.2.1",
+			},
+		},
+		{
+			desc:  "cname to another zone",
+			qname: "external.example.com",
+			qtype: QTypeA,
+			answers: []string{
+				"external.example.com. 3600 IN CNAME www.example.net.",
+				"www.example.net. 3600 IN A 192.0.2.2",
+			},
+		},
+		{
+			desc:    "cname to a name that doesn't exist",
+			qname:   "dangling.example.com",
+			qtype:   QTypeA,
+			rcode:   RCODENameError,
+			answers: []string{"dangling.example.com. 3600 IN CNAME nope.example.com."},
+		},
+		{
+			desc:  "nxdomain",
+			qname: "nope.example.com",
+			qtype: QTypeA,
+			rcode: RCODENameError,
+		},
+		{
+			desc:  "nxdomain from the root",
+			qname: "www.example.org",
+			qtype: QTypeA,
+			rcode: RCODENameError,
+		},
+		{
+			desc:  "nodata",
+			qname: "www.example.com",
+			qtype: QTypeAAAA,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			res, err := resolver.Resolve(context.Background(), tc.qname, tc.qtype)
+			require.NoError(t, err)
+
+			assert.Equal(t, byte(tc.rcode), res.RCODE)
+			assert.Equal(t, tc.answers, recordLines(res.Answers))
+			require.Len(t, res.Questions, 1)
+			assert.Equal(t, tc.qname, res.Questions[0].QNAME)
+		})
+	}
+}
+
+func TestResolver_limits(t *testing.T) {
+	t.Run("depth", func(t *testing.T) {
+		resolver := testResolver(t, ResolverConfig{})
+
+		_, err := resolver.Resolve(context.Background(), "www.loop.com", QTypeA)
+		assert.Error(t, err)
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		resolver := testResolver(t, ResolverConfig{MaxQueries: 2})
+
+		_, err := resolver.Resolve(context.Background(), "www.example.com", QTypeA)
+		assert.Error(t, err)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		resolver := testResolver(t, ResolverConfig{})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := resolver.Resolve(ctx, "www.example.com", QTypeA)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewResolver(t *testing.T) {
+	resolver, err := NewResolver(ResolverConfig{})
+	require.NoError(t, err)
+	assert.Len(t, resolver.servers, 13)
+
+	for _, ns := range resolver.servers {
+		assert.Len(t, ns.addrs, 2, ns.host.String())
+	}
+
+	_, err = NewResolver(ResolverConfig{Hints: testRecords(t, ". 3600 IN NS a.root-servers.test.")})
+	assert.Error(t, err)
+
+	_, err = NewResolver(ResolverConfig{Hints: testRecords(t, "a.root-servers.test. 3600 IN A 127.0.0.10")})
+	assert.Error(t, err)
+}
+
+func TestResolver_Trace(t *testing.T) {
+	var (
+		steps    []string
+		resolver = testResolver(t, ResolverConfig{
+			Trace: func(step ResolverStep) {
+				require.NoError(t, step.Err)
+				require.NotNil(t, step.Response)
+
+				steps = append(steps, strings.Repeat(" ", step.Depth)+
+					step.Question.QNAME+" "+step.Question.QTYPE.String()+" "+
+					step.Zone+" "+step.Server+" "+step.Address)
+			},
+		})
+	)
+
+	_, err := resolver.Resolve(context.Background(), "www.glueless.com", QTypeA)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"com NS . a.root-servers.test. 127.0.0.10",
+		"glueless.com NS com. ns.com. 127.0.0.11",
+		" net NS . a.root-servers.test. 127.0.0.10",
+		" example.net NS net. ns.net. 127.0.0.12",
+		" ns.example.net A example.net. ns.example.net. 127.0.0.14",
+		"www.glueless.com A glueless.com. ns.example.net. 127.0.0.14",
+	}, steps)
+}
+
+func TestResolver_queryIDs(t *testing.T) {
+	var (
+		ids      = make(map[uint16]bool)
+		queries  int
+		resolver = testResolver(t, ResolverConfig{
+			Trace: func(step ResolverStep) {
+				require.NotNil(t, step.Response)
+				ids[step.Response.ID] = true
+				queries++
+			},
+		})
+	)
+
+	for _, qname := range []string{"www.example.com", "www.example.net", "www.glueless.com"} {
+		_, err := resolver.Resolve(context.Background(), qname, QTypeA)
+		require.NoError(t, err)
+	}
+
+	// IDs are picked at random: a few may repeat, but not most of
+	// them.
+	require.Greater(t, queries, 8)
+	assert.Greater(t, len(ids), queries/2)
+	assert.False(t, ids[0] && ids[1] && ids[2], "ids look sequential")
+}
+
+func TestResolver_minimisation(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		qname     string
+		disable   bool
+		rcode     RCODE
+		questions []string
+	}{
+		{
+			desc:  "one label more per zone",
+			qname: "www.example.com",
+			questions: []string{
+				"com NS .",
+				"example.com NS com.",
+				"www.example.com A example.com.",
+			},
+		},
+		{
+			desc:    "disabled",
+			qname:   "www.example.com",
+			disable: true,
+			questions: []string{
+				"www.example.com A .",
+				"www.example.com A com.",
+				"www.example.com A example.com.",
+			},
+		},
+		{
+			desc:  "empty non-terminal",
+			qname: "x.y.example.com",
+			questions: []string{
+				"com NS .",
+				"example.com NS com.",
+				"y.example.com NS example.com.",
+				"x.y.example.com A example.com.",
+			},
+		},
+		{
+			desc:  "nxdomain for an empty non-terminal",
+			qname: "a.b.ent.example.com",
+			questions: []string{
+				"com NS .",
+				"example.com NS com.",
+				"ent.example.com NS example.com.",
+				"a.b.ent.example.com A example.com.",
+			},
+		},
+		{
+			desc:  "ns queries refused",
+			qname: "a.b.strict.com",
+			questions: []string{
+				"com NS .",
+				"strict.com NS com.",
+				"b.strict.com NS strict.com.",
+				"b.strict.com A strict.com.",
+				"a.b.strict.com A strict.com.",
+			},
+		},
+		{
+			desc:  "nxdomain",
+			qname: "www.example.org",
+			rcode: RCODENameError,
+			questions: []string{
+				"org NS .",
+				"www.example.org A .",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var (
+				questions []string
+				resolver  = testResolver(t, ResolverConfig{
+					DisableMinimisation: tc.disable,
+					Trace: func(step ResolverStep) {
+						questions = append(questions, step.Question.QNAME+" "+
+							step.Question.QTYPE.String()+" "+step.Zone)
+					},
+				})
+			)
+
+			res, err := resolver.Resolve(context.Background(), tc.qname, QTypeA)
+			require.NoError(t, err)
+
+			assert.Equal(t, byte(tc.rcode), res.RCODE)
+			assert.Equal(t, tc.questions, questions)
+
+			if tc.rcode == RCODENoError {
+				assert.Len(t, res.Answers, 1)
+			}
+		})
+	}
+}
+
+func TestMinimisedName(t *testing.T) {
+	var testCases = []struct {
+		desc     string
+		name     string
+		revealed string
+		child    string
+	}{
+		{
+			desc:     "from the root",
+			name:     "www.example.com",
+			revealed: ".",
+			child:    "com",
+		},
+		{
+			desc:     "from a zone",
+			name:     "www.example.com",
+			revealed: "com",
+			child:    "example.com",
+		},
+		{
+			desc:     "one label below",
+			name:     "www.example.com",
+			revealed: "example.com",
+		},
+		{
+			desc:     "the name itself",
+			name:     "example.com",
+			revealed: "example.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			child, ok := minimisedName(MustParseName(tc.name), MustParseName(tc.revealed))
+
+			assert.Equal(t, tc.child != "", ok)
+			if ok {
+				assert.Equal(t, tc.child, child.text())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+// RP represents the RDATA of an RP record as described in RFC1183
+// section 2.2: the person responsible for a domain name.
+//
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                   MBOX-DNAME                  /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                   TXT-DNAME                   /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type RP struct {
+
+	// Mbox is the mailbox of the responsible person, encoded as
+	// a domain name (as in SOA's RNAME). `.` if unavailable.
+	Mbox string
+
+	// Txt is a domain name for which TXT records exist with
+	// further information. `.` if unavailable.
+	Txt string
+}
+
+func unmarshalRPRData(msg []byte, off, length int) (data RData, err error) {
+	var r = new(RP)
+
+	_, err = unmarshalRP(msg, off, off+length, r)
+	if err != nil {
+		return
+	}
+
+	data = r
+	return
+}
+
+// UnmarshalRP decodes the RDATA of an RP record into `r`. `msg` must
+// contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalRP(msg []byte, r *RP) (n int, err error) {
+	return unmarshalRP(msg, 0, len(msg), r)
+}
+
+func unmarshalRP(msg []byte, off, end int, r *RP) (n int, err error) {
+	if r == nil {
+		err = errors.Errorf("rp must be non-nil")
+		return
+	}
+
+	n, err = unmarshalNamePair(msg, off, end,
+		&r.Mbox, &r.Txt, "mbox", "txt")
+	return
+}
+
+// Marshal encodes the RDATA of the RP record.
+func (r RP) Marshal() (res []byte, err error) {
+	return r.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the RP record to `b`.
+func (r RP) AppendTo(b []byte) (res []byte, err error) {
+	res, err = appendRDATAName(b, r.Mbox)
+	if err != nil {
+		return
+	}
+
+	return appendRDATAName(res, r.Txt)
+}
+
+// String renders the RDATA in presentation format.
+func (r RP) String() string {
+	return fqdn(r.Mbox) + " " + fqdn(r.Txt)
+}
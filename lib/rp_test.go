@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		entity    *RP
+		presented string
+	}{
+		{
+			desc: "well formed",
+			entity: &RP{
+				Mbox: "louie.trantor.umd.edu",
+				Txt:  "lam1.people.umd.edu",
+			},
+			presented: "louie.trantor.umd.edu. lam1.people.umd.edu.",
+		},
+		{
+			desc: "no txt",
+			entity: &RP{
+				Mbox: "hostmaster.example.com",
+				Txt:  ".",
+			},
+			presented: "hostmaster.example.com. .",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *RP
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			require.NoError(t, err)
+
+			unmarshalled = new(RP)
+			_, err = UnmarshalRP(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestRPWithCompressedNames(t *testing.T) {
+	// umd.edu at offset 0 followed by an RP record whose
+	// names point back at it.
+	var msg = []byte{
+		3, 'u', 'm', 'd', 3, 'e', 'd', 'u', 0,
+		0xc0, 0, 0, 17, 0, 1, 0, 0, 0, 60, 0, 12,
+		3, 'l', 'a', 'm', 0xc0, 0,
+		3, 'i', 'n', 'f', 0xc0, 0,
+	}
+
+	var rr = new(RR)
+
+	n, err := unmarshalRR(msg, 9, rr, false)
+	require.NoError(t, err)
+	assert.Equal(t, len(msg)-9, n)
+
+	rp, ok := rr.Data.(*RP)
+	require.True(t, ok)
+	assert.Equal(t, "lam.umd.edu", rp.Mbox)
+	assert.Equal(t, "inf.umd.edu", rp.Txt)
+}
@@ -1,6 +1,10 @@
 package lib
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
 	"github.com/pkg/errors"
 )
 
@@ -53,48 +57,213 @@ type RR struct {
 	// RDATA is the generic data from the record.
 	// The format of the information contained here varies
 	// according to the tupple {TYPE, CLASS} of the RR.
+	// ps.: names in RDATA might be compressed, in which case
+	// they only make sense within the original message.
 	RDATA []byte
+
+	// Data is the typed representation of RDATA, set when
+	// decoding records of types that have one (e.g., *SVCB
+	// for SVCB and HTTPS records).
+	Data RData
 }
 
+// UnmarshalRR decodes a resource record that sits at the beginning
+// of `msg`.
+//
+// Given that compression pointers are offsets from the start of the
+// whole message, records that make use of them must be decoded from
+// the message they came in (see `UnmarshalMessage`).
 func UnmarshalRR(msg []byte, r *RR) (n int, err error) {
+	return unmarshalRR(msg, 0, r, false)
+}
+
+// unmarshalRR decodes the resource record that starts at the offset
+// `off` of the message `msg`.
+//
+// If `lenient`, RDATA that can't be decoded into its typed
+// representation is kept as an *UnknownRDATA instead of failing,
+// except for the types that may carry compressed names.
+func unmarshalRR(msg []byte, off int, r *RR, lenient bool) (n int, err error) {
 	if r == nil {
 		err = errors.Errorf(
 			"rr must be non-nil")
 		return
 	}
 
-	if len(msg) < 11 {
-		err = errors.Errorf(
-			"rr msg must be at least 11bytes long")
+	var (
+		ndx int = off
+	)
+
+	r.NAME, n, err = unmarshalName(msg, ndx)
+	if err != nil {
+		err = parseErrorf(0, off,
+			"malformed name: %s",
+			err)
+		return
+	}
+
+	ndx += n
+
+	if len(msg)-ndx < 10 {
+		err = parseErrorf(0, off,
+			"rr must have at least 10 bytes after the name - %d",
+			len(msg)-ndx)
+		return
 	}
 
+	// TYPE, CLASS, TTL and RDLENGTH come in network byte order
+	// (BigEndian).
+	r.TYPE = QType(binary.BigEndian.Uint16(msg[ndx : ndx+2]))
+	r.CLASS = QClass(binary.BigEndian.Uint16(msg[ndx+2 : ndx+4]))
+	r.TTL = binary.BigEndian.Uint32(msg[ndx+4 : ndx+8])
+	r.RDLENGTH = binary.BigEndian.Uint16(msg[ndx+8 : ndx+10])
+	ndx += 10
+
+	if len(msg)-ndx < int(r.RDLENGTH) {
+		err = parseErrorf(0, ndx,
+			"rdata of length %d goes past the end of the message",
+			r.RDLENGTH)
+		return
+	}
+
+	r.RDATA = msg[ndx : ndx+int(r.RDLENGTH)]
+
+	r.Data, err = unmarshalRDATA(r.TYPE, msg, ndx, int(r.RDLENGTH))
+	if err != nil {
+		// The RDATA of the types that may carry compressed names
+		// can't be kept as is, as it would point at the wrong
+		// names once taken out of this message.
+		if !lenient || compressibleTypes[r.TYPE] {
+			err = parseErrorf(0, ndx,
+				"malformed rdata: %s",
+				err)
+			return
+		}
+
+		r.Data, err = unmarshalUnknownRData(msg, ndx, int(r.RDLENGTH))
+		if err != nil {
+			return
+		}
+	}
+
+	ndx += int(r.RDLENGTH)
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the resource record without compressing its name.
+//
+// If `Data` is set, RDATA and RDLENGTH are computed from it, otherwise
+// RDATA is written as is.
+func (r *RR) Marshal() (res []byte, err error) {
+	return r.AppendTo(nil)
+}
+
+// AppendTo appends the wire format of the resource record to `b` (see
+// `Marshal`).
+func (r *RR) AppendTo(b []byte) (res []byte, err error) {
 	var (
-		compressedName = new(CompressedName)
+		rdata  = r.RDATA
+		length int
 	)
 
-	_, err = UnmarshalCompressedName(msg[0:2], compressedName)
+	res, err = appendName(b, r.NAME)
 	if err != nil {
 		err = errors.Wrapf(err,
-			"failed to parse compressed name")
+			"malformed name %s",
+			r.NAME)
 		return
 	}
 
-	// TODO get the expanded name
+	res = binary.BigEndian.AppendUint16(res, uint16(r.TYPE))
+	res = binary.BigEndian.AppendUint16(res, uint16(r.CLASS))
+	res = binary.BigEndian.AppendUint32(res, r.TTL)
 
-	r.TYPE = QType(uint16(msg[3]) | uint16(msg[2]))
-	r.CLASS = QClass(uint16(msg[5]) | uint16(msg[4]))
-	r.TTL = uint32(msg[9]) | uint32(msg[8]) | uint32(msg[7]) | uint32(6)
-	r.RDLENGTH = uint16(msg[11]) | uint16(msg[10])
-	r.RDATA = msg[12 : 12+r.RDLENGTH]
+	// RDLENGTH is filled in once RDATA is written.
+	length = len(res)
+	res = append(res, 0, 0)
 
-	n = int(12 + r.RDLENGTH)
+	switch data := r.Data.(type) {
+	case nil:
+		res = append(res, rdata...)
+	case rdataAppender:
+		res, err = data.AppendTo(res)
+	default:
+		rdata, err = data.Marshal()
+		res = append(res, rdata...)
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to marshal rdata")
+		return
+	}
+
+	if len(res)-length-2 > 0xffff {
+		err = errors.Errorf(
+			"rdata exceeds the maximum length - %d",
+			len(res)-length-2)
+		return
+	}
+
+	binary.BigEndian.PutUint16(res[length:], uint16(len(res)-length-2))
 	return
 }
 
-// TODO in order to perform the proper marshalling, we'd need
-//	to have the full message to properly compress the name,
-//	unless we don't compress it at all.
-func (r *RR) Marshal() (res []byte, err error) {
-	// TODO implement
+// String renders the record in presentation (master file) format,
+// e.g.:
+//
+//	example.com.	300	IN	A	93.184.216.34
+//
+// Records without a typed RDATA are rendered using the generic
+// RDATA syntax (RFC3597).
+func (r *RR) String() string {
+	var rdata string
+
+	if r.Data != nil {
+		rdata = r.Data.String()
+	} else {
+		rdata = UnknownRDATA{Raw: r.RDATA}.String()
+	}
+
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s",
+		fqdn(r.NAME), r.TTL, r.CLASS, r.TYPE, rdata)
+}
+
+// Equal tells whether `r` and `other` are the same record: they have
+// the same owner (compared case-insensitively), type, class and RDATA
+// in its canonical form (RFC4034 section 6.2).
+//
+// TTLs are not compared as they're a property of the whole RRset
+// rather than of the record (RFC2181 section 5.2).
+func (r *RR) Equal(other *RR) bool {
+	if r.TYPE != other.TYPE || r.CLASS != other.CLASS ||
+		canonicalName(r.NAME) != canonicalName(other.NAME) {
+		return false
+	}
+
+	a, err := r.canonicalRDATA()
+	if err != nil {
+		return false
+	}
+
+	b, err := other.canonicalRDATA()
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(a, b)
+}
+
+// canonicalRDATA returns the wire format of the RDATA in canonical
+// form. Records without a typed RDATA are taken as they are.
+func (r *RR) canonicalRDATA() (res []byte, err error) {
+	if r.Data == nil {
+		res = r.RDATA
+		return
+	}
+
+	res, err = canonicalRData(r.Data).Marshal()
 	return
 }
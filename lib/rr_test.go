@@ -21,6 +21,39 @@ func TestRRMarshallingAndUnmarshalling(t *testing.T) {
 				RDATA:    []byte{192, 168, 0, 1},
 			},
 		},
+		{
+			desc: "root owner",
+			entity: &RR{
+				NAME:     ".",
+				TYPE:     QTypeNS,
+				CLASS:    QClassIN,
+				TTL:      518400,
+				RDLENGTH: 20,
+				Data:     &NS{Host: "a.root-servers.net"},
+			},
+		},
+		{
+			desc: "single label owner",
+			entity: &RR{
+				NAME:     "com",
+				TYPE:     QTypeNS,
+				CLASS:    QClassIN,
+				TTL:      172800,
+				RDLENGTH: 20,
+				Data:     &NS{Host: "a.gtld-servers.net."},
+			},
+		},
+		{
+			desc: "fully qualified single label owner",
+			entity: &RR{
+				NAME:     "com.",
+				TYPE:     QTypeNS,
+				CLASS:    QClassIN,
+				TTL:      172800,
+				RDLENGTH: 1,
+				Data:     &NS{Host: "."},
+			},
+		},
 	}
 
 	var (
@@ -39,7 +72,13 @@ func TestRRMarshallingAndUnmarshalling(t *testing.T) {
 			_, err = UnmarshalRR(msg, unmarshalled)
 			require.NoError(t, err)
 
-			assert.Equal(t, tc.entity.RDATA, unmarshalled.RDATA)
+			if tc.entity.Data == nil {
+				assert.Equal(t, tc.entity.RDATA, unmarshalled.RDATA)
+			} else {
+				assert.Equal(t, canonicalName(rdataNames(tc.entity.Data)[0]), rdataNames(unmarshalled.Data)[0])
+			}
+
+			assert.True(t, MustParseName(tc.entity.NAME).Equal(MustParseName(unmarshalled.NAME)))
 			assert.Equal(t, tc.entity.TTL, unmarshalled.TTL)
 			assert.Equal(t, tc.entity.RDLENGTH, unmarshalled.RDLENGTH)
 			assert.Equal(t, tc.entity.TYPE, unmarshalled.TYPE)
@@ -47,3 +86,53 @@ func TestRRMarshallingAndUnmarshalling(t *testing.T) {
 		})
 	}
 }
+
+func TestRREqual(t *testing.T) {
+	var testCases = []struct {
+		desc     string
+		a        *RR
+		b        *RR
+		expected bool
+	}{
+		{
+			desc:     "owner is case-insensitive",
+			a:        &RR{NAME: "WWW.Example.com", TYPE: QTypeA, CLASS: QClassIN, TTL: 60, Data: &A{Address: []byte{192, 0, 2, 1}}},
+			b:        &RR{NAME: "www.example.com.", TYPE: QTypeA, CLASS: QClassIN, TTL: 300, Data: &A{Address: []byte{192, 0, 2, 1}}},
+			expected: true,
+		},
+		{
+			desc:     "names in rdata are case-insensitive",
+			a:        &RR{NAME: "example.com", TYPE: QTypeMX, CLASS: QClassIN, Data: &MX{Preference: 10, Exchange: "MAIL.example.com"}},
+			b:        &RR{NAME: "example.com", TYPE: QTypeMX, CLASS: QClassIN, Data: &MX{Preference: 10, Exchange: "mail.example.com."}},
+			expected: true,
+		},
+		{
+			desc: "character-strings are case-sensitive",
+			a:    &RR{NAME: "example.com", TYPE: QTypeTXT, CLASS: QClassIN, Data: &TXT{Strings: []string{"A"}}},
+			b:    &RR{NAME: "example.com", TYPE: QTypeTXT, CLASS: QClassIN, Data: &TXT{Strings: []string{"a"}}},
+		},
+		{
+			desc: "different rdata",
+			a:    &RR{NAME: "example.com", TYPE: QTypeA, CLASS: QClassIN, Data: &A{Address: []byte{192, 0, 2, 1}}},
+			b:    &RR{NAME: "example.com", TYPE: QTypeA, CLASS: QClassIN, Data: &A{Address: []byte{192, 0, 2, 2}}},
+		},
+		{
+			desc: "different class",
+			a:    &RR{NAME: "example.com", TYPE: QTypeA, CLASS: QClassIN, RDATA: []byte{1}},
+			b:    &RR{NAME: "example.com", TYPE: QTypeA, CLASS: QClassCH, RDATA: []byte{1}},
+		},
+		{
+			desc:     "raw rdata",
+			a:        &RR{NAME: "example.com", TYPE: 731, CLASS: QClassIN, Data: &UnknownRDATA{Raw: []byte{1, 2}}},
+			b:        &RR{NAME: "example.com", TYPE: 731, CLASS: QClassIN, RDATA: []byte{1, 2}},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.a.Equal(tc.b))
+			assert.Equal(t, tc.expected, tc.b.Equal(tc.a))
+		})
+	}
+}
@@ -0,0 +1,654 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultUDPSize is the size of the largest UDP response
+	// accepted by clients that don't advertise otherwise (RFC1035
+	// section 4.2.1).
+	defaultUDPSize = 512
+
+	// defaultIdleTimeout is how long TCP connections are kept open
+	// waiting for a request (RFC7766 section 6.2.3).
+	defaultIdleTimeout = 10 * time.Second
+
+	// defaultWriteTimeout is how long writing a response over TCP
+	// may take.
+	defaultWriteTimeout = 5 * time.Second
+)
+
+var (
+	// ErrServerClosed is returned by the Serve methods of a Server
+	// after it's shut down.
+	ErrServerClosed = errors.New("server closed")
+)
+
+// Handler responds to DNS requests.
+//
+// ServeDNS writes the response with `w.WriteMsg` and returns. Requests
+// that aren't written to get no response at all. The request must not
+// be modified and `w` must not be used after ServeDNS returns.
+type Handler interface {
+	ServeDNS(w ResponseWriter, r *Message)
+}
+
+// HandlerFunc adapts an ordinary function to a Handler.
+type HandlerFunc func(w ResponseWriter, r *Message)
+
+// ServeDNS calls f(w, r).
+func (f HandlerFunc) ServeDNS(w ResponseWriter, r *Message) {
+	f(w, r)
+}
+
+// ResponseWriter is used by a Handler to respond to a request.
+type ResponseWriter interface {
+
+	// Context is the context of the request, canceled once the
+	// handler returns or the server is forcefully closed.
+	Context() context.Context
+
+	// Network is the network the request came through, either
+	// "udp" or "tcp".
+	Network() string
+
+	// LocalAddr is the address the request was received at.
+	LocalAddr() net.Addr
+
+	// RemoteAddr is the address of the client.
+	RemoteAddr() net.Addr
+
+	// WriteMsg sends the response `m` to the client, with the counts
+	// of its header set from its sections. Responses over UDP that
+	// don't fit in the size accepted by the client are truncated
+	// (see `MarshalOptions`).
+	WriteMsg(m *Message) error
+}
+
+// Server answers DNS requests over UDP and TCP, handing each of them
+// to a Handler in its own goroutine.
+//
+// The zero value is a valid server that has to be given a Handler.
+type Server struct {
+
+	// Addr is the address to listen on with `ListenAndServe`,
+	// ":53" if empty.
+	Addr string
+
+	// Handler responds to the requests.
+	Handler Handler
+
+	// UDPSize is the size of the largest response sent over UDP,
+	// 512 bytes if zero.
+	UDPSize int
+
+	// MaxConcurrent limits how many requests are handled at once,
+	// with zero meaning no limit. Once it's reached, reading new
+	// requests waits for the ones being handled.
+	MaxConcurrent int
+
+	// IdleTimeout is how long a TCP connection is kept open waiting
+	// for a request, 10 seconds if zero.
+	IdleTimeout time.Duration
+
+	// WriteTimeout is how long writing a response over TCP may
+	// take, 5 seconds if zero.
+	WriteTimeout time.Duration
+
+	// ErrorLog logs handlers that panic, with the standard logger
+	// being used if nil.
+	ErrorLog *log.Logger
+
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	sem       chan struct{}
+	closed    bool
+	listeners map[io.Closer]struct{}
+	conns     map[net.Conn]struct{}
+
+	// active tracks the requests and TCP connections being served.
+	active sync.WaitGroup
+}
+
+// NewReply creates a response to the request `req`, with its ID, opcode,
+// RD bit and questions.
+func NewReply(req *Message) *Message {
+	return &Message{
+		Header: Header{
+			ID:     req.ID,
+			QR:     1,
+			Opcode: req.Opcode,
+			RD:     req.RD,
+		},
+		Questions: req.Questions,
+	}
+}
+
+// ListenAndServe listens on `s.Addr` both over UDP and TCP (on the same
+// port) and serves requests until the server is shut down, always
+// returning a non-nil error.
+func (s *Server) ListenAndServe() (err error) {
+	var (
+		addr     = s.Addr
+		errs     = make(chan error, 2)
+		conn     net.PacketConn
+		listener net.Listener
+	)
+
+	if addr == "" {
+		addr = ":53"
+	}
+
+	conn, err = net.ListenPacket("udp", addr)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to listen on udp address %s",
+			addr)
+		return
+	}
+
+	listener, err = net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		conn.Close()
+		err = errors.Wrapf(err,
+			"failed to listen on tcp address %s",
+			conn.LocalAddr())
+		return
+	}
+
+	go func() { errs <- s.ServeUDP(conn) }()
+	go func() { errs <- s.ServeTCP(listener) }()
+
+	err = <-errs
+	conn.Close()
+	listener.Close()
+	<-errs
+
+	return
+}
+
+// ServeUDP reads requests from `conn` until the server is shut down,
+// closing it once the requests read from it are answered.
+func (s *Server) ServeUDP(conn net.PacketConn) (err error) {
+	var (
+		buf      = make([]byte, 65535)
+		requests sync.WaitGroup
+	)
+
+	if !s.trackListener(conn) {
+		conn.Close()
+		err = ErrServerClosed
+		return
+	}
+
+	defer func() {
+		requests.Wait()
+		conn.Close()
+		s.untrackListener(conn)
+	}()
+
+	for {
+		var (
+			n    int
+			addr net.Addr
+		)
+
+		n, addr, err = conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				err = ErrServerClosed
+				return
+			}
+
+			err = errors.Wrapf(err,
+				"failed to read from %s",
+				conn.LocalAddr())
+			return
+		}
+
+		var (
+			payload = append([]byte(nil), buf[:n]...)
+			w       = &responseWriter{
+				network: "udp",
+				local:   conn.LocalAddr(),
+				remote:  addr,
+				maxSize: s.udpSize(),
+				write: func(b []byte) (err error) {
+					_, err = conn.WriteTo(b, addr)
+					return
+				},
+			}
+		)
+
+		if !s.acquire() {
+			err = ErrServerClosed
+			return
+		}
+
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			defer s.release()
+			s.serve(w, payload)
+		}()
+	}
+}
+
+// ServeTCP accepts connections from `listener` and reads requests from
+// them until the server is shut down, closing it when done.
+func (s *Server) ServeTCP(listener net.Listener) (err error) {
+	if !s.trackListener(listener) {
+		listener.Close()
+		err = ErrServerClosed
+		return
+	}
+	defer s.untrackListener(listener)
+	defer listener.Close()
+
+	for {
+		var conn net.Conn
+
+		conn, err = listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				err = ErrServerClosed
+				return
+			}
+
+			err = errors.Wrapf(err,
+				"failed to accept connection on %s",
+				listener.Addr())
+			return
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			err = ErrServerClosed
+			return
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads the requests sent through the TCP connection `conn`
+// until it's closed or stays idle for too long. Requests are handled
+// concurrently, with their responses possibly sent out of order
+// (RFC7766 section 6.2.1.1).
+func (s *Server) serveConn(conn net.Conn) {
+	var (
+		mu       sync.Mutex
+		requests sync.WaitGroup
+		length   [2]byte
+	)
+
+	defer func() {
+		requests.Wait()
+		conn.Close()
+		s.untrackConn(conn)
+	}()
+
+	write := func(b []byte) (err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(s.writeTimeout()))
+		_, err = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...))
+		return
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout()))
+
+		// checked after setting the deadline so that one set by
+		// `Shutdown` to stop reading isn't overridden.
+		if s.isClosed() {
+			return
+		}
+
+		_, err := io.ReadFull(conn, length[:])
+		if err != nil {
+			return
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint16(length[:]))
+
+		_, err = io.ReadFull(conn, payload)
+		if err != nil {
+			return
+		}
+
+		if !s.acquire() {
+			return
+		}
+
+		w := &responseWriter{
+			network: "tcp",
+			local:   conn.LocalAddr(),
+			remote:  conn.RemoteAddr(),
+			maxSize: 65535,
+			write:   write,
+		}
+
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			defer s.release()
+			s.serve(w, payload)
+		}()
+	}
+}
+
+// serve decodes the request `payload` and hands it to the handler,
+// answering FORMERR to the ones that are malformed. Responses are
+// never answered.
+func (s *Server) serve(w *responseWriter, payload []byte) {
+	var (
+		req    Message
+		header Header
+		cancel context.CancelFunc
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.logf("rawdns: panic serving %s: %v\n%s",
+				w.remote, r, debug.Stack())
+		}
+	}()
+
+	if len(payload) < 12 {
+		return
+	}
+
+	UnmarshalHeader(payload[:12], &header)
+	if header.QR == 1 {
+		return
+	}
+
+	w.ctx, cancel = context.WithCancel(s.ctx)
+	defer cancel()
+
+	err := UnmarshalMessage(payload, &req)
+	if err != nil {
+		reply := &Message{
+			Header: Header{
+				ID:     header.ID,
+				QR:     1,
+				Opcode: header.Opcode,
+				RD:     header.RD,
+				RCODE:  byte(RCODEFormatError),
+			},
+		}
+
+		w.WriteMsg(reply)
+		return
+	}
+
+	s.Handler.ServeDNS(w, &req)
+}
+
+// Shutdown gracefully shuts the server down: it stops reading requests
+// and waits for the ones being handled to be answered. If `ctx` is done
+// before that, the contexts of the requests are canceled, their TCP
+// connections closed and the error of `ctx` returned.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	var done = make(chan struct{})
+
+	s.mu.Lock()
+	s.init()
+	s.closed = true
+
+	// UDP sockets are only kept open for writing the responses
+	// of the requests being handled.
+	for listener := range s.listeners {
+		if conn, ok := listener.(net.PacketConn); ok {
+			conn.SetReadDeadline(time.Now())
+			continue
+		}
+
+		listener.Close()
+	}
+
+	// connections waiting for requests stop right away.
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.cancel()
+
+	s.mu.Lock()
+	for listener := range s.listeners {
+		listener.Close()
+	}
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	return
+}
+
+// Close closes the server right away, canceling the contexts of the
+// requests being handled (see `Shutdown`).
+func (s *Server) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s.Shutdown(ctx)
+	return nil
+}
+
+// init initializes the internal state of the server, must be called
+// with `s.mu` held.
+func (s *Server) init() {
+	if s.ctx != nil {
+		return
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.listeners = make(map[io.Closer]struct{})
+	s.conns = make(map[net.Conn]struct{})
+
+	if s.MaxConcurrent > 0 {
+		s.sem = make(chan struct{}, s.MaxConcurrent)
+	}
+}
+
+// trackListener adds `listener` to the ones closed on shutdown, failing
+// if the server is already closed.
+func (s *Server) trackListener(listener io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init()
+	if s.closed {
+		return false
+	}
+
+	s.listeners[listener] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackListener(listener io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, listener)
+}
+
+// trackConn adds the TCP connection `conn` to the ones waited for on
+// shutdown, failing if the server is already closed.
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init()
+	if s.closed {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+	s.active.Done()
+}
+
+// acquire reserves a slot for handling a request, waiting for one if
+// the limit of concurrent requests is reached. It fails if the server
+// is closed.
+func (s *Server) acquire() bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+
+	s.active.Add(1)
+	s.mu.Unlock()
+
+	if s.sem == nil {
+		return true
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	case <-s.ctx.Done():
+		s.active.Done()
+		return false
+	}
+}
+
+// release frees a slot reserved with `acquire`.
+func (s *Server) release() {
+	if s.sem != nil {
+		<-s.sem
+	}
+
+	s.active.Done()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed
+}
+
+func (s *Server) udpSize() int {
+	if s.UDPSize > 0 {
+		return s.UDPSize
+	}
+
+	return defaultUDPSize
+}
+
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+
+	return defaultIdleTimeout
+}
+
+func (s *Server) writeTimeout() time.Duration {
+	if s.WriteTimeout > 0 {
+		return s.WriteTimeout
+	}
+
+	return defaultWriteTimeout
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+// responseWriter is the ResponseWriter of both UDP and TCP requests,
+// with `write` sending a marshalled response through the transport.
+type responseWriter struct {
+	ctx     context.Context
+	network string
+	local   net.Addr
+	remote  net.Addr
+	maxSize int
+	write   func(b []byte) error
+}
+
+func (w *responseWriter) Context() context.Context { return w.ctx }
+func (w *responseWriter) Network() string          { return w.network }
+func (w *responseWriter) LocalAddr() net.Addr      { return w.local }
+func (w *responseWriter) RemoteAddr() net.Addr     { return w.remote }
+
+func (w *responseWriter) WriteMsg(m *Message) (err error) {
+	var (
+		res   []byte
+		reply = withCounts(m)
+	)
+
+	res, err = MarshalOptions{MaxSize: w.maxSize}.MarshalAppend(nil, &reply)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to marshal response to %s",
+			w.remote)
+		return
+	}
+
+	err = w.write(res)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to write response to %s",
+			w.remote)
+		return
+	}
+
+	return
+}
+
+// withCounts returns a copy of the message `m` with the counts of its
+// header set from its sections.
+func withCounts(m *Message) Message {
+	var res = *m
+
+	res.QDCOUNT = uint16(len(m.Questions))
+	res.ANCOUNT = uint16(len(m.Answers))
+	res.NSCOUNT = uint16(len(m.Authorities))
+	res.ARCOUNT = uint16(len(m.Additionals))
+
+	return res
+}
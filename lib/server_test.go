@@ -0,0 +1,379 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves `s` on UDP and TCP ports of the loopback,
+// returning their addresses and a channel with the errors the Serve
+// methods return with.
+func startServer(t *testing.T, s *Server) (udpAddr, tcpAddr string, errs chan error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errs = make(chan error, 2)
+	go func() { errs <- s.ServeUDP(conn) }()
+	go func() { errs <- s.ServeTCP(listener) }()
+
+	t.Cleanup(func() { s.Close() })
+
+	return conn.LocalAddr().String(), listener.Addr().String(), errs
+}
+
+// exchange sends `req` to `addr` over `network` and reads the response.
+func exchange(t *testing.T, network, addr string, req *Message) (res *Message, err error) {
+	var (
+		payload []byte
+		buf     = make([]byte, 65535)
+		n       int
+	)
+
+	conn, err := net.Dial(network, addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	payload, err = req.Marshal()
+	require.NoError(t, err)
+
+	if network == "udp" {
+		_, err = conn.Write(payload)
+		require.NoError(t, err)
+
+		n, err = conn.Read(buf)
+		if err != nil {
+			return
+		}
+	} else {
+		_, err = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(payload))), payload...))
+		require.NoError(t, err)
+
+		_, err = io.ReadFull(conn, buf[:2])
+		if err != nil {
+			return
+		}
+
+		n = int(binary.BigEndian.Uint16(buf[:2]))
+		_, err = io.ReadFull(conn, buf[:n])
+		if err != nil {
+			return
+		}
+	}
+
+	res = new(Message)
+	err = UnmarshalMessage(buf[:n], res)
+	return
+}
+
+func testQuery(id uint16, name string) *Message {
+	return &Message{
+		Header: Header{ID: id, RD: 1, QDCOUNT: 1},
+		Questions: []*Question{
+			{QNAME: name, QTYPE: QTypeA, QCLASS: QClassIN},
+		},
+	}
+}
+
+// addressHandler answers with `count` A records for the name asked.
+func addressHandler(count int) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		reply := NewReply(r)
+
+		for ndx := 0; ndx < count; ndx++ {
+			reply.Answers = append(reply.Answers, &RR{
+				NAME: r.Questions[0].QNAME, TYPE: QTypeA, CLASS: QClassIN, TTL: 60,
+				Data: &A{Address: net.IPv4(192, 0, 2, byte(ndx)).To4()},
+			})
+		}
+
+		w.WriteMsg(reply)
+	})
+}
+
+func TestServer(t *testing.T) {
+	var networks = make(chan string, 2)
+
+	udpAddr, tcpAddr, _ := startServer(t, &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+			assert.NotNil(t, w.Context())
+			assert.NotNil(t, w.LocalAddr())
+			assert.NotNil(t, w.RemoteAddr())
+			networks <- w.Network()
+
+			addressHandler(1).ServeDNS(w, r)
+		}),
+	})
+
+	for network, addr := range map[string]string{"udp": udpAddr, "tcp": tcpAddr} {
+		t.Run(network, func(t *testing.T) {
+			res, err := exchange(t, network, addr, testQuery(7, "example.com"))
+			require.NoError(t, err)
+
+			assert.Equal(t, network, <-networks)
+			assert.Equal(t, uint16(7), res.ID)
+			assert.Equal(t, byte(1), res.QR)
+			assert.Equal(t, byte(1), res.RD)
+			assert.Equal(t, uint16(1), res.QDCOUNT)
+			assert.Equal(t, uint16(1), res.ANCOUNT)
+			require.Len(t, res.Answers, 1)
+			assert.Equal(t, "example.com.\t60\tIN\tA\t192.0.2.0", res.Answers[0].String())
+		})
+	}
+}
+
+func TestServer_truncatesOverUDP(t *testing.T) {
+	udpAddr, tcpAddr, _ := startServer(t, &Server{
+		Handler: addressHandler(40),
+	})
+
+	res, err := exchange(t, "udp", udpAddr, testQuery(1, "example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, byte(1), res.TC)
+	assert.Empty(t, res.Answers)
+
+	res, err = exchange(t, "tcp", tcpAddr, testQuery(1, "example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), res.TC)
+	assert.Len(t, res.Answers, 40)
+}
+
+func TestServer_UDPSize(t *testing.T) {
+	udpAddr, _, _ := startServer(t, &Server{
+		Handler: addressHandler(40),
+		UDPSize: 4096,
+	})
+
+	res, err := exchange(t, "udp", udpAddr, testQuery(1, "example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), res.TC)
+	assert.Len(t, res.Answers, 40)
+}
+
+func TestServer_malformedRequests(t *testing.T) {
+	var handled = make(chan struct{}, 2)
+
+	udpAddr, _, _ := startServer(t, &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+			handled <- struct{}{}
+		}),
+	})
+
+	conn, err := net.Dial("udp", udpAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// a query whose question goes past the end of the message.
+	_, err = conn.Write([]byte{0x00, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 1, 'a'})
+	require.NoError(t, err)
+
+	var (
+		buf = make([]byte, 512)
+		res Message
+	)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.NoError(t, UnmarshalMessage(buf[:n], &res))
+	assert.Equal(t, uint16(1), res.ID)
+	assert.Equal(t, byte(RCODEFormatError), res.RCODE)
+
+	// responses and messages shorter than a header are dropped.
+	response := testQuery(2, "example.com")
+	response.QR = 1
+	payload, err := response.Marshal()
+	require.NoError(t, err)
+
+	_, err = conn.Write(payload)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte{0x00, 0x03})
+	require.NoError(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(buf)
+	assert.Error(t, err)
+	assert.Empty(t, handled)
+}
+
+func TestServer_recoversFromPanics(t *testing.T) {
+	udpAddr, _, _ := startServer(t, &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+			if r.ID == 1 {
+				panic("boom")
+			}
+
+			addressHandler(1).ServeDNS(w, r)
+		}),
+		ErrorLog: testLogger(t),
+	})
+
+	conn, err := net.Dial("udp", udpAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	payload, err := testQuery(1, "example.com").Marshal()
+	require.NoError(t, err)
+	_, err = conn.Write(payload)
+	require.NoError(t, err)
+
+	res, err := exchange(t, "udp", udpAddr, testQuery(2, "example.com"))
+	require.NoError(t, err)
+	assert.Len(t, res.Answers, 1)
+}
+
+func TestServer_MaxConcurrent(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		current int
+		highest int
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	udpAddr, _, _ := startServer(t, &Server{
+		MaxConcurrent: 2,
+		Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+			mu.Lock()
+			current++
+			if current > highest {
+				highest = current
+			}
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			current--
+			mu.Unlock()
+
+			addressHandler(1).ServeDNS(w, r)
+		}),
+	})
+
+	for ndx := 0; ndx < 5; ndx++ {
+		wg.Add(1)
+		go func(id uint16) {
+			defer wg.Done()
+
+			res, err := exchange(t, "udp", udpAddr, testQuery(id, "example.com"))
+			if assert.NoError(t, err) {
+				assert.Equal(t, id, res.ID)
+			}
+		}(uint16(ndx))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 2, highest)
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	var (
+		started  = make(chan struct{})
+		release  = make(chan struct{})
+		shutdown = make(chan error)
+		response = make(chan *Message)
+		s        = &Server{
+			Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+				close(started)
+				<-release
+				addressHandler(1).ServeDNS(w, r)
+			}),
+		}
+	)
+
+	udpAddr, _, errs := startServer(t, s)
+
+	go func() {
+		res, err := exchange(t, "udp", udpAddr, testQuery(3, "example.com"))
+		assert.NoError(t, err)
+		response <- res
+	}()
+
+	<-started
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown didn't wait for the request being handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-shutdown)
+	assert.Equal(t, uint16(3), (<-response).ID)
+	assert.Equal(t, ErrServerClosed, <-errs)
+	assert.Equal(t, ErrServerClosed, <-errs)
+}
+
+func TestServer_Shutdown_cancelsRequests(t *testing.T) {
+	var (
+		started  = make(chan struct{})
+		canceled = make(chan struct{})
+		s        = &Server{
+			Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+				close(started)
+				<-w.Context().Done()
+				close(canceled)
+			}),
+		}
+	)
+
+	_, tcpAddr, _ := startServer(t, s)
+
+	go exchange(t, "tcp", tcpAddr, testQuery(4, "example.com"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the context of the request wasn't canceled")
+	}
+}
+
+func TestServer_closed(t *testing.T) {
+	var s = &Server{Handler: addressHandler(1)}
+
+	require.NoError(t, s.Close())
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.Equal(t, ErrServerClosed, s.ServeUDP(conn))
+}
+
+// testLogger is a logger that writes to the log of the test.
+func testLogger(t *testing.T) *log.Logger {
+	return log.New(testWriter{t}, "", 0)
+}
+
+type testWriter struct {
+	t *testing.T
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
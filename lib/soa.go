@@ -0,0 +1,157 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SOA represents the RDATA of an SOA record as described in RFC1035
+// section 3.3.13: the start of a zone of authority.
+//
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                     MNAME                     /
+//	/                                               /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                     RNAME                     /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                    SERIAL                     |
+//	|                                               |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                    REFRESH                    |
+//	|                                               |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                     RETRY                     |
+//	|                                               |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                    EXPIRE                     |
+//	|                                               |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                    MINIMUM                    |
+//	|                                               |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type SOA struct {
+
+	// MName is the name of the primary name server of the zone.
+	MName string
+
+	// RName is the mailbox of the person responsible for the
+	// zone, encoded as a domain name.
+	RName string
+
+	// Serial is the version number of the original copy of the
+	// zone.
+	Serial uint32
+
+	// Refresh is the interval (in seconds) before the zone should
+	// be refreshed.
+	Refresh uint32
+
+	// Retry is the interval (in seconds) that should elapse before
+	// a failed refresh should be retried.
+	Retry uint32
+
+	// Expire is the upper limit (in seconds) on the time interval
+	// that can elapse before the zone is no longer authoritative.
+	Expire uint32
+
+	// Minimum is the TTL used for negative responses (RFC2308).
+	Minimum uint32
+}
+
+func unmarshalSOARData(msg []byte, off, length int) (data RData, err error) {
+	var s = new(SOA)
+
+	_, err = unmarshalSOA(msg, off, off+length, s)
+	if err != nil {
+		return
+	}
+
+	data = s
+	return
+}
+
+// UnmarshalSOA decodes the RDATA of an SOA record into `s`. `msg` must
+// contain exactly the (uncompressed) RDATA of the record.
+func UnmarshalSOA(msg []byte, s *SOA) (n int, err error) {
+	return unmarshalSOA(msg, 0, len(msg), s)
+}
+
+func unmarshalSOA(msg []byte, off, end int, s *SOA) (n int, err error) {
+	if s == nil {
+		err = errors.Errorf("soa must be non-nil")
+		return
+	}
+
+	var ndx int = off
+
+	s.MName, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read mname")
+		return
+	}
+
+	ndx += n
+
+	s.RName, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read rname")
+		return
+	}
+
+	ndx += n
+
+	if end-ndx != 20 {
+		err = errors.Errorf(
+			"soa must have 20 bytes of timers after the names - %d",
+			end-ndx)
+		return
+	}
+
+	s.Serial = binary.BigEndian.Uint32(msg[ndx : ndx+4])
+	s.Refresh = binary.BigEndian.Uint32(msg[ndx+4 : ndx+8])
+	s.Retry = binary.BigEndian.Uint32(msg[ndx+8 : ndx+12])
+	s.Expire = binary.BigEndian.Uint32(msg[ndx+12 : ndx+16])
+	s.Minimum = binary.BigEndian.Uint32(msg[ndx+16 : ndx+20])
+	ndx += 20
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the RDATA of the SOA record.
+func (s SOA) Marshal() (res []byte, err error) {
+	return s.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the SOA record to `b`.
+func (s SOA) AppendTo(b []byte) (res []byte, err error) {
+	res, err = appendRDATAName(b, s.MName)
+	if err != nil {
+		return
+	}
+
+	res, err = appendRDATAName(res, s.RName)
+	if err != nil {
+		return
+	}
+
+	res = binary.BigEndian.AppendUint32(res, s.Serial)
+	res = binary.BigEndian.AppendUint32(res, s.Refresh)
+	res = binary.BigEndian.AppendUint32(res, s.Retry)
+	res = binary.BigEndian.AppendUint32(res, s.Expire)
+	res = binary.BigEndian.AppendUint32(res, s.Minimum)
+	return
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	ns.example.com. hostmaster.example.com. 2018010101 7200 3600 1209600 300
+func (s SOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d",
+		fqdn(s.MName), fqdn(s.RName),
+		s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum)
+}
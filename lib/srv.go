@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SRV represents the RDATA of an SRV record as described in RFC2782:
+// the location of the server(s) for a specific protocol and domain.
+type SRV struct {
+
+	// Priority of the target host, lower values being preferred.
+	Priority uint16
+
+	// Weight is used for selecting among targets with the same
+	// priority.
+	Weight uint16
+
+	// Port on the target host of the service.
+	Port uint16
+
+	// Target is the domain name of the target host. `.` means
+	// that the service is decidedly not available.
+	Target string
+}
+
+func unmarshalSRVRData(msg []byte, off, length int) (data RData, err error) {
+	var s = new(SRV)
+
+	_, err = unmarshalSRV(msg, off, off+length, s)
+	if err != nil {
+		return
+	}
+
+	data = s
+	return
+}
+
+// UnmarshalSRV decodes the RDATA of an SRV record into `s`. `msg` must
+// contain exactly the RDATA of the record.
+func UnmarshalSRV(msg []byte, s *SRV) (n int, err error) {
+	return unmarshalSRV(msg, 0, len(msg), s)
+}
+
+func unmarshalSRV(msg []byte, off, end int, s *SRV) (n int, err error) {
+	if s == nil {
+		err = errors.Errorf("srv must be non-nil")
+		return
+	}
+
+	var ndx int = off
+
+	if end-ndx < 6 {
+		err = errors.Errorf(
+			"srv rdata must be at least 6 bytes long - %d",
+			end-ndx)
+		return
+	}
+
+	s.Priority = binary.BigEndian.Uint16(msg[ndx : ndx+2])
+	s.Weight = binary.BigEndian.Uint16(msg[ndx+2 : ndx+4])
+	s.Port = binary.BigEndian.Uint16(msg[ndx+4 : ndx+6])
+	ndx += 6
+
+	// RFC2782 forbids compressing the target but RFC3597 asks
+	// receivers to decompress it anyway.
+	s.Target, n, err = unmarshalRDATAName(msg, ndx, end)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read target")
+		return
+	}
+
+	ndx += n
+
+	err = checkRDATAEnd(ndx, end)
+	if err != nil {
+		return
+	}
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the RDATA of the SRV record.
+func (s SRV) Marshal() (res []byte, err error) {
+	return s.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the SRV record to `b`.
+func (s SRV) AppendTo(b []byte) (res []byte, err error) {
+	res = binary.BigEndian.AppendUint16(b, s.Priority)
+	res = binary.BigEndian.AppendUint16(res, s.Weight)
+	res = binary.BigEndian.AppendUint16(res, s.Port)
+	return appendRDATAName(res, s.Target)
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	10 60 5060 bigbox.example.com.
+func (s SRV) String() string {
+	return fmt.Sprintf("%d %d %d %s",
+		s.Priority, s.Weight, s.Port, fqdn(s.Target))
+}
@@ -0,0 +1,226 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHFPAlgorithm identifies the algorithm of the public key whose
+// fingerprint is carried by the record.
+type SSHFPAlgorithm uint8
+
+const (
+	SSHFPAlgorithmReserved SSHFPAlgorithm = iota
+	SSHFPAlgorithmRSA
+	SSHFPAlgorithmDSA
+	SSHFPAlgorithmECDSA
+	SSHFPAlgorithmEd25519
+	SSHFPAlgorithmEd448 SSHFPAlgorithm = 6
+)
+
+// SSHFPType identifies the message digest used to compute the
+// fingerprint.
+type SSHFPType uint8
+
+const (
+	SSHFPTypeReserved SSHFPType = iota
+	SSHFPTypeSHA1
+	SSHFPTypeSHA256
+)
+
+// SSHFP represents the RDATA of an SSHFP record as described in
+// RFC4255 section 3.1.
+//
+//	                     1 1 1 1 1 1 1 1 1 1 2 2 2 2 2 2 2 2 2 2 3 3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|   algorithm   |    fp type    |                               /
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+                               /
+//	/                                                               /
+//	/                          fingerprint                          /
+//	/                                                               /
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type SSHFP struct {
+	Algorithm   SSHFPAlgorithm
+	Type        SSHFPType
+	Fingerprint []byte
+}
+
+func unmarshalSSHFPRData(msg []byte, off, length int) (data RData, err error) {
+	var s = new(SSHFP)
+
+	_, err = UnmarshalSSHFP(msg[off:off+length], s)
+	if err != nil {
+		return
+	}
+
+	data = s
+	return
+}
+
+// UnmarshalSSHFP decodes the RDATA of an SSHFP record into `s`. `msg`
+// must contain exactly the RDATA of the record.
+func UnmarshalSSHFP(msg []byte, s *SSHFP) (n int, err error) {
+	if s == nil {
+		err = errors.Errorf("sshfp must be non-nil")
+		return
+	}
+
+	if len(msg) < 2 {
+		err = errors.Errorf(
+			"sshfp rdata must be at least 2 bytes long - %d",
+			len(msg))
+		return
+	}
+
+	s.Algorithm = SSHFPAlgorithm(msg[0])
+	s.Type = SSHFPType(msg[1])
+	s.Fingerprint = append([]byte{}, msg[2:]...)
+
+	n = len(msg)
+	return
+}
+
+// Marshal encodes the RDATA of the SSHFP record.
+func (s SSHFP) Marshal() (res []byte, err error) {
+	var buf = new(bytes.Buffer)
+
+	buf.WriteByte(byte(s.Algorithm))
+	buf.WriteByte(byte(s.Type))
+	buf.Write(s.Fingerprint)
+
+	res = buf.Bytes()
+	return
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	4 2 C7B4A6...
+func (s SSHFP) String() string {
+	return fmt.Sprintf("%d %d %s",
+		s.Algorithm, s.Type,
+		strings.ToUpper(hex.EncodeToString(s.Fingerprint)))
+}
+
+// NewSSHFP creates the SSHFP record that corresponds to the public
+// key `key` using the digest `fpType`.
+func NewSSHFP(key ssh.PublicKey, fpType SSHFPType) (s *SSHFP, err error) {
+	var (
+		algorithm   SSHFPAlgorithm
+		fingerprint []byte
+	)
+
+	algorithm, err = sshfpAlgorithm(key)
+	if err != nil {
+		return
+	}
+
+	fingerprint, err = sshfpFingerprint(key, fpType)
+	if err != nil {
+		return
+	}
+
+	s = &SSHFP{
+		Algorithm:   algorithm,
+		Type:        fpType,
+		Fingerprint: fingerprint,
+	}
+	return
+}
+
+// Match verifies whether the record holds the fingerprint of the
+// public key `key`.
+//
+// An error is returned for fingerprint types that are not supported.
+func (s SSHFP) Match(key ssh.PublicKey) (ok bool, err error) {
+	var (
+		algorithm   SSHFPAlgorithm
+		fingerprint []byte
+	)
+
+	algorithm, err = sshfpAlgorithm(key)
+	if err != nil {
+		return
+	}
+
+	if algorithm != s.Algorithm {
+		return
+	}
+
+	fingerprint, err = sshfpFingerprint(key, s.Type)
+	if err != nil {
+		return
+	}
+
+	ok = bytes.Equal(fingerprint, s.Fingerprint)
+	return
+}
+
+// MatchSSHFP looks for a record of the SSHFP RRset `records` that
+// holds the fingerprint of the host key `key`, as an SSH client does
+// when verifying a server's host key (RFC4255 section 2.1).
+//
+// Records with fingerprint types that are not supported are skipped.
+func MatchSSHFP(records []*SSHFP, key ssh.PublicKey) (record *SSHFP, ok bool) {
+	for _, record = range records {
+		matched, err := record.Match(key)
+		if err != nil {
+			continue
+		}
+
+		if matched {
+			ok = true
+			return
+		}
+	}
+
+	record = nil
+	return
+}
+
+// sshfpAlgorithm maps the type of an SSH public key to the
+// algorithm number used by SSHFP records.
+func sshfpAlgorithm(key ssh.PublicKey) (algorithm SSHFPAlgorithm, err error) {
+	switch key.Type() {
+	case ssh.KeyAlgoRSA:
+		algorithm = SSHFPAlgorithmRSA
+	case ssh.KeyAlgoDSA:
+		algorithm = SSHFPAlgorithmDSA
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		algorithm = SSHFPAlgorithmECDSA
+	case ssh.KeyAlgoED25519:
+		algorithm = SSHFPAlgorithmEd25519
+	default:
+		err = errors.Errorf(
+			"unsupported key type %s",
+			key.Type())
+	}
+
+	return
+}
+
+// sshfpFingerprint computes the fingerprint of the public key blob
+// of `key` (as sent over the wire) using the digest `fpType`.
+func sshfpFingerprint(key ssh.PublicKey, fpType SSHFPType) (fingerprint []byte, err error) {
+	switch fpType {
+	case SSHFPTypeSHA1:
+		sum := sha1.Sum(key.Marshal())
+		fingerprint = sum[:]
+	case SSHFPTypeSHA256:
+		sum := sha256.Sum256(key.Marshal())
+		fingerprint = sum[:]
+	default:
+		err = errors.Errorf(
+			"unsupported fingerprint type %d",
+			fpType)
+	}
+
+	return
+}
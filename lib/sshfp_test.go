@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func generateSSHKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestSSHFPMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		entity    *SSHFP
+		presented string
+	}{
+		{
+			desc: "ed25519 sha256",
+			entity: &SSHFP{
+				Algorithm:   SSHFPAlgorithmEd25519,
+				Type:        SSHFPTypeSHA256,
+				Fingerprint: []byte{0xc7, 0xb4, 0xa6},
+			},
+			presented: "4 2 C7B4A6",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *SSHFP
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			require.NoError(t, err)
+
+			unmarshalled = new(SSHFP)
+			_, err = UnmarshalSSHFP(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestMatchSSHFP(t *testing.T) {
+	var (
+		key   = generateSSHKey(t)
+		other = generateSSHKey(t)
+	)
+
+	sha1Record, err := NewSSHFP(key, SSHFPTypeSHA1)
+	require.NoError(t, err)
+
+	sha256Record, err := NewSSHFP(key, SSHFPTypeSHA256)
+	require.NoError(t, err)
+
+	otherRecord, err := NewSSHFP(other, SSHFPTypeSHA256)
+	require.NoError(t, err)
+
+	record, ok := MatchSSHFP([]*SSHFP{otherRecord, sha256Record}, key)
+	require.True(t, ok)
+	assert.Equal(t, sha256Record, record)
+
+	record, ok = MatchSSHFP([]*SSHFP{sha1Record}, key)
+	require.True(t, ok)
+	assert.Equal(t, sha1Record, record)
+
+	_, ok = MatchSSHFP([]*SSHFP{otherRecord}, key)
+	assert.False(t, ok)
+
+	wrongAlgorithm := *sha256Record
+	wrongAlgorithm.Algorithm = SSHFPAlgorithmRSA
+	_, ok = MatchSSHFP([]*SSHFP{&wrongAlgorithm}, key)
+	assert.False(t, ok)
+}
@@ -0,0 +1,586 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SVCParamKey identifies the kind of a SvcParam carried by SVCB and
+// HTTPS records (RFC9460 section 14.3.2).
+type SVCParamKey uint16
+
+const (
+	// Keys that the client must support for the record to be used
+	SVCParamMandatory SVCParamKey = iota
+
+	// Application-Layer Protocol Negotiation protocol ids
+	SVCParamALPN
+
+	// The default set of protocols is not supported
+	SVCParamNoDefaultALPN
+
+	// TCP or UDP port for alternative endpoints
+	SVCParamPort
+
+	// IPv4 addresses that the client may use to reach the service
+	SVCParamIPv4Hint
+
+	// Encrypted ClientHello configuration list
+	SVCParamECH
+
+	// IPv6 addresses that the client may use to reach the service
+	SVCParamIPv6Hint
+
+	// Reserved ("Invalid key")
+	SVCParamInvalid SVCParamKey = 65535
+)
+
+var svcParamKeyNames = map[SVCParamKey]string{
+	SVCParamMandatory:     "mandatory",
+	SVCParamALPN:          "alpn",
+	SVCParamNoDefaultALPN: "no-default-alpn",
+	SVCParamPort:          "port",
+	SVCParamIPv4Hint:      "ipv4hint",
+	SVCParamECH:           "ech",
+	SVCParamIPv6Hint:      "ipv6hint",
+}
+
+// String returns the presentation name of the key, falling back to
+// the generic `keyNNNNN` form for keys without a registered name.
+func (k SVCParamKey) String() string {
+	if name, ok := svcParamKeyNames[k]; ok {
+		return name
+	}
+
+	return "key" + strconv.Itoa(int(k))
+}
+
+// SVCParam is a single key=value pair of the SvcParams list.
+//
+// Value holds the wire format of the value; the typed accessors
+// (ALPN, Port, ...) and constructors (NewSVCParamALPN, ...) take
+// care of converting it from and to its meaningful form.
+type SVCParam struct {
+	Key   SVCParamKey
+	Value []byte
+}
+
+// SVCB represents the RDATA of SVCB (64) and HTTPS (65) records
+// as described in RFC9460 section 2.2.
+//
+//	                                1  1  1  1  1  1
+//	  0  1  2  3  4  5  6  7  8  9  0  1  2  3  4  5
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	|                  SvcPriority                  |
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                  TargetName                   /
+//	/                                               /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+//	/                   SvcParams                   /
+//	/                                               /
+//	+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+--+
+type SVCB struct {
+
+	// Priority is the SvcPriority of the record. Zero indicates
+	// AliasMode while any other value indicates ServiceMode,
+	// with lower values being preferred.
+	Priority uint16
+
+	// Target is the TargetName of the record - the domain name
+	// of either the alias target (AliasMode) or of the alternative
+	// endpoint (ServiceMode). It's never compressed.
+	// `.` means the owner name of the record (ServiceMode).
+	Target string
+
+	// Params is the list of SvcParams, which must be sorted by
+	// strictly increasing key.
+	Params []SVCParam
+}
+
+// unmarshalSVCBRData decodes the RDATA of both SVCB and HTTPS records.
+func unmarshalSVCBRData(msg []byte, off, length int) (data RData, err error) {
+	var s = new(SVCB)
+
+	_, err = UnmarshalSVCB(msg[off:off+length], s)
+	if err != nil {
+		return
+	}
+
+	data = s
+	return
+}
+
+// UnmarshalSVCB decodes the RDATA of a SVCB or HTTPS record into
+// `s`. `msg` must contain exactly the RDATA of the record.
+//
+// Decoding fails if the TargetName is compressed.
+//
+// Decoding fails if the resulting SvcParams list is not valid
+// (see `SVCB.Validate`).
+func UnmarshalSVCB(msg []byte, s *SVCB) (n int, err error) {
+	if s == nil {
+		err = errors.Errorf("svcb must be non-nil")
+		return
+	}
+
+	if len(msg) < 3 {
+		err = errors.Errorf(
+			"svcb rdata must be at least 3 bytes long - %d",
+			len(msg))
+		return
+	}
+
+	var (
+		ndx    int = 0
+		length int = 0
+		key    SVCParamKey
+	)
+
+	s.Priority = binary.BigEndian.Uint16(msg[0:2])
+	ndx += 2
+
+	// TargetName must not be compressed (RFC9460 section 2.2).
+	s.Target, n, err = unmarshalUncompressedName(msg, ndx)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to read target name")
+		return
+	}
+
+	ndx += n
+
+	s.Params = nil
+	for ndx < len(msg) {
+		if len(msg)-ndx < 4 {
+			err = errors.Errorf(
+				"truncated svcparam at offset %d",
+				ndx)
+			return
+		}
+
+		key = SVCParamKey(binary.BigEndian.Uint16(msg[ndx : ndx+2]))
+		length = int(binary.BigEndian.Uint16(msg[ndx+2 : ndx+4]))
+		ndx += 4
+
+		if ndx+length > len(msg) {
+			err = errors.Errorf(
+				"value of svcparam %s goes past the end of the rdata",
+				key)
+			return
+		}
+
+		s.Params = append(s.Params, SVCParam{
+			Key:   key,
+			Value: append([]byte{}, msg[ndx:ndx+length]...),
+		})
+		ndx += length
+	}
+
+	err = s.Validate()
+	if err != nil {
+		return
+	}
+
+	n = ndx
+	return
+}
+
+// Marshal encodes the RDATA of the SVCB or HTTPS record.
+func (s SVCB) Marshal() (res []byte, err error) {
+	var (
+		buf    = new(bytes.Buffer)
+		target []byte
+	)
+
+	err = s.Validate()
+	if err != nil {
+		return
+	}
+
+	target, err = marshalName(s.Target)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed target name %s",
+			s.Target)
+		return
+	}
+
+	binary.Write(buf, binary.BigEndian, s.Priority)
+	buf.Write(target)
+
+	for _, param := range s.Params {
+		binary.Write(buf, binary.BigEndian, param.Key)
+		binary.Write(buf, binary.BigEndian, uint16(len(param.Value)))
+		buf.Write(param.Value)
+	}
+
+	res = buf.Bytes()
+	return
+}
+
+// Validate verifies that the SvcParams are sorted by strictly
+// increasing key, that each value is well formed for its key and
+// that the keys listed as mandatory are present (RFC9460 section
+// 2.2 and 8).
+func (s SVCB) Validate() (err error) {
+	var (
+		mandatory []SVCParamKey
+		present   = map[SVCParamKey]bool{}
+	)
+
+	for ndx, param := range s.Params {
+		if ndx > 0 && param.Key <= s.Params[ndx-1].Key {
+			err = errors.Errorf(
+				"svcparam keys must be in strictly increasing order - %s follows %s",
+				param.Key, s.Params[ndx-1].Key)
+			return
+		}
+
+		err = param.validate()
+		if err != nil {
+			err = errors.Wrapf(err,
+				"malformed svcparam %s",
+				param.Key)
+			return
+		}
+
+		present[param.Key] = true
+
+		if param.Key == SVCParamMandatory {
+			mandatory, _ = param.MandatoryKeys()
+		}
+	}
+
+	for _, key := range mandatory {
+		if !present[key] {
+			err = errors.Errorf(
+				"mandatory svcparam %s is missing",
+				key)
+			return
+		}
+	}
+
+	if present[SVCParamNoDefaultALPN] && !present[SVCParamALPN] {
+		err = errors.Errorf(
+			"svcparam no-default-alpn requires alpn to be set")
+		return
+	}
+
+	return
+}
+
+// Param retrieves the SvcParam with the key `key`, if present.
+func (s SVCB) Param(key SVCParamKey) (param SVCParam, ok bool) {
+	for _, param = range s.Params {
+		if param.Key == key {
+			ok = true
+			return
+		}
+	}
+
+	param = SVCParam{}
+	return
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	1 . alpn="h3,h2" ipv4hint=192.0.2.1
+func (s SVCB) String() string {
+	var fields = []string{
+		strconv.Itoa(int(s.Priority)),
+		fqdn(s.Target),
+	}
+
+	for _, param := range s.Params {
+		fields = append(fields, param.String())
+	}
+
+	return strings.Join(fields, " ")
+}
+
+func (p SVCParam) validate() (err error) {
+	switch p.Key {
+	case SVCParamMandatory:
+		var keys []SVCParamKey
+
+		keys, err = p.MandatoryKeys()
+		if err != nil {
+			return
+		}
+
+		for ndx, key := range keys {
+			if key == SVCParamMandatory {
+				err = errors.Errorf("mandatory can't list itself")
+				return
+			}
+
+			if ndx > 0 && key <= keys[ndx-1] {
+				err = errors.Errorf(
+					"keys must be in strictly increasing order - %s follows %s",
+					key, keys[ndx-1])
+				return
+			}
+		}
+	case SVCParamALPN:
+		_, err = p.ALPN()
+	case SVCParamNoDefaultALPN:
+		if len(p.Value) != 0 {
+			err = errors.Errorf("value must be empty")
+		}
+	case SVCParamPort:
+		_, err = p.Port()
+	case SVCParamIPv4Hint, SVCParamIPv6Hint:
+		_, err = p.IPHints()
+	case SVCParamECH:
+		if len(p.Value) == 0 {
+			err = errors.Errorf("value must be non-empty")
+		}
+	case SVCParamInvalid:
+		err = errors.Errorf("key is reserved")
+	}
+
+	return
+}
+
+// MandatoryKeys decodes the value of a `mandatory` SvcParam.
+func (p SVCParam) MandatoryKeys() (keys []SVCParamKey, err error) {
+	if len(p.Value) == 0 || len(p.Value)%2 != 0 {
+		err = errors.Errorf(
+			"mandatory value must be a non-empty list of 2 byte keys - %d bytes",
+			len(p.Value))
+		return
+	}
+
+	for ndx := 0; ndx < len(p.Value); ndx += 2 {
+		keys = append(keys,
+			SVCParamKey(binary.BigEndian.Uint16(p.Value[ndx:ndx+2])))
+	}
+
+	return
+}
+
+// ALPN decodes the value of an `alpn` SvcParam into the list of
+// protocol ids.
+func (p SVCParam) ALPN() (ids []string, err error) {
+	var (
+		ndx  int = 0
+		size int = 0
+	)
+
+	if len(p.Value) == 0 {
+		err = errors.Errorf("alpn value must be non-empty")
+		return
+	}
+
+	for ndx < len(p.Value) {
+		size = int(p.Value[ndx])
+		if size == 0 {
+			err = errors.Errorf("alpn ids must be non-empty")
+			return
+		}
+
+		if ndx+size+1 > len(p.Value) {
+			err = errors.Errorf(
+				"alpn id at offset %d goes past the end of the value",
+				ndx)
+			return
+		}
+
+		ids = append(ids, string(p.Value[ndx+1:ndx+size+1]))
+		ndx += size + 1
+	}
+
+	return
+}
+
+// Port decodes the value of a `port` SvcParam.
+func (p SVCParam) Port() (port uint16, err error) {
+	if len(p.Value) != 2 {
+		err = errors.Errorf(
+			"port value must be 2 bytes long - %d",
+			len(p.Value))
+		return
+	}
+
+	port = binary.BigEndian.Uint16(p.Value)
+	return
+}
+
+// IPHints decodes the value of either an `ipv4hint` or an `ipv6hint`
+// SvcParam.
+func (p SVCParam) IPHints() (ips []net.IP, err error) {
+	var size = net.IPv4len
+
+	if p.Key == SVCParamIPv6Hint {
+		size = net.IPv6len
+	}
+
+	if len(p.Value) == 0 || len(p.Value)%size != 0 {
+		err = errors.Errorf(
+			"%s value must be a non-empty list of %d byte addresses - %d bytes",
+			p.Key, size, len(p.Value))
+		return
+	}
+
+	for ndx := 0; ndx < len(p.Value); ndx += size {
+		ips = append(ips, net.IP(p.Value[ndx:ndx+size]))
+	}
+
+	return
+}
+
+// String renders the SvcParam in presentation format (`key=value`
+// or just `key` for params without a value).
+func (p SVCParam) String() string {
+	var value string
+
+	switch p.Key {
+	case SVCParamMandatory:
+		keys, err := p.MandatoryKeys()
+		if err != nil {
+			break
+		}
+
+		names := make([]string, len(keys))
+		for ndx, key := range keys {
+			names[ndx] = key.String()
+		}
+
+		value = strings.Join(names, ",")
+	case SVCParamALPN:
+		ids, err := p.ALPN()
+		if err != nil {
+			break
+		}
+
+		// ids are first escaped as elements of a comma-separated
+		// list and then the list as a character-string.
+		escaped := make([]string, len(ids))
+		for ndx, id := range ids {
+			id = strings.Replace(id, `\`, `\\`, -1)
+			escaped[ndx] = strings.Replace(id, `,`, `\,`, -1)
+		}
+
+		value = quoteCharacterString([]byte(strings.Join(escaped, ",")))
+	case SVCParamPort:
+		port, err := p.Port()
+		if err != nil {
+			break
+		}
+
+		value = strconv.Itoa(int(port))
+	case SVCParamIPv4Hint, SVCParamIPv6Hint:
+		ips, err := p.IPHints()
+		if err != nil {
+			break
+		}
+
+		addrs := make([]string, len(ips))
+		for ndx, ip := range ips {
+			addrs[ndx] = ip.String()
+		}
+
+		value = strings.Join(addrs, ",")
+	case SVCParamECH:
+		value = base64.StdEncoding.EncodeToString(p.Value)
+	}
+
+	if value == "" && len(p.Value) > 0 {
+		value = quoteCharacterString(p.Value)
+	}
+
+	if value == "" {
+		return p.Key.String()
+	}
+
+	return fmt.Sprintf("%s=%s", p.Key, value)
+}
+
+// NewSVCParamMandatory creates a `mandatory` SvcParam listing `keys`.
+func NewSVCParamMandatory(keys ...SVCParamKey) SVCParam {
+	var value = make([]byte, 2*len(keys))
+
+	for ndx, key := range keys {
+		binary.BigEndian.PutUint16(value[2*ndx:], uint16(key))
+	}
+
+	return SVCParam{Key: SVCParamMandatory, Value: value}
+}
+
+// NewSVCParamALPN creates an `alpn` SvcParam with the protocol ids
+// `ids`.
+func NewSVCParamALPN(ids ...string) SVCParam {
+	var buf = new(bytes.Buffer)
+
+	for _, id := range ids {
+		buf.WriteByte(uint8(len(id)))
+		buf.WriteString(id)
+	}
+
+	return SVCParam{Key: SVCParamALPN, Value: buf.Bytes()}
+}
+
+// NewSVCParamNoDefaultALPN creates a `no-default-alpn` SvcParam.
+func NewSVCParamNoDefaultALPN() SVCParam {
+	return SVCParam{Key: SVCParamNoDefaultALPN, Value: []byte{}}
+}
+
+// NewSVCParamPort creates a `port` SvcParam.
+func NewSVCParamPort(port uint16) SVCParam {
+	var value = make([]byte, 2)
+
+	binary.BigEndian.PutUint16(value, port)
+
+	return SVCParam{Key: SVCParamPort, Value: value}
+}
+
+// NewSVCParamIPv4Hint creates an `ipv4hint` SvcParam, failing if any
+// of the addresses is not an IPv4 address.
+func NewSVCParamIPv4Hint(ips ...net.IP) (p SVCParam, err error) {
+	p.Key = SVCParamIPv4Hint
+
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			err = errors.Errorf(
+				"%s is not an ipv4 address",
+				ip)
+			return
+		}
+
+		p.Value = append(p.Value, ip.To4()...)
+	}
+
+	return
+}
+
+// NewSVCParamECH creates an `ech` SvcParam carrying the ECHConfigList
+// `config`.
+func NewSVCParamECH(config []byte) SVCParam {
+	return SVCParam{Key: SVCParamECH, Value: config}
+}
+
+// NewSVCParamIPv6Hint creates an `ipv6hint` SvcParam, failing if any
+// of the addresses is not an IPv6 address.
+func NewSVCParamIPv6Hint(ips ...net.IP) (p SVCParam, err error) {
+	p.Key = SVCParamIPv6Hint
+
+	for _, ip := range ips {
+		if ip.To16() == nil || ip.To4() != nil {
+			err = errors.Errorf(
+				"%s is not an ipv6 address",
+				ip)
+			return
+		}
+
+		p.Value = append(p.Value, ip.To16()...)
+	}
+
+	return
+}
@@ -0,0 +1,234 @@
+package lib
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustIPv4Hint(ips ...string) SVCParam {
+	var addrs []net.IP
+
+	for _, ip := range ips {
+		addrs = append(addrs, net.ParseIP(ip))
+	}
+
+	param, err := NewSVCParamIPv4Hint(addrs...)
+	if err != nil {
+		panic(err)
+	}
+
+	return param
+}
+
+func TestSVCBMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		entity     *SVCB
+		presented  string
+		shouldFail bool
+	}{
+		{
+			desc: "alias mode",
+			entity: &SVCB{
+				Priority: 0,
+				Target:   "foo.example.com",
+			},
+			presented: "0 foo.example.com.",
+		},
+		{
+			desc: "service mode with root target",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+			},
+			presented: "1 .",
+		},
+		{
+			desc: "service mode with params",
+			entity: &SVCB{
+				Priority: 16,
+				Target:   "foo.example.org",
+				Params: []SVCParam{
+					NewSVCParamMandatory(SVCParamALPN, SVCParamIPv4Hint),
+					NewSVCParamALPN("h2", "h3-19"),
+					NewSVCParamNoDefaultALPN(),
+					NewSVCParamPort(8443),
+					mustIPv4Hint("192.0.2.1", "192.0.2.2"),
+					NewSVCParamECH([]byte{0xde, 0xad}),
+				},
+			},
+			presented: `16 foo.example.org. mandatory=alpn,ipv4hint alpn="h2,h3-19" no-default-alpn port=8443 ipv4hint=192.0.2.1,192.0.2.2 ech=3q0=`,
+		},
+		{
+			desc: "escaped alpn and unknown key",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   "foo.example.com",
+				Params: []SVCParam{
+					NewSVCParamALPN(`f\oo,bar`, "h2"),
+					{Key: 667, Value: []byte("hello\x00")},
+				},
+			},
+			presented: `1 foo.example.com. alpn="f\\\\oo\\,bar,h2" key667="hello\000"`,
+		},
+		{
+			desc: "keys out of order",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					NewSVCParamPort(53),
+					NewSVCParamALPN("h2"),
+				},
+			},
+			shouldFail: true,
+		},
+		{
+			desc: "duplicate keys",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					NewSVCParamPort(53),
+					NewSVCParamPort(54),
+				},
+			},
+			shouldFail: true,
+		},
+		{
+			desc: "missing mandatory key",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					NewSVCParamMandatory(SVCParamPort),
+					NewSVCParamALPN("h2"),
+				},
+			},
+			shouldFail: true,
+		},
+		{
+			desc: "mandatory listing itself",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					NewSVCParamMandatory(SVCParamMandatory),
+				},
+			},
+			shouldFail: true,
+		},
+		{
+			desc: "no-default-alpn without alpn",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					NewSVCParamNoDefaultALPN(),
+				},
+			},
+			shouldFail: true,
+		},
+		{
+			desc: "malformed port",
+			entity: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					{Key: SVCParamPort, Value: []byte{1}},
+				},
+			},
+			shouldFail: true,
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *SVCB
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			unmarshalled = new(SVCB)
+			_, err = UnmarshalSVCB(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity.Priority, unmarshalled.Priority)
+			assert.Equal(t, tc.entity.Target, unmarshalled.Target)
+			assert.Equal(t, len(tc.entity.Params), len(unmarshalled.Params))
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestSVCBUnmarshalFromMessage(t *testing.T) {
+	var (
+		rr = &RR{
+			NAME:  "example.com",
+			TYPE:  QTypeHTTPS,
+			CLASS: QClassIN,
+			TTL:   300,
+			Data: &SVCB{
+				Priority: 1,
+				Target:   ".",
+				Params: []SVCParam{
+					NewSVCParamALPN("h3", "h2"),
+				},
+			},
+		}
+		unmarshalled = new(RR)
+	)
+
+	msg, err := rr.Marshal()
+	require.NoError(t, err)
+
+	_, err = UnmarshalRR(msg, unmarshalled)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com", unmarshalled.NAME)
+	assert.Equal(t, QTypeHTTPS, unmarshalled.TYPE)
+	assert.Equal(t, uint32(300), unmarshalled.TTL)
+
+	record, ok := unmarshalled.Data.(*SVCB)
+	require.True(t, ok)
+
+	ids, err := record.Params[0].ALPN()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"h3", "h2"}, ids)
+}
+
+func TestUnmarshalSVCB_compressedTarget(t *testing.T) {
+	var testCases = []struct {
+		desc string
+		msg  []byte
+	}{
+		{
+			desc: "pointer as the whole target",
+			msg:  []byte{0x00, 0x01, 0xc0, 0x00},
+		},
+		{
+			desc: "pointer after a label",
+			msg:  []byte{0x00, 0x01, 0x03, 'f', 'o', 'o', 0xc0, 0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := UnmarshalSVCB(tc.msg, new(SVCB))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "compression pointer")
+		})
+	}
+}
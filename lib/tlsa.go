@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TLSAUsage specifies the provided association that will be used to
+// match the certificate presented in the TLS handshake.
+type TLSAUsage uint8
+
+const (
+	// CA constraint - the certificate must be in the PKIX
+	// validated chain.
+	TLSAUsagePKIXTA TLSAUsage = iota
+
+	// Service certificate constraint - the end entity must
+	// match and pass PKIX validation.
+	TLSAUsagePKIXEE
+
+	// Trust anchor assertion - the certificate is a trust
+	// anchor for the chain.
+	TLSAUsageDANETA
+
+	// Domain-issued certificate - the end entity must match,
+	// no PKIX validation takes place.
+	TLSAUsageDANEEE
+)
+
+// TLSASelector specifies which part of the certificate is matched
+// against the association data.
+type TLSASelector uint8
+
+const (
+	// Full certificate
+	TLSASelectorCert TLSASelector = iota
+
+	// SubjectPublicKeyInfo
+	TLSASelectorSPKI
+)
+
+// TLSAMatchingType specifies how the association data is presented.
+type TLSAMatchingType uint8
+
+const (
+	// Exact match on selected content
+	TLSAMatchingTypeFull TLSAMatchingType = iota
+
+	// SHA-256 hash of selected content
+	TLSAMatchingTypeSHA256
+
+	// SHA-512 hash of selected content
+	TLSAMatchingTypeSHA512
+)
+
+// TLSA represents the RDATA of a TLSA record as described in RFC6698
+// section 2.1.
+//
+//	                     1 1 1 1 1 1 1 1 1 1 2 2 2 2 2 2 2 2 2 2 3 3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|  Cert. Usage  |   Selector    | Matching Type |               /
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+               /
+//	/                                                               /
+//	/                 Certificate Association Data                  /
+//	/                                                               /
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type TLSA struct {
+	Usage        TLSAUsage
+	Selector     TLSASelector
+	MatchingType TLSAMatchingType
+
+	// CertificateAssociation is either the raw selected content or
+	// its hash, depending on the matching type.
+	CertificateAssociation []byte
+}
+
+func unmarshalTLSARData(msg []byte, off, length int) (data RData, err error) {
+	var t = new(TLSA)
+
+	_, err = UnmarshalTLSA(msg[off:off+length], t)
+	if err != nil {
+		return
+	}
+
+	data = t
+	return
+}
+
+// UnmarshalTLSA decodes the RDATA of a TLSA record into `t`. `msg`
+// must contain exactly the RDATA of the record.
+func UnmarshalTLSA(msg []byte, t *TLSA) (n int, err error) {
+	if t == nil {
+		err = errors.Errorf("tlsa must be non-nil")
+		return
+	}
+
+	if len(msg) < 3 {
+		err = errors.Errorf(
+			"tlsa rdata must be at least 3 bytes long - %d",
+			len(msg))
+		return
+	}
+
+	t.Usage = TLSAUsage(msg[0])
+	t.Selector = TLSASelector(msg[1])
+	t.MatchingType = TLSAMatchingType(msg[2])
+	t.CertificateAssociation = append([]byte{}, msg[3:]...)
+
+	n = len(msg)
+	return
+}
+
+// Marshal encodes the RDATA of the TLSA record.
+func (t TLSA) Marshal() (res []byte, err error) {
+	var buf = new(bytes.Buffer)
+
+	buf.WriteByte(byte(t.Usage))
+	buf.WriteByte(byte(t.Selector))
+	buf.WriteByte(byte(t.MatchingType))
+	buf.Write(t.CertificateAssociation)
+
+	res = buf.Bytes()
+	return
+}
+
+// String renders the RDATA in presentation format, e.g.:
+//
+//	3 1 1 0D6FCE...
+func (t TLSA) String() string {
+	return fmt.Sprintf("%d %d %d %s",
+		t.Usage, t.Selector, t.MatchingType,
+		strings.ToUpper(hex.EncodeToString(t.CertificateAssociation)))
+}
+
+// Match verifies whether the certificate `cert` corresponds to the
+// association data of the record, taking into account the selector
+// and the matching type (but not the usage - see `MatchTLSA`).
+//
+// An error is returned for selectors and matching types that are
+// not supported.
+func (t TLSA) Match(cert *x509.Certificate) (ok bool, err error) {
+	var content []byte
+
+	switch t.Selector {
+	case TLSASelectorCert:
+		content = cert.Raw
+	case TLSASelectorSPKI:
+		content = cert.RawSubjectPublicKeyInfo
+	default:
+		err = errors.Errorf(
+			"unsupported selector %d",
+			t.Selector)
+		return
+	}
+
+	switch t.MatchingType {
+	case TLSAMatchingTypeFull:
+	case TLSAMatchingTypeSHA256:
+		sum := sha256.Sum256(content)
+		content = sum[:]
+	case TLSAMatchingTypeSHA512:
+		sum := sha512.Sum512(content)
+		content = sum[:]
+	default:
+		err = errors.Errorf(
+			"unsupported matching type %d",
+			t.MatchingType)
+		return
+	}
+
+	ok = bytes.Equal(content, t.CertificateAssociation)
+	return
+}
+
+// MatchTLSA looks for a record of the TLSA RRset `records` that
+// matches the certificate chain `chain` presented by a TLS server,
+// with the end entity certificate first.
+//
+// End entity usages (PKIX-EE and DANE-EE) are matched against the
+// first certificate while trust anchor usages (PKIX-TA and DANE-TA)
+// are matched against the rest of the chain. Records with usages,
+// selectors or matching types that are not supported are skipped as
+// unusable (RFC6698 section 4.1).
+//
+// ps.: for the PKIX usages the caller is still responsible for
+// performing the PKIX validation of the chain.
+func MatchTLSA(records []*TLSA, chain []*x509.Certificate) (record *TLSA, ok bool) {
+	var candidates []*x509.Certificate
+
+	if len(chain) == 0 {
+		return
+	}
+
+	for _, record = range records {
+		switch record.Usage {
+		case TLSAUsagePKIXEE, TLSAUsageDANEEE:
+			candidates = chain[:1]
+		case TLSAUsagePKIXTA, TLSAUsageDANETA:
+			candidates = chain[1:]
+		default:
+			continue
+		}
+
+		for _, cert := range candidates {
+			matched, err := record.Match(cert)
+			if err != nil {
+				break
+			}
+
+			if matched {
+				ok = true
+				return
+			}
+		}
+	}
+
+	record = nil
+	return
+}
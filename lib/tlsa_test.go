@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateCertificate(t *testing.T, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func TestTLSAMarshallingAndUnmarshalling(t *testing.T) {
+	var testCases = []struct {
+		desc      string
+		entity    *TLSA
+		presented string
+	}{
+		{
+			desc: "dane-ee spki sha256",
+			entity: &TLSA{
+				Usage:                  TLSAUsageDANEEE,
+				Selector:               TLSASelectorSPKI,
+				MatchingType:           TLSAMatchingTypeSHA256,
+				CertificateAssociation: []byte{0x0d, 0x6f, 0xce},
+			},
+			presented: "3 1 1 0D6FCE",
+		},
+	}
+
+	var (
+		msg          []byte
+		err          error
+		unmarshalled *TLSA
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			msg, err = tc.entity.Marshal()
+			require.NoError(t, err)
+
+			unmarshalled = new(TLSA)
+			_, err = UnmarshalTLSA(msg, unmarshalled)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.entity, unmarshalled)
+			assert.Equal(t, tc.presented, unmarshalled.String())
+		})
+	}
+}
+
+func TestMatchTLSA(t *testing.T) {
+	var (
+		leaf       = generateCertificate(t, "mail.example.com")
+		issuer     = generateCertificate(t, "ca.example.com")
+		other      = generateCertificate(t, "other.example.com")
+		spkiSHA256 = sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+		certSHA512 = sha512.Sum512(issuer.Raw)
+	)
+
+	var testCases = []struct {
+		desc    string
+		records []*TLSA
+		chain   []*x509.Certificate
+		matched int
+	}{
+		{
+			desc: "dane-ee matches leaf",
+			records: []*TLSA{
+				{TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingTypeSHA256, spkiSHA256[:]},
+			},
+			chain:   []*x509.Certificate{leaf, issuer},
+			matched: 0,
+		},
+		{
+			desc: "dane-ee does not match other leaf",
+			records: []*TLSA{
+				{TLSAUsageDANEEE, TLSASelectorSPKI, TLSAMatchingTypeSHA256, spkiSHA256[:]},
+			},
+			chain:   []*x509.Certificate{other, issuer},
+			matched: -1,
+		},
+		{
+			desc: "dane-ta matches issuer but not leaf",
+			records: []*TLSA{
+				{TLSAUsageDANETA, TLSASelectorSPKI, TLSAMatchingTypeSHA256, spkiSHA256[:]},
+				{TLSAUsageDANETA, TLSASelectorCert, TLSAMatchingTypeSHA512, certSHA512[:]},
+			},
+			chain:   []*x509.Certificate{leaf, issuer},
+			matched: 1,
+		},
+		{
+			desc: "full certificate",
+			records: []*TLSA{
+				{TLSAUsagePKIXEE, TLSASelectorCert, TLSAMatchingTypeFull, leaf.Raw},
+			},
+			chain:   []*x509.Certificate{leaf},
+			matched: 0,
+		},
+		{
+			desc: "unusable records are skipped",
+			records: []*TLSA{
+				{TLSAUsageDANEEE, TLSASelectorSPKI, 200, spkiSHA256[:]},
+				{200, TLSASelectorSPKI, TLSAMatchingTypeSHA256, spkiSHA256[:]},
+			},
+			chain:   []*x509.Certificate{leaf},
+			matched: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			record, ok := MatchTLSA(tc.records, tc.chain)
+			if tc.matched < 0 {
+				assert.False(t, ok)
+				assert.Nil(t, record)
+				return
+			}
+
+			require.True(t, ok)
+			assert.Equal(t, tc.records[tc.matched], record)
+		})
+	}
+}
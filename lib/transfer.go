@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Transfer fetches the records of the zone `zone` from the server with
+// a full zone transfer over TCP (RFC5936), returning them as
+// `AXFRZone` does: starting with the SOA of the zone, which isn't
+// repeated at the end.
+//
+// The records may span any number of messages, which are read until
+// the one carrying the SOA that closes the transfer. Each of them must
+// arrive within the timeout of the client.
+func (c *Client) Transfer(ctx context.Context, zone string) (rrs []*RR, err error) {
+	var (
+		dialer   = net.Dialer{Timeout: c.timeout}
+		conn     net.Conn
+		payload  []byte
+		length   [2]byte
+		received []*RR
+		done     bool
+	)
+
+	query := withCounts(&Message{
+		Header: Header{Opcode: OpcodeQuery},
+		Questions: []*Question{
+			{QNAME: zone, QTYPE: QTypeAXFR, QCLASS: QClassIN},
+		},
+	})
+
+	query.ID, err = randomID()
+	if err != nil {
+		return
+	}
+
+	payload, err = query.Marshal()
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to marshal axfr query for %s",
+			zone)
+		return
+	}
+
+	conn, err = dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to create connection to address %s",
+			c.address)
+		return
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	conn.SetWriteDeadline(time.Now().Add(c.timeout))
+
+	_, err = conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(payload))), payload...))
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to write axfr query for %s",
+			zone)
+		return
+	}
+
+	for !done {
+		conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+		// every message gets a buffer of its own, as the records
+		// decoded from it may keep referencing it (e.g., `RDATA`).
+		_, err = io.ReadFull(conn, length[:])
+		if err == nil {
+			payload = make([]byte, binary.BigEndian.Uint16(length[:]))
+			_, err = io.ReadFull(conn, payload)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+
+			err = errors.Wrapf(err,
+				"failed to read from conn after %d records",
+				len(received))
+			return
+		}
+
+		res := new(Message)
+
+		err = UnmarshalMessage(payload, res)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"failed to read message")
+			return
+		}
+
+		// only the first message of a transfer is required to
+		// carry the question (RFC5936 section 2.2).
+		if res.ID != query.ID || res.QR != 1 || (len(res.Questions) > 0 && !isResponseTo(res, &query)) {
+			err = errors.Errorf(
+				"response %d doesn't match axfr query %d for %s",
+				res.ID, query.ID, zone)
+			return
+		}
+
+		if res.RCODE != byte(RCODENoError) {
+			err = errors.Errorf(
+				"transfer of %s failed with %s",
+				zone, RCODE(res.RCODE))
+			return
+		}
+
+		for _, rr := range res.Answers {
+			if done {
+				err = errors.Errorf(
+					"records of %s after the soa closing its transfer",
+					zone)
+				return
+			}
+
+			received = append(received, rr)
+			done = len(received) > 1 && rr.TYPE == QTypeSOA
+		}
+
+		if len(received) > 0 && received[0].TYPE != QTypeSOA {
+			err = errors.Errorf(
+				"transfer of %s doesn't start with its soa",
+				zone)
+			return
+		}
+	}
+
+	rrs, err = AXFRZone(received)
+	return
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transferHandler answers with `records`, `per` of them per message,
+// only the first message carrying the question.
+func transferHandler(records []*RR, per int) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Message) {
+		for ndx := 0; ndx < len(records); ndx += per {
+			res := NewReply(r)
+			if ndx > 0 {
+				res.Questions = nil
+			}
+
+			res.Answers = records[ndx:min(ndx+per, len(records))]
+			w.WriteMsg(res)
+		}
+	})
+}
+
+func TestClient_Transfer(t *testing.T) {
+	var (
+		from, to = parseDiffZones(t)
+
+		// stream copies the records so that the cases don't share
+		// their backing arrays.
+		stream = func(rrs []*RR, more ...*RR) []*RR {
+			return append(append([]*RR{}, rrs...), more...)
+		}
+	)
+
+	var testCases = []struct {
+		desc       string
+		handler    Handler
+		shouldFail bool
+	}{
+		{
+			desc:    "one message",
+			handler: transferHandler(stream(from, from[0]), len(from)+1),
+		},
+		{
+			desc:    "one message per record",
+			handler: transferHandler(stream(from, from[0]), 1),
+		},
+		{
+			desc:    "a few records per message",
+			handler: transferHandler(stream(from, from[0]), 3),
+		},
+		{
+			desc:       "refused",
+			handler:    ErrorHandler(RCODERefused),
+			shouldFail: true,
+		},
+		{
+			desc:       "not starting with the soa",
+			handler:    transferHandler(stream(from[1:], from[0]), 1),
+			shouldFail: true,
+		},
+		{
+			desc:       "records after the closing soa",
+			handler:    transferHandler(stream(from, from[0], to[1]), len(from)+2),
+			shouldFail: true,
+		},
+		{
+			desc:       "closing soa never arrives",
+			handler:    transferHandler(from, 2),
+			shouldFail: true,
+		},
+		{
+			desc:       "closing soa of another version",
+			handler:    transferHandler(stream(from, to[0]), 2),
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			client, err := NewClient(ClientConfig{
+				Address: startUpstream(t, tc.handler),
+				Timeout: 200 * time.Millisecond,
+			})
+			require.NoError(t, err)
+
+			rrs, err := client.Transfer(context.Background(), "example.com")
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, len(from), len(rrs))
+
+			delta := DiffZone(from, rrs)
+			assert.Empty(t, delta.Deleted)
+			assert.Empty(t, delta.Added)
+			assert.True(t, delta.OldSOA.Equal(delta.NewSOA))
+		})
+	}
+}
+
+func TestClient_Transfer_keepsRecordsOfEveryMessage(t *testing.T) {
+	rrs, err := ParseZone(strings.NewReader(`
+$TTL 300
+@		SOA	ns hostmaster 1 7200 900 604800 300
+		NS	ns
+ns		A	192.0.2.1
+_443._tcp.www	TLSA	3 1 1 0D6FCE0D6FCE
+ns		SSHFP	4 2 CAFECAFE
+www		HTTPS	1 . alpn="h2,h3" port=443
+www		TXT	"after all of them"
+`), "example.com", "zone")
+	require.NoError(t, err)
+
+	client, err := NewClient(ClientConfig{
+		Address: startUpstream(t, transferHandler(append(rrs, rrs[0]), 1)),
+		Timeout: time.Second,
+	})
+	require.NoError(t, err)
+
+	transferred, err := client.Transfer(context.Background(), "example.com")
+	require.NoError(t, err)
+	require.Len(t, transferred, len(rrs))
+
+	for ndx, rr := range rrs {
+		assert.Equal(t, rr.String(), transferred[ndx].String())
+		assert.Equal(t, rr.RDATA, transferred[ndx].RDATA)
+	}
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TXT represents the RDATA of a TXT record as described in RFC1035
+// section 3.3.14: one or more <character-string>s.
+type TXT struct {
+	Strings []string
+}
+
+func unmarshalTXTRData(msg []byte, off, length int) (data RData, err error) {
+	var t = new(TXT)
+
+	_, err = unmarshalTXT(msg, off, off+length, t)
+	if err != nil {
+		return
+	}
+
+	data = t
+	return
+}
+
+// UnmarshalTXT decodes the RDATA of a TXT record into `t`. `msg` must
+// contain exactly the RDATA of the record.
+func UnmarshalTXT(msg []byte, t *TXT) (n int, err error) {
+	return unmarshalTXT(msg, 0, len(msg), t)
+}
+
+func unmarshalTXT(msg []byte, off, end int, t *TXT) (n int, err error) {
+	if t == nil {
+		err = errors.Errorf("txt must be non-nil")
+		return
+	}
+
+	var (
+		ndx   int = off
+		field []byte
+	)
+
+	if ndx >= end {
+		err = errors.Errorf("txt rdata must be non-empty")
+		return
+	}
+
+	t.Strings = nil
+	for ndx < end {
+		field, n, err = unmarshalCharacterString(msg, ndx, end)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"failed to read string %d",
+				len(t.Strings))
+			return
+		}
+
+		t.Strings = append(t.Strings, string(field))
+		ndx += n
+	}
+
+	n = ndx - off
+	return
+}
+
+// Marshal encodes the RDATA of the TXT record.
+func (t TXT) Marshal() (res []byte, err error) {
+	return t.AppendTo(nil)
+}
+
+// AppendTo appends the RDATA of the TXT record to `b`.
+func (t TXT) AppendTo(b []byte) (res []byte, err error) {
+	if len(t.Strings) == 0 {
+		err = errors.Errorf("txt must have at least one string")
+		return
+	}
+
+	res = b
+	for ndx, s := range t.Strings {
+		res, err = appendCharacterString(res, s)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"malformed string %d",
+				ndx)
+			return
+		}
+	}
+
+	return
+}
+
+// String renders the RDATA in presentation format - each string
+// quoted and escaped, separated by spaces.
+func (t TXT) String() string {
+	var quoted = make([]string, len(t.Strings))
+
+	for ndx, s := range t.Strings {
+		quoted[ndx] = quoteCharacterString([]byte(s))
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UnknownRDATA represents the RDATA of records whose type the library
+// doesn't know, as described in RFC3597.
+//
+// The RDATA is kept as an opaque sequence of octets so that it can be
+// forwarded without any loss: it's never compressed (nor decompressed)
+// and gets re-marshalled exactly as it was received.
+type UnknownRDATA struct {
+	Raw []byte
+}
+
+// compressibleTypes are the RFC1035 types whose RDATA may contain
+// compressed names (RFC3597 section 4). Only these can't be treated
+// as opaque as their RDATA doesn't make sense outside the original
+// message: they all have a typed representation, and are never kept
+// as *UnknownRDATA.
+var compressibleTypes = map[QType]bool{
+	QTypeNS:    true,
+	QTypeMD:    true,
+	QTypeMF:    true,
+	QTypeCNAME: true,
+	QTypeSOA:   true,
+	QTypeMB:    true,
+	QTypeMG:    true,
+	QTypeMR:    true,
+	QTypePTR:   true,
+	QTypeMINFO: true,
+	QTypeMX:    true,
+}
+
+func unmarshalUnknownRData(msg []byte, off, length int) (data RData, err error) {
+	var u = new(UnknownRDATA)
+
+	_, err = UnmarshalUnknownRDATA(msg[off:off+length], u)
+	if err != nil {
+		return
+	}
+
+	data = u
+	return
+}
+
+// UnmarshalUnknownRDATA copies the RDATA `msg` into `u`.
+func UnmarshalUnknownRDATA(msg []byte, u *UnknownRDATA) (n int, err error) {
+	if u == nil {
+		err = errors.Errorf("unknown rdata must be non-nil")
+		return
+	}
+
+	u.Raw = make([]byte, len(msg))
+	copy(u.Raw, msg)
+
+	n = len(msg)
+	return
+}
+
+// ParseUnknownRDATA parses the generic presentation format of RDATA
+// (RFC3597 section 5):
+//
+//	\# <length> <hex> [<hex> ...]
+//
+// where the hexadecimal data may be broken up into multiple words and
+// must decode to exactly `length` octets.
+func ParseUnknownRDATA(s string) (u *UnknownRDATA, err error) {
+	var (
+		fields = strings.Fields(s)
+		length int
+		raw    []byte
+	)
+
+	if len(fields) < 2 || fields[0] != `\#` {
+		err = errors.Errorf(
+			"generic rdata must start with \\# and a length - %s",
+			s)
+		return
+	}
+
+	length, err = strconv.Atoi(fields[1])
+	if err != nil || length < 0 || length > 0xffff {
+		err = errors.Errorf(
+			"malformed generic rdata length %s",
+			fields[1])
+		return
+	}
+
+	raw, err = hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed generic rdata hex data")
+		return
+	}
+
+	if len(raw) != length {
+		err = errors.Errorf(
+			"generic rdata length %d does not match data length %d",
+			length, len(raw))
+		return
+	}
+
+	u = &UnknownRDATA{Raw: raw}
+	return
+}
+
+// Marshal returns the RDATA exactly as it was received.
+func (u UnknownRDATA) Marshal() (res []byte, err error) {
+	res = u.Raw
+	return
+}
+
+// String renders the RDATA in the generic presentation format, e.g.:
+//
+//	\# 4 0A000001
+func (u UnknownRDATA) String() string {
+	var buf = new(bytes.Buffer)
+
+	buf.WriteString(`\# `)
+	buf.WriteString(strconv.Itoa(len(u.Raw)))
+
+	if len(u.Raw) > 0 {
+		buf.WriteByte(' ')
+		buf.WriteString(strings.ToUpper(hex.EncodeToString(u.Raw)))
+	}
+
+	return buf.String()
+}
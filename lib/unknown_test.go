@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownRDATAParsing(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		presented  string
+		raw        []byte
+		shouldFail bool
+	}{
+		{
+			desc:      "empty",
+			presented: `\# 0`,
+			raw:       []byte{},
+		},
+		{
+			desc:      "single word",
+			presented: `\# 4 0A000001`,
+			raw:       []byte{10, 0, 0, 1},
+		},
+		{
+			desc:      "multiple words",
+			presented: `\# 4 0a00 00 01`,
+			raw:       []byte{10, 0, 0, 1},
+		},
+		{
+			desc:       "missing marker",
+			presented:  `4 0A000001`,
+			shouldFail: true,
+		},
+		{
+			desc:       "length mismatch",
+			presented:  `\# 3 0A000001`,
+			shouldFail: true,
+		},
+		{
+			desc:       "malformed hex",
+			presented:  `\# 2 0G00`,
+			shouldFail: true,
+		},
+		{
+			desc:       "odd number of digits",
+			presented:  `\# 1 0A0`,
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			u, err := ParseUnknownRDATA(tc.presented)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.raw, u.Raw)
+
+			reparsed, err := ParseUnknownRDATA(u.String())
+			require.NoError(t, err)
+			assert.Equal(t, u, reparsed)
+		})
+	}
+}
+
+func TestUnknownRDATAIsPreservedVerbatim(t *testing.T) {
+	// A record of the private use type 65280 whose RDATA happens
+	// to look like a compression pointer.
+	var msg = []byte{
+		3, 'f', 'o', 'o', 0,
+		0xff, 0x00, 0, 1, 0, 0, 0, 60, 0, 3,
+		0xc0, 0x00, 0x2a,
+	}
+
+	var rr = new(RR)
+
+	_, err := UnmarshalRR(msg, rr)
+	require.NoError(t, err)
+
+	u, ok := rr.Data.(*UnknownRDATA)
+	require.True(t, ok)
+	assert.Equal(t, []byte{0xc0, 0x00, 0x2a}, u.Raw)
+	assert.Equal(t, `\# 3 C0002A`, u.String())
+
+	marshalled, err := rr.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, msg, marshalled)
+}
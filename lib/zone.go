@@ -0,0 +1,715 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxZoneIncludeDepth bounds how deep `$INCLUDE` directives can be
+// nested so that a file including itself can't recurse forever.
+const maxZoneIncludeDepth = 16
+
+// maxZoneGenerate bounds the number of records that a single
+// `$GENERATE` directive can produce.
+const maxZoneGenerate = 65536
+
+// ZoneParseError is returned by the zone parser when an entry of a
+// master file can't be parsed. It carries the position of the entry
+// so that it can be fixed.
+type ZoneParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ZoneParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// ZoneParser reads resource records from a master file (RFC1035
+// section 5) one at a time.
+//
+// Besides resource records, it understands the `$ORIGIN`, `$TTL`
+// (RFC2308), `$INCLUDE` and `$GENERATE` (BIND) directives as well as
+// the generic `TYPENNN`, `CLASSNNN` and `\#` syntaxes (RFC3597).
+type ZoneParser struct {
+	lexer *zoneLexer
+	file  string
+	depth int
+
+	origin string
+
+	// defaultTTL is the TTL set by `$TTL`, used by records that
+	// don't specify one.
+	defaultTTL    uint32
+	hasDefaultTTL bool
+
+	// last* keep the values of the previous record, used when
+	// the owner, TTL or class are omitted.
+	lastOwner    string
+	hasLastOwner bool
+	lastTTL      uint32
+	hasLastTTL   bool
+	lastClass    QClass
+
+	// include is the parser of the file currently being included.
+	include *ZoneParser
+	closer  io.Closer
+
+	// pending holds the records produced by a `$GENERATE` that
+	// were not consumed yet.
+	pending []*RR
+}
+
+// NewZoneParser creates a parser that reads the master file from `r`.
+//
+// `origin` is the initial origin, used to complete relative names
+// (e.g. `example.com`), while `file` is the name of the file being
+// read, used in errors and to resolve relative `$INCLUDE` paths.
+func NewZoneParser(r io.Reader, origin, file string) *ZoneParser {
+	return &ZoneParser{
+		lexer:     newZoneLexer(r),
+		file:      file,
+		origin:    strings.TrimSuffix(origin, "."),
+		lastClass: QClassIN,
+	}
+}
+
+// ParseZone reads all of the resource records of the master file
+// read from `r`. See `NewZoneParser`.
+func ParseZone(r io.Reader, origin, file string) (rrs []*RR, err error) {
+	var (
+		parser = NewZoneParser(r, origin, file)
+		rr     *RR
+	)
+
+	for {
+		rr, err = parser.Next()
+		if err == io.EOF {
+			err = nil
+			return
+		}
+
+		if err != nil {
+			return
+		}
+
+		rrs = append(rrs, rr)
+	}
+}
+
+// ParseZoneFile reads all of the resource records of the master file
+// at `path`. See `NewZoneParser`.
+func ParseZoneFile(path, origin string) (rrs []*RR, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to open zone file %s",
+			path)
+		return
+	}
+	defer file.Close()
+
+	rrs, err = ParseZone(file, origin, path)
+	return
+}
+
+// Next returns the next resource record of the master file, or
+// io.EOF if there are no more records.
+//
+// Parsing errors are returned as *ZoneParseError.
+func (p *ZoneParser) Next() (rr *RR, err error) {
+	var entry *zoneEntry
+
+	for {
+		if p.include != nil {
+			rr, err = p.include.Next()
+			if err != io.EOF {
+				return
+			}
+
+			p.closer.Close()
+			p.include, p.closer = nil, nil
+			continue
+		}
+
+		if len(p.pending) > 0 {
+			rr = p.pending[0]
+			p.pending = p.pending[1:]
+			return
+		}
+
+		entry, err = p.lexer.next()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			err = p.errorf(p.lexer.line, err)
+			return
+		}
+
+		if strings.HasPrefix(entry.tokens[0].text, "$") && !entry.blankOwner {
+			err = p.directive(entry)
+			if err != nil {
+				err = p.errorf(entry.line, err)
+				return
+			}
+
+			continue
+		}
+
+		rr, err = p.record(entry)
+		if err != nil {
+			err = p.errorf(entry.line, err)
+			return
+		}
+
+		return
+	}
+}
+
+func (p *ZoneParser) errorf(line int, err error) error {
+	if _, ok := err.(*ZoneParseError); ok {
+		return err
+	}
+
+	return &ZoneParseError{
+		File: p.file,
+		Line: line,
+		Err:  err,
+	}
+}
+
+// directive handles the `$` control entries.
+func (p *ZoneParser) directive(entry *zoneEntry) (err error) {
+	var (
+		name = strings.ToUpper(entry.tokens[0].text)
+		args = entry.tokens[1:]
+	)
+
+	switch name {
+	case "$ORIGIN":
+		if len(args) != 1 {
+			err = errors.Errorf("$ORIGIN takes exactly one domain name")
+			return
+		}
+
+		p.origin, err = parseZoneName(args[0].text, p.origin)
+	case "$TTL":
+		if len(args) != 1 {
+			err = errors.Errorf("$TTL takes exactly one ttl")
+			return
+		}
+
+		p.defaultTTL, err = parseTTL(args[0].text)
+		p.hasDefaultTTL = err == nil
+	case "$INCLUDE":
+		err = p.includeFile(args)
+	case "$GENERATE":
+		err = p.generate(entry)
+	default:
+		err = errors.Errorf("unknown directive %s", entry.tokens[0].text)
+	}
+
+	return
+}
+
+// includeFile starts reading the records of the file referenced by an
+// `$INCLUDE <file> [<origin>]` directive. The origin of the including
+// file is not affected by the included one.
+func (p *ZoneParser) includeFile(args []zoneToken) (err error) {
+	var (
+		path   string
+		origin = p.origin
+		file   *os.File
+	)
+
+	if len(args) < 1 || len(args) > 2 {
+		err = errors.Errorf("$INCLUDE takes a file name and an optional origin")
+		return
+	}
+
+	if p.depth >= maxZoneIncludeDepth {
+		err = errors.Errorf(
+			"$INCLUDE nested more than %d levels deep",
+			maxZoneIncludeDepth)
+		return
+	}
+
+	path = args[0].text
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.file), path)
+	}
+
+	if len(args) == 2 {
+		origin, err = parseZoneName(args[1].text, p.origin)
+		if err != nil {
+			return
+		}
+	}
+
+	file, err = os.Open(path)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"failed to open included file")
+		return
+	}
+
+	p.include = NewZoneParser(file, origin, path)
+	p.include.depth = p.depth + 1
+	p.include.defaultTTL, p.include.hasDefaultTTL = p.defaultTTL, p.hasDefaultTTL
+	p.include.lastTTL, p.include.hasLastTTL = p.lastTTL, p.hasLastTTL
+	p.include.lastClass = p.lastClass
+	p.closer = file
+
+	return
+}
+
+// generate expands a BIND `$GENERATE` directive:
+//
+//	$GENERATE <start>-<stop>[/<step>] <lhs> [<ttl>] [<class>] <type> <rhs>
+//
+// where `$` in the owner (lhs) and rdata (rhs) is replaced by the
+// iterator, optionally modified as in `${offset,width,base}`.
+func (p *ZoneParser) generate(entry *zoneEntry) (err error) {
+	var (
+		args                     = entry.tokens[1:]
+		start, stop, step uint64 = 0, 0, 1
+		bounds            []string
+		generated         *RR
+	)
+
+	if len(args) < 4 {
+		err = errors.Errorf("$GENERATE takes a range, an owner, a type and rdata")
+		return
+	}
+
+	bounds = strings.SplitN(args[0].text, "/", 2)
+	if len(bounds) == 2 {
+		step, err = strconv.ParseUint(bounds[1], 10, 32)
+		if err != nil || step == 0 {
+			err = errors.Errorf("malformed $GENERATE step %s", bounds[1])
+			return
+		}
+	}
+
+	bounds = strings.SplitN(bounds[0], "-", 2)
+	if len(bounds) != 2 {
+		err = errors.Errorf("malformed $GENERATE range %s", args[0].text)
+		return
+	}
+
+	start, err = strconv.ParseUint(bounds[0], 10, 32)
+	if err == nil {
+		stop, err = strconv.ParseUint(bounds[1], 10, 32)
+	}
+
+	if err != nil || start > stop {
+		err = errors.Errorf("malformed $GENERATE range %s", args[0].text)
+		return
+	}
+
+	if (stop-start)/step >= maxZoneGenerate {
+		err = errors.Errorf(
+			"$GENERATE range %s produces more than %d records",
+			args[0].text, maxZoneGenerate)
+		return
+	}
+
+	for i := start; i <= stop; i += step {
+		var tokens = make([]zoneToken, len(args)-1)
+
+		for ndx, token := range args[1:] {
+			token.text, err = expandGenerate(token.text, int64(i))
+			if err != nil {
+				return
+			}
+
+			tokens[ndx] = token
+		}
+
+		generated, err = p.record(&zoneEntry{
+			tokens: tokens,
+			line:   entry.line,
+		})
+		if err != nil {
+			return
+		}
+
+		p.pending = append(p.pending, generated)
+	}
+
+	return
+}
+
+// expandGenerate replaces the `$` and `${offset[,width[,base]]}`
+// references of a `$GENERATE` template by the iterator `i`. `\$`
+// (or `$$`) stands for a literal dollar sign.
+func expandGenerate(template string, i int64) (res string, err error) {
+	var buf strings.Builder
+
+	for ndx := 0; ndx < len(template); ndx++ {
+		c := template[ndx]
+
+		switch {
+		case c == '\\' && ndx+1 < len(template) && template[ndx+1] == '$':
+			buf.WriteByte('$')
+			ndx++
+		case c == '$' && ndx+1 < len(template) && template[ndx+1] == '$':
+			buf.WriteByte('$')
+			ndx++
+		case c == '$' && ndx+1 < len(template) && template[ndx+1] == '{':
+			end := strings.IndexByte(template[ndx:], '}')
+			if end < 0 {
+				err = errors.Errorf("unterminated modifier in %s", template)
+				return
+			}
+
+			var formatted string
+			formatted, err = formatGenerate(template[ndx+2:ndx+end], i)
+			if err != nil {
+				return
+			}
+
+			buf.WriteString(formatted)
+			ndx += end
+		case c == '$':
+			buf.WriteString(strconv.FormatInt(i, 10))
+		case c == '\\' && ndx+1 < len(template):
+			buf.WriteByte(c)
+			buf.WriteByte(template[ndx+1])
+			ndx++
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	res = buf.String()
+	return
+}
+
+// formatGenerate applies a `offset[,width[,base]]` modifier.
+func formatGenerate(modifier string, i int64) (res string, err error) {
+	var (
+		parts  = strings.Split(modifier, ",")
+		offset int64
+		width  int64
+		base   = "d"
+	)
+
+	if len(parts) > 3 {
+		err = errors.Errorf("malformed modifier %s", modifier)
+		return
+	}
+
+	offset, err = strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		err = errors.Errorf("malformed modifier offset %s", parts[0])
+		return
+	}
+
+	if len(parts) > 1 {
+		width, err = strconv.ParseInt(parts[1], 10, 8)
+		if err != nil || width < 0 {
+			err = errors.Errorf("malformed modifier width %s", parts[1])
+			return
+		}
+	}
+
+	if len(parts) > 2 {
+		base = parts[2]
+	}
+
+	switch base {
+	case "d", "o", "x", "X":
+	default:
+		err = errors.Errorf("unsupported modifier base %s", base)
+		return
+	}
+
+	res = fmt.Sprintf("%0*"+base, width, i+offset)
+	return
+}
+
+// record parses a resource record entry:
+//
+//	[<owner>] [<ttl>] [<class>] <type> <rdata>
+//
+// with TTL and class possibly swapped.
+func (p *ZoneParser) record(entry *zoneEntry) (rr *RR, err error) {
+	var (
+		tokens   = entry.tokens
+		hasTTL   bool
+		hasClass bool
+		value    uint32
+		class    QClass
+	)
+
+	rr = &RR{CLASS: p.lastClass}
+
+	if entry.blankOwner {
+		if !p.hasLastOwner {
+			err = errors.Errorf("record without owner")
+			return
+		}
+
+		rr.NAME = p.lastOwner
+	} else {
+		rr.NAME, err = parseZoneName(tokens[0].text, p.origin)
+		if err != nil {
+			err = errors.Wrapf(err, "malformed owner")
+			return
+		}
+
+		tokens = tokens[1:]
+	}
+
+	for len(tokens) > 0 {
+		if !hasTTL && isTTL(tokens[0].text) {
+			value, err = parseTTL(tokens[0].text)
+			if err != nil {
+				return
+			}
+
+			rr.TTL, hasTTL = value, true
+			tokens = tokens[1:]
+			continue
+		}
+
+		if !hasClass {
+			class, err = ParseQClass(tokens[0].text)
+			if err == nil {
+				rr.CLASS, hasClass = class, true
+				tokens = tokens[1:]
+				continue
+			}
+
+			err = nil
+		}
+
+		break
+	}
+
+	if len(tokens) == 0 {
+		err = errors.Errorf("missing type")
+		return
+	}
+
+	rr.TYPE, err = ParseQType(tokens[0].text)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case hasTTL:
+	case p.hasDefaultTTL:
+		rr.TTL = p.defaultTTL
+	case p.hasLastTTL:
+		rr.TTL = p.lastTTL
+	default:
+		err = errors.Errorf("missing ttl and no $TTL set")
+		return
+	}
+
+	rr.Data, err = parseRDATA(rr.TYPE, tokens[1:], p.origin)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"malformed %s rdata",
+			rr.TYPE)
+		return
+	}
+
+	rr.RDATA, err = rr.Data.Marshal()
+	if err != nil {
+		err = errors.Wrapf(err,
+			"invalid %s rdata",
+			rr.TYPE)
+		return
+	}
+
+	if len(rr.RDATA) > 0xffff {
+		err = errors.Errorf("rdata exceeds 65535 octets")
+		return
+	}
+
+	rr.RDLENGTH = uint16(len(rr.RDATA))
+
+	p.lastOwner, p.hasLastOwner = rr.NAME, true
+	p.lastTTL, p.hasLastTTL = rr.TTL, true
+	p.lastClass = rr.CLASS
+
+	return
+}
+
+// isTTL tells whether a field of a record is a TTL rather than a class
+// or a type - TTLs always start with a digit.
+func isTTL(s string) bool {
+	return len(s) > 0 && s[0] >= '0' && s[0] <= '9'
+}
+
+// parseTTL parses a TTL given either as a number of seconds or using
+// BIND's units (e.g., `1h30m`, `1w`).
+func parseTTL(s string) (ttl uint32, err error) {
+	var (
+		total   uint64
+		current uint64
+		digits  bool
+	)
+
+	if s == "" {
+		err = errors.Errorf("empty ttl")
+		return
+	}
+
+	for ndx := 0; ndx < len(s); ndx++ {
+		c := s[ndx]
+
+		if c >= '0' && c <= '9' {
+			current = current*10 + uint64(c-'0')
+			digits = true
+			if current > 1<<32 {
+				err = errors.Errorf("ttl %s out of range", s)
+				return
+			}
+
+			continue
+		}
+
+		if !digits {
+			err = errors.Errorf("malformed ttl %s", s)
+			return
+		}
+
+		switch c {
+		case 's', 'S':
+		case 'm', 'M':
+			current *= 60
+		case 'h', 'H':
+			current *= 60 * 60
+		case 'd', 'D':
+			current *= 60 * 60 * 24
+		case 'w', 'W':
+			current *= 60 * 60 * 24 * 7
+		default:
+			err = errors.Errorf("malformed ttl %s", s)
+			return
+		}
+
+		total += current
+		current, digits = 0, false
+	}
+
+	total += current
+
+	// RFC2181 section 8
+	if total > 1<<31-1 {
+		err = errors.Errorf("ttl %s out of range", s)
+		return
+	}
+
+	ttl = uint32(total)
+	return
+}
+
+// parseZoneName parses a domain name as found in master files, taking
+// care of `@` (the origin) and of completing relative names with
+// `origin`. The name is returned as the library's structs hold it.
+func parseZoneName(s, origin string) (name string, err error) {
+	var (
+		n, suffix Name
+		absolute  bool
+	)
+
+	// the origin is taken as it is rather than made absolute by
+	// appending a dot, which would break the root (`.`).
+	if s == "@" {
+		n, err = ParseName(origin)
+		if err != nil {
+			return
+		}
+
+		name = n.text()
+		return
+	}
+
+	n, absolute, err = parseName(s)
+	if err != nil {
+		return
+	}
+
+	if !absolute {
+		suffix, err = ParseName(origin)
+		if err != nil {
+			return
+		}
+
+		n, err = n.Concat(suffix)
+		if err != nil {
+			return
+		}
+	}
+
+	name = n.text()
+	return
+}
+
+// unescapeByte decodes the escape sequence (`\X` or `\DDD`) at the
+// start of `s`, returning the byte it represents and the length of
+// the sequence.
+func unescapeByte(s string) (c byte, n int, err error) {
+	if len(s) < 2 || s[0] != '\\' {
+		err = errors.Errorf("malformed escape sequence %s", s)
+		return
+	}
+
+	if s[1] < '0' || s[1] > '9' {
+		c, n = s[1], 2
+		return
+	}
+
+	if len(s) < 4 {
+		err = errors.Errorf("malformed escape sequence %s", s)
+		return
+	}
+
+	value, err := strconv.ParseUint(s[1:4], 10, 8)
+	if err != nil {
+		err = errors.Errorf("malformed escape sequence %s", s[:4])
+		return
+	}
+
+	c, n = byte(value), 4
+	return
+}
+
+// unescape decodes all of the escape sequences of `s`.
+func unescape(s string) (res []byte, err error) {
+	var (
+		c byte
+		n int
+	)
+
+	for ndx := 0; ndx < len(s); ndx++ {
+		if s[ndx] != '\\' {
+			res = append(res, s[ndx])
+			continue
+		}
+
+		c, n, err = unescapeByte(s[ndx:])
+		if err != nil {
+			return
+		}
+
+		res = append(res, c)
+		ndx += n - 1
+	}
+
+	return
+}
@@ -0,0 +1,264 @@
+package lib
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// ZoneDelta is the set of changes that turn a version of a zone into
+// another one.
+//
+// SOA records are not part of `Deleted` and `Added`, being kept in
+// `OldSOA` and `NewSOA` instead as they delimit the changes in both
+// dynamic updates and incremental zone transfers.
+type ZoneDelta struct {
+	OldSOA *RR
+	NewSOA *RR
+
+	// Deleted holds the records that are in the old version of
+	// the zone but not in the new one.
+	Deleted []*RR
+
+	// Added holds the records that are in the new version of the
+	// zone but not in the old one.
+	Added []*RR
+}
+
+// DiffZone computes the minimal delta that turns the records of the
+// zone `from` into those of `to`, comparing records with `RR.Equal`.
+//
+// Given that all the records of an RRset must share the same TTL, a
+// change to the TTL of an RRset replaces all of its records. Records
+// are listed in the order in which they appear in each version.
+func DiffZone(from, to []*RR) (delta *ZoneDelta) {
+	var (
+		oldSets, oldKeys = groupRRsets(from)
+		newSets, newKeys = groupRRsets(to)
+	)
+
+	delta = new(ZoneDelta)
+
+	if soas := oldSets[soaKey(oldKeys)]; len(soas) > 0 {
+		delta.OldSOA = soas[0]
+	}
+
+	if soas := newSets[soaKey(newKeys)]; len(soas) > 0 {
+		delta.NewSOA = soas[0]
+	}
+
+	for _, key := range oldKeys {
+		if key.qtype == QTypeSOA {
+			continue
+		}
+
+		delta.Deleted = append(delta.Deleted,
+			rrsetDifference(oldSets[key], newSets[key])...)
+	}
+
+	for _, key := range newKeys {
+		if key.qtype == QTypeSOA {
+			continue
+		}
+
+		delta.Added = append(delta.Added,
+			rrsetDifference(newSets[key], oldSets[key])...)
+	}
+
+	return
+}
+
+// groupRRsets groups records by RRset, keeping the order in which
+// each RRset first appears.
+func groupRRsets(rrs []*RR) (sets map[rrsetKey][]*RR, keys []rrsetKey) {
+	sets = map[rrsetKey][]*RR{}
+
+	for _, rr := range rrs {
+		key := rrsetKey{canonicalName(rr.NAME), rr.TYPE, rr.CLASS}
+		if _, ok := sets[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		sets[key] = append(sets[key], rr)
+	}
+
+	return
+}
+
+// soaKey finds the RRset of SOA records (there should be only one in
+// a zone).
+func soaKey(keys []rrsetKey) rrsetKey {
+	for _, key := range keys {
+		if key.qtype == QTypeSOA {
+			return key
+		}
+	}
+
+	return rrsetKey{}
+}
+
+// rrsetDifference returns the records of `a` that are not in `b`, or
+// all of them if the TTL of the RRset changed.
+func rrsetDifference(a, b []*RR) (res []*RR) {
+	if len(b) > 0 && a[0].TTL != b[0].TTL {
+		return a
+	}
+
+	for _, rr := range a {
+		if !containsRR(b, rr) {
+			res = append(res, rr)
+		}
+	}
+
+	return
+}
+
+func containsRR(rrs []*RR, rr *RR) bool {
+	for _, candidate := range rrs {
+		if candidate.Equal(rr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Empty tells whether the delta has no changes at all.
+func (d ZoneDelta) Empty() bool {
+	return len(d.Deleted) == 0 && len(d.Added) == 0 && !d.soaChanged()
+}
+
+func (d ZoneDelta) soaChanged() bool {
+	if d.OldSOA == nil || d.NewSOA == nil {
+		return d.OldSOA != d.NewSOA
+	}
+
+	return !d.OldSOA.Equal(d.NewSOA) || d.OldSOA.TTL != d.NewSOA.TTL
+}
+
+// String renders the delta in a diff-like format, with deleted records
+// prefixed by `-` and added ones by `+`, e.g.:
+//
+//	-example.com.	300	IN	SOA	ns.example.com. hostmaster.example.com. 1 7200 900 604800 300
+//	+example.com.	300	IN	SOA	ns.example.com. hostmaster.example.com. 2 7200 900 604800 300
+//	-www.example.com.	300	IN	A	192.0.2.1
+//	+www.example.com.	300	IN	A	192.0.2.2
+func (d ZoneDelta) String() string {
+	var buf = new(bytes.Buffer)
+
+	line := func(prefix byte, rr *RR) {
+		buf.WriteByte(prefix)
+		buf.WriteString(rr.String())
+		buf.WriteByte('\n')
+	}
+
+	if d.soaChanged() {
+		if d.OldSOA != nil {
+			line('-', d.OldSOA)
+		}
+
+		if d.NewSOA != nil {
+			line('+', d.NewSOA)
+		}
+	}
+
+	for _, rr := range d.Deleted {
+		line('-', rr)
+	}
+
+	for _, rr := range d.Added {
+		line('+', rr)
+	}
+
+	return buf.String()
+}
+
+// UpdateMessage creates a dynamic update (RFC2136) that applies the
+// delta to the zone `zone`.
+//
+// Deleted records are sent as deletions of single RRs (class NONE)
+// followed by the additions, including the new SOA when it changed.
+// The ID of the message is left for the caller to set.
+func (d ZoneDelta) UpdateMessage(zone string) (m *Message, err error) {
+	var (
+		class   = QClassIN
+		updates []*RR
+	)
+
+	if d.NewSOA != nil {
+		class = d.NewSOA.CLASS
+	}
+
+	for _, rr := range d.Deleted {
+		deletion := *rr
+		deletion.CLASS = QClassNone
+		deletion.TTL = 0
+
+		updates = append(updates, &deletion)
+	}
+
+	updates = append(updates, d.Added...)
+
+	if d.soaChanged() && d.NewSOA != nil {
+		updates = append(updates, d.NewSOA)
+	}
+
+	if len(updates) > 0xffff {
+		err = errors.Errorf(
+			"too many updates for a single message - %d",
+			len(updates))
+		return
+	}
+
+	m = &Message{
+		Header: Header{
+			Opcode:  OpcodeUpdate,
+			QDCOUNT: 1,
+			NSCOUNT: uint16(len(updates)),
+		},
+		Questions: []*Question{
+			{
+				QNAME:  zone,
+				QTYPE:  QTypeSOA,
+				QCLASS: class,
+			},
+		},
+		Authorities: updates,
+	}
+
+	return
+}
+
+// IXFR renders the delta as the sequence of records of an incremental
+// zone transfer (RFC1995 section 4): the new SOA, followed by the old
+// SOA and the deleted records, then the new SOA and the added ones and
+// finally the new SOA again.
+func (d ZoneDelta) IXFR() (rrs []*RR, err error) {
+	if d.OldSOA == nil || d.NewSOA == nil {
+		err = errors.Errorf(
+			"both versions of the zone must have a SOA record")
+		return
+	}
+
+	rrs = append(rrs, d.NewSOA, d.OldSOA)
+	rrs = append(rrs, d.Deleted...)
+	rrs = append(rrs, d.NewSOA)
+	rrs = append(rrs, d.Added...)
+	rrs = append(rrs, d.NewSOA)
+
+	return
+}
+
+// AXFRZone extracts the records of a zone from the records received
+// in a full zone transfer (RFC5936 section 2.2), which starts and ends
+// with the SOA of the zone.
+func AXFRZone(rrs []*RR) (zone []*RR, err error) {
+	if len(rrs) < 2 || rrs[0].TYPE != QTypeSOA || !rrs[0].Equal(rrs[len(rrs)-1]) {
+		err = errors.Errorf(
+			"zone transfer must start and end with the same SOA record")
+		return
+	}
+
+	zone = rrs[:len(rrs)-1]
+	return
+}
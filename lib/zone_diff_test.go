@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	diffFrom = `
+$TTL 300
+@	SOA	ns hostmaster 1 7200 900 604800 300
+	NS	ns
+ns	A	192.0.2.1
+www	A	192.0.2.1
+www	A	192.0.2.2
+mail	60	A	192.0.2.3
+old	TXT	"gone"
+`
+	diffTo = `
+$TTL 300
+@	SOA	ns hostmaster 2 7200 900 604800 300
+	NS	NS.EXAMPLE.COM.
+ns	A	192.0.2.1
+www	A	192.0.2.2
+www	A	192.0.2.4
+mail	120	A	192.0.2.3
+new	TXT	"here"
+`
+)
+
+func parseDiffZones(t *testing.T) (from, to []*RR) {
+	from, err := ParseZone(strings.NewReader(diffFrom), "example.com", "from")
+	require.NoError(t, err)
+
+	to, err = ParseZone(strings.NewReader(diffTo), "example.com", "to")
+	require.NoError(t, err)
+
+	return
+}
+
+func TestDiffZone(t *testing.T) {
+	from, to := parseDiffZones(t)
+
+	delta := DiffZone(from, to)
+	require.False(t, delta.Empty())
+
+	assert.Equal(t, strings.Join([]string{
+		"-example.com.\t300\tIN\tSOA\tns.example.com. hostmaster.example.com. 1 7200 900 604800 300",
+		"+example.com.\t300\tIN\tSOA\tns.example.com. hostmaster.example.com. 2 7200 900 604800 300",
+		"-www.example.com.\t300\tIN\tA\t192.0.2.1",
+		"-mail.example.com.\t60\tIN\tA\t192.0.2.3",
+		"-old.example.com.\t300\tIN\tTXT\t\"gone\"",
+		"+www.example.com.\t300\tIN\tA\t192.0.2.4",
+		"+mail.example.com.\t120\tIN\tA\t192.0.2.3",
+		"+new.example.com.\t300\tIN\tTXT\t\"here\"",
+	}, "\n")+"\n", delta.String())
+
+	assert.True(t, DiffZone(from, from).Empty())
+}
+
+func TestZoneDelta_UpdateMessage(t *testing.T) {
+	from, to := parseDiffZones(t)
+
+	m, err := DiffZone(from, to).UpdateMessage("example.com")
+	require.NoError(t, err)
+
+	assert.Equal(t, OpcodeUpdate, m.Opcode)
+	require.Len(t, m.Questions, 1)
+	assert.Equal(t, QTypeSOA, m.Questions[0].QTYPE)
+	require.Len(t, m.Authorities, 7)
+	assert.Equal(t, uint16(7), m.NSCOUNT)
+
+	assert.Equal(t, "www.example.com.\t0\tNONE\tA\t192.0.2.1", m.Authorities[0].String())
+	assert.Equal(t, "example.com.\t300\tIN\tSOA\tns.example.com. hostmaster.example.com. 2 7200 900 604800 300", m.Authorities[6].String())
+
+	msg, err := m.Marshal()
+	require.NoError(t, err)
+
+	unmarshalled := new(Message)
+	require.NoError(t, UnmarshalMessage(msg, unmarshalled))
+	assert.Equal(t, OpcodeUpdate, unmarshalled.Opcode)
+	require.Len(t, unmarshalled.Authorities, 7)
+	assert.Equal(t, QClassNone, unmarshalled.Authorities[0].CLASS)
+}
+
+func TestZoneDelta_IXFR(t *testing.T) {
+	from, to := parseDiffZones(t)
+	delta := DiffZone(from, to)
+
+	rrs, err := delta.IXFR()
+	require.NoError(t, err)
+	require.Len(t, rrs, 10)
+
+	assert.Equal(t, delta.NewSOA, rrs[0])
+	assert.Equal(t, delta.OldSOA, rrs[1])
+	assert.Equal(t, delta.NewSOA, rrs[5])
+	assert.Equal(t, delta.NewSOA, rrs[9])
+
+	_, err = DiffZone(from[1:], to).IXFR()
+	require.Error(t, err)
+}
+
+func TestAXFRZone(t *testing.T) {
+	from, _ := parseDiffZones(t)
+
+	zone, err := AXFRZone(append(from, from[0]))
+	require.NoError(t, err)
+	assert.Equal(t, from, zone)
+
+	_, err = AXFRZone(from)
+	require.Error(t, err)
+}
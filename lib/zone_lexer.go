@@ -0,0 +1,224 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// zoneToken is a single whitespace-delimited field of a master file
+// entry. Escape sequences are kept as they appear in the file so that
+// each kind of field (names, character-strings, ...) can interpret
+// them accordingly.
+type zoneToken struct {
+	text string
+
+	// quoted indicates whether the token was a quoted string, in
+	// which case `text` doesn't include the quotes.
+	quoted bool
+}
+
+// zoneEntry is a logical line of a master file: a directive or a
+// resource record, possibly spanning multiple physical lines through
+// the use of parentheses.
+type zoneEntry struct {
+	tokens []zoneToken
+
+	// line is the line at which the entry starts.
+	line int
+
+	// blankOwner indicates that the entry started with whitespace,
+	// meaning that the owner is the last stated one.
+	blankOwner bool
+}
+
+// zoneLexer splits a master file into entries, taking care of
+// comments, quotes, escapes and parentheses (RFC1035 section 5.1).
+type zoneLexer struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newZoneLexer(r io.Reader) *zoneLexer {
+	return &zoneLexer{
+		r:    bufio.NewReader(r),
+		line: 1,
+	}
+}
+
+// next reads the next non-empty entry, returning io.EOF once there
+// are no more entries.
+func (l *zoneLexer) next() (entry *zoneEntry, err error) {
+	var (
+		c              byte
+		tok            = new(bytes.Buffer)
+		inToken        bool
+		inQuotes       bool
+		tokQuoted      bool
+		parens         int
+		lineStart      = true
+		lineIndented   bool
+		parensOpenedAt int
+	)
+
+	entry = new(zoneEntry)
+
+	flush := func() {
+		if !inToken {
+			return
+		}
+
+		entry.tokens = append(entry.tokens, zoneToken{
+			text:   tok.String(),
+			quoted: tokQuoted,
+		})
+
+		tok.Reset()
+		inToken = false
+		tokQuoted = false
+	}
+
+	start := func() {
+		if inToken {
+			return
+		}
+
+		if len(entry.tokens) == 0 {
+			entry.line = l.line
+			entry.blankOwner = lineIndented
+		}
+
+		inToken = true
+	}
+
+	for {
+		c, err = l.r.ReadByte()
+		if err == io.EOF {
+			if inQuotes {
+				err = errors.Errorf("unterminated quoted string")
+				return
+			}
+
+			if parens > 0 {
+				err = errors.Errorf(
+					"unbalanced parentheses opened at line %d",
+					parensOpenedAt)
+				return
+			}
+
+			flush()
+			if len(entry.tokens) > 0 {
+				err = nil
+				return
+			}
+
+			entry = nil
+			return
+		}
+
+		if err != nil {
+			return
+		}
+
+		if lineStart {
+			lineIndented = c == ' ' || c == '\t'
+			lineStart = false
+		}
+
+		if inQuotes {
+			switch c {
+			case '\\':
+				tok.WriteByte(c)
+				c, err = l.r.ReadByte()
+				if err != nil {
+					err = errors.Errorf("unterminated escape sequence")
+					return
+				}
+
+				tok.WriteByte(c)
+			case '"':
+				inQuotes = false
+				if tokQuoted {
+					flush()
+				} else {
+					tok.WriteByte(c)
+				}
+			case '\n':
+				err = errors.Errorf("unterminated quoted string")
+				return
+			default:
+				tok.WriteByte(c)
+			}
+
+			continue
+		}
+
+		switch c {
+		case ';':
+			flush()
+			for err == nil && c != '\n' {
+				c, err = l.r.ReadByte()
+			}
+
+			// leave the EOF to be found by the next read
+			if err == io.EOF {
+				err = nil
+				continue
+			}
+
+			if err != nil {
+				return
+			}
+
+			l.r.UnreadByte()
+		case '\\':
+			start()
+			tok.WriteByte(c)
+			c, err = l.r.ReadByte()
+			if err != nil {
+				err = errors.Errorf("unterminated escape sequence")
+				return
+			}
+
+			tok.WriteByte(c)
+		case '"':
+			if !inToken {
+				start()
+				tokQuoted = true
+			} else {
+				tok.WriteByte(c)
+			}
+
+			inQuotes = true
+		case '(':
+			flush()
+			if parens == 0 {
+				parensOpenedAt = l.line
+			}
+
+			parens++
+		case ')':
+			flush()
+			parens--
+			if parens < 0 {
+				err = errors.Errorf("unbalanced parentheses")
+				return
+			}
+		case ' ', '\t', '\r':
+			flush()
+		case '\n':
+			flush()
+			l.line++
+			lineStart = true
+
+			if parens == 0 && len(entry.tokens) > 0 {
+				return
+			}
+		default:
+			start()
+			tok.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LintSeverity tells how bad a problem found in a zone is.
+type LintSeverity uint8
+
+const (
+	// The zone works but doesn't follow best practices
+	LintWarning LintSeverity = iota
+
+	// The zone is broken and shouldn't be served
+	LintError
+)
+
+func (s LintSeverity) String() string {
+	if s == LintError {
+		return "error"
+	}
+
+	return "warning"
+}
+
+// LintProblem is an issue found in the contents of a zone.
+type LintProblem struct {
+	Severity LintSeverity
+
+	// Name is the owner name of the records that have the problem.
+	Name string
+
+	// Type is the type of the records that have the problem, if
+	// the problem concerns a single RRset.
+	Type QType
+
+	Message string
+}
+
+func (p LintProblem) String() string {
+	if p.Type == 0 {
+		return fmt.Sprintf("%s: %s: %s", p.Severity, fqdn(p.Name), p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s %s: %s", p.Severity, fqdn(p.Name), p.Type, p.Message)
+}
+
+// cnameCompanions are the types of the DNSSEC records that may share
+// their owner with a CNAME record (RFC4035 section 2.5): its RRSIG and
+// NSEC records, along with the NSEC3 records of hashed owner names
+// (RFC5155).
+var cnameCompanions = map[QType]bool{
+	46: true, // RRSIG
+	47: true, // NSEC
+	50: true, // NSEC3
+}
+
+// LintZone checks the records of the zone whose apex is `origin` for
+// common mistakes:
+//
+//   - CNAME records sharing the owner with other data (RFC1034
+//     section 3.6.2), DNSSEC records aside (RFC4035 section 2.5);
+//   - a missing SOA or more than one at the apex (RFC1035 section 5.2);
+//   - NS records whose target is in the zone but has no address
+//     records (glue);
+//   - records that don't belong to the zone;
+//   - MX and SRV records whose target is an alias (RFC2181 section
+//     10.3);
+//   - records of the same RRset with different TTLs (RFC2181 section
+//     5.2);
+//   - names that exceed the label and total length limits.
+//
+// Problems are sorted by name and type.
+func LintZone(origin string, rrs []*RR) (problems []LintProblem) {
+	var (
+		apex    = canonicalName(origin)
+		class   = QClassIN
+		owners  = map[string][]*RR{}
+		rrsets  = map[rrsetKey][]*RR{}
+		reports = map[string]bool{}
+	)
+
+	report := func(severity LintSeverity, name string, t QType, format string, args ...interface{}) {
+		problem := LintProblem{
+			Severity: severity,
+			Name:     name,
+			Type:     t,
+			Message:  fmt.Sprintf(format, args...),
+		}
+
+		if reports[problem.String()] {
+			return
+		}
+
+		reports[problem.String()] = true
+		problems = append(problems, problem)
+	}
+
+	// the zone is of the class of its records, which starts with
+	// the SOA in master files.
+	if len(rrs) > 0 {
+		class = rrs[0].CLASS
+	}
+
+	for _, rr := range rrs {
+		name := canonicalName(rr.NAME)
+
+		if _, err := marshalName(rr.NAME); err != nil {
+			report(LintError, rr.NAME, 0, "invalid name: %s", err)
+			continue
+		}
+
+		if !isSubdomain(name, apex) {
+			report(LintError, rr.NAME, rr.TYPE,
+				"out of zone data (zone is %s)", fqdn(apex))
+			continue
+		}
+
+		owners[name] = append(owners[name], rr)
+
+		key := rrsetKey{name, rr.TYPE, rr.CLASS}
+		rrsets[key] = append(rrsets[key], rr)
+
+		for _, target := range rdataNames(rr.Data) {
+			if _, err := marshalName(target); err != nil {
+				report(LintError, rr.NAME, rr.TYPE,
+					"invalid target %s: %s", target, err)
+			}
+		}
+	}
+
+	soas := rrsets[rrsetKey{apex, QTypeSOA, class}]
+	switch {
+	case len(soas) == 0:
+		report(LintError, apex, QTypeSOA, "missing SOA record at the apex")
+	case len(soas) > 1:
+		report(LintError, apex, QTypeSOA, "%d SOA records at the apex", len(soas))
+	}
+
+	for name, records := range owners {
+		var (
+			hasCNAME bool
+			others   []string
+		)
+
+		for _, rr := range records {
+			switch rr.TYPE {
+			case QTypeCNAME:
+				hasCNAME = true
+			case QTypeSOA:
+				if name != apex {
+					report(LintError, name, QTypeSOA, "SOA record outside of the apex")
+				}
+			}
+		}
+
+		if !hasCNAME {
+			continue
+		}
+
+		for _, rr := range records {
+			if rr.TYPE != QTypeCNAME && !cnameCompanions[rr.TYPE] {
+				others = append(others, rr.TYPE.String())
+			}
+		}
+
+		if len(others) > 0 {
+			report(LintError, name, QTypeCNAME,
+				"CNAME and other data (%s)", strings.Join(uniqueSorted(others), ", "))
+		}
+	}
+
+	for key, records := range rrsets {
+		for _, rr := range records[1:] {
+			if rr.TTL != records[0].TTL {
+				report(LintWarning, key.name, key.qtype, "inconsistent TTLs in RRset")
+				break
+			}
+		}
+
+		if key.qtype == QTypeCNAME && len(records) > 1 {
+			report(LintError, key.name, key.qtype, "multiple CNAME records")
+		}
+
+		for _, rr := range records {
+			switch data := rr.Data.(type) {
+			case *NS:
+				host := canonicalName(data.Host)
+				if isSubdomain(host, apex) && !hasAddress(rrsets, host) {
+					report(LintError, key.name, key.qtype,
+						"in-zone name server %s has no address records (glue)",
+						fqdn(host))
+				}
+			case *MX:
+				lintTargetIsNotAlias(report, rrsets, key, data.Exchange)
+			case *SRV:
+				lintTargetIsNotAlias(report, rrsets, key, data.Target)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Name != problems[j].Name {
+			return problems[i].Name < problems[j].Name
+		}
+
+		if problems[i].Type != problems[j].Type {
+			return problems[i].Type < problems[j].Type
+		}
+
+		return problems[i].Message < problems[j].Message
+	})
+
+	return
+}
+
+// rrsetKey identifies an RRset: the records sharing owner, type and
+// class.
+type rrsetKey struct {
+	name  string
+	qtype QType
+	class QClass
+}
+
+func lintTargetIsNotAlias(
+	report func(LintSeverity, string, QType, string, ...interface{}),
+	rrsets map[rrsetKey][]*RR,
+	key rrsetKey,
+	target string,
+) {
+	target = canonicalName(target)
+
+	if len(rrsets[rrsetKey{target, QTypeCNAME, key.class}]) > 0 {
+		report(LintError, key.name, key.qtype,
+			"target %s is an alias (CNAME)", fqdn(target))
+	}
+}
+
+func hasAddress(rrsets map[rrsetKey][]*RR, name string) bool {
+	return len(rrsets[rrsetKey{name, QTypeA, QClassIN}]) > 0 ||
+		len(rrsets[rrsetKey{name, QTypeAAAA, QClassIN}]) > 0
+}
+
+// rdataNames returns the domain names carried in the RDATA.
+func rdataNames(data RData) (names []string) {
+	switch data := data.(type) {
+	case *NS:
+		names = []string{data.Host}
+	case *CNAME:
+		names = []string{data.Target}
+	case *PTR:
+		names = []string{data.Target}
+	case *SOA:
+		names = []string{data.MName, data.RName}
+	case *MX:
+		names = []string{data.Exchange}
+	case *SRV:
+		names = []string{data.Target}
+	case *MINFO:
+		names = []string{data.RMailbx, data.EMailbx}
+	case *RP:
+		names = []string{data.Mbox, data.Txt}
+	case *AFSDB:
+		names = []string{data.Hostname}
+	case *SVCB:
+		names = []string{data.Target}
+	}
+
+	return
+}
+
+func uniqueSorted(s []string) (res []string) {
+	sort.Strings(s)
+
+	for ndx, v := range s {
+		if ndx == 0 || v != s[ndx-1] {
+			res = append(res, v)
+		}
+	}
+
+	return
+}
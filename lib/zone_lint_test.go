@@ -0,0 +1,159 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintZone(t *testing.T) {
+	var testCases = []struct {
+		desc     string
+		zone     string
+		expected []string
+	}{
+		{
+			desc: "clean zone",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+	NS	ns1
+	NS	ns.example.net.
+	MX	10 mail
+ns1	A	192.0.2.1
+mail	AAAA	2001:db8::1
+www	CNAME	@
+sub	NS	ns.sub
+ns.sub	A	192.0.2.2
+`,
+		},
+		{
+			desc: "cname and other data",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+ns1	A	192.0.2.1
+www	CNAME	@
+www	TXT	"a"
+www	A	192.0.2.1
+`,
+			expected: []string{
+				"error: www.example.com. CNAME: CNAME and other data (A, TXT)",
+			},
+		},
+		{
+			desc: "cname with dnssec records",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+ns1	A	192.0.2.1
+www	CNAME	@
+www	TYPE46	\# 2 0005
+www	TYPE47	\# 2 0000
+`,
+		},
+		{
+			desc: "missing soa",
+			zone: `
+@	NS	ns.example.net.
+`,
+			expected: []string{
+				"error: example.com. SOA: missing SOA record at the apex",
+			},
+		},
+		{
+			desc: "multiple soa",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+@	SOA	ns1 hostmaster 2 7200 900 604800 300
+`,
+			expected: []string{
+				"error: example.com. SOA: 2 SOA records at the apex",
+			},
+		},
+		{
+			desc: "ns without glue",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+	NS	ns1
+sub	NS	ns.sub
+`,
+			expected: []string{
+				"error: example.com. NS: in-zone name server ns1.example.com. has no address records (glue)",
+				"error: sub.example.com. NS: in-zone name server ns.sub.example.com. has no address records (glue)",
+			},
+		},
+		{
+			desc: "out of zone data",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+www.example.net.	A	192.0.2.1
+`,
+			expected: []string{
+				"error: www.example.net. A: out of zone data (zone is example.com.)",
+			},
+		},
+		{
+			desc: "mx and srv targets pointing at aliases",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+	MX	10 MAIL
+_sip._tcp	SRV	0 0 5060 mail
+mail	CNAME	host
+`,
+			expected: []string{
+				"error: _sip._tcp.example.com. SRV: target mail.example.com. is an alias (CNAME)",
+				"error: example.com. MX: target mail.example.com. is an alias (CNAME)",
+			},
+		},
+		{
+			desc: "ttl inconsistencies",
+			zone: `
+@	SOA	ns1 hostmaster 1 7200 900 604800 300
+www	60	A	192.0.2.1
+www	120	A	192.0.2.2
+`,
+			expected: []string{
+				"warning: www.example.com. A: inconsistent TTLs in RRset",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			rrs, err := ParseZone(strings.NewReader("$TTL 300\n"+tc.zone), "example.com", "zone")
+			require.NoError(t, err)
+
+			var actual []string
+			for _, problem := range LintZone("example.com.", rrs) {
+				actual = append(actual, problem.String())
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestLintZone_class(t *testing.T) {
+	var rrs = []*RR{
+		{NAME: "example.com", TYPE: QTypeSOA, CLASS: QClassCH, Data: &SOA{MName: "ns.example.com", RName: "hostmaster.example.com"}},
+		{NAME: "www.example.com", TYPE: QTypeTXT, CLASS: QClassCH, Data: &TXT{Strings: []string{"a"}}},
+	}
+
+	assert.Empty(t, LintZone("example.com", rrs))
+}
+
+func TestLintZone_nameLimits(t *testing.T) {
+	var rrs = []*RR{
+		{NAME: "example.com", TYPE: QTypeSOA, CLASS: QClassIN, Data: &SOA{MName: "ns.example.com", RName: "hostmaster.example.com"}},
+		{NAME: strings.Repeat("a", 64) + ".example.com", TYPE: QTypeA, CLASS: QClassIN},
+		{NAME: strings.Repeat(strings.Repeat("a", 60)+".", 5) + "example.com", TYPE: QTypeA, CLASS: QClassIN},
+	}
+
+	problems := LintZone("example.com", rrs)
+	require.Len(t, problems, 2)
+
+	for _, problem := range problems {
+		assert.Equal(t, LintError, problem.Severity)
+		assert.Contains(t, problem.Message, "invalid name")
+	}
+}
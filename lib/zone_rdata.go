@@ -0,0 +1,895 @@
+package lib
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// rdataFields walks through the fields of the RDATA of a record in a
+// master file.
+type rdataFields struct {
+	tokens []zoneToken
+	origin string
+}
+
+// rdataParser parses the presentation format of the RDATA of a given
+// type.
+type rdataParser func(f *rdataFields) (data RData, err error)
+
+// rdataParsers holds the parsers of the RDATA of the types that the
+// zone parser knows how to read.
+var rdataParsers map[QType]rdataParser
+
+func init() {
+	rdataParsers = map[QType]rdataParser{
+		QTypeA:          parseARData,
+		QTypeNS:         parseNSRData,
+		QTypeMD:         parseNameRData,
+		QTypeMF:         parseNameRData,
+		QTypeCNAME:      parseCNAMERData,
+		QTypeSOA:        parseSOARData,
+		QTypeMB:         parseNameRData,
+		QTypeMG:         parseNameRData,
+		QTypeMR:         parseNameRData,
+		QTypePTR:        parsePTRRData,
+		QTypeHINFO:      parseHINFORData,
+		QTypeMINFO:      parseMINFORData,
+		QTypeMX:         parseMXRData,
+		QTypeTXT:        parseTXTRData,
+		QTypeRP:         parseRPRData,
+		QTypeAFSDB:      parseAFSDBRData,
+		QTypeAAAA:       parseAAAARData,
+		QTypeLOC:        parseLOCRData,
+		QTypeSRV:        parseSRVRData,
+		QTypeSSHFP:      parseSSHFPRData,
+		QTypeTLSA:       parseTLSARData,
+		QTypeOPENPGPKEY: parseOPENPGPKEYRData,
+		QTypeSVCB:       parseSVCBRData,
+		QTypeHTTPS:      parseSVCBRData,
+	}
+}
+
+// parseRDATA parses the RDATA of a record of type `t` given its fields
+// in presentation format. Any type can be given in the generic `\#`
+// format (RFC3597 section 5), which is decoded into the typed RDATA
+// when the type is known.
+func parseRDATA(t QType, tokens []zoneToken, origin string) (data RData, err error) {
+	var (
+		f      = &rdataFields{tokens: tokens, origin: origin}
+		parser rdataParser
+		ok     bool
+	)
+
+	if len(tokens) > 0 && tokens[0].text == `\#` && !tokens[0].quoted {
+		data, err = parseGenericRDATA(t, tokens)
+		return
+	}
+
+	parser, ok = rdataParsers[t]
+	if !ok {
+		err = errors.Errorf(
+			"type %s must use the generic \\# syntax",
+			t)
+		return
+	}
+
+	data, err = parser(f)
+	if err != nil {
+		return
+	}
+
+	err = f.end()
+	return
+}
+
+func parseGenericRDATA(t QType, tokens []zoneToken) (data RData, err error) {
+	var (
+		texts   = make([]string, len(tokens))
+		unknown *UnknownRDATA
+	)
+
+	for ndx, token := range tokens {
+		texts[ndx] = token.text
+	}
+
+	unknown, err = ParseUnknownRDATA(strings.Join(texts, " "))
+	if err != nil {
+		return
+	}
+
+	if _, ok := rdataUnmarshalers[t]; !ok {
+		data = unknown
+		return
+	}
+
+	data, err = unmarshalRDATA(t, unknown.Raw, 0, len(unknown.Raw))
+	return
+}
+
+// next returns the next field.
+func (f *rdataFields) next(what string) (s string, err error) {
+	var token zoneToken
+
+	token, err = f.nextToken(what)
+	if err != nil {
+		return
+	}
+
+	s = token.text
+	return
+}
+
+func (f *rdataFields) nextToken(what string) (token zoneToken, err error) {
+	if len(f.tokens) == 0 {
+		err = errors.Errorf("missing %s", what)
+		return
+	}
+
+	token = f.tokens[0]
+	f.tokens = f.tokens[1:]
+	return
+}
+
+// rest returns all of the remaining fields, which must not be empty.
+func (f *rdataFields) rest(what string) (s []string, err error) {
+	if len(f.tokens) == 0 {
+		err = errors.Errorf("missing %s", what)
+		return
+	}
+
+	for _, token := range f.tokens {
+		s = append(s, token.text)
+	}
+
+	f.tokens = nil
+	return
+}
+
+// end makes sure that there are no fields left.
+func (f *rdataFields) end() (err error) {
+	if len(f.tokens) > 0 {
+		err = errors.Errorf(
+			"unexpected field %s",
+			f.tokens[0].text)
+	}
+
+	return
+}
+
+func (f *rdataFields) name(what string) (name string, err error) {
+	s, err := f.next(what)
+	if err != nil {
+		return
+	}
+
+	name, err = parseZoneName(s, f.origin)
+	if err != nil {
+		err = errors.Wrapf(err, "malformed %s", what)
+	}
+
+	return
+}
+
+func (f *rdataFields) uint(what string, bitSize int) (value uint64, err error) {
+	s, err := f.next(what)
+	if err != nil {
+		return
+	}
+
+	value, err = strconv.ParseUint(s, 10, bitSize)
+	if err != nil {
+		err = errors.Errorf("malformed %s %s", what, s)
+	}
+
+	return
+}
+
+func (f *rdataFields) uint8(what string) (value uint8, err error) {
+	v, err := f.uint(what, 8)
+	value = uint8(v)
+	return
+}
+
+func (f *rdataFields) uint16(what string) (value uint16, err error) {
+	v, err := f.uint(what, 16)
+	value = uint16(v)
+	return
+}
+
+// ttl parses a 32 bit time value, which may use BIND's units (e.g.,
+// the SOA timers).
+func (f *rdataFields) ttl(what string) (value uint32, err error) {
+	s, err := f.next(what)
+	if err != nil {
+		return
+	}
+
+	if v, perr := strconv.ParseUint(s, 10, 32); perr == nil {
+		value = uint32(v)
+		return
+	}
+
+	value, err = parseTTL(s)
+	if err != nil {
+		err = errors.Wrapf(err, "malformed %s", what)
+	}
+
+	return
+}
+
+func (f *rdataFields) characterString(what string) (s []byte, err error) {
+	text, err := f.next(what)
+	if err != nil {
+		return
+	}
+
+	s, err = unescape(text)
+	if err != nil {
+		return
+	}
+
+	if len(s) > maxCharacterStringLength {
+		err = errors.Errorf(
+			"%s exceeds %d octets",
+			what, maxCharacterStringLength)
+	}
+
+	return
+}
+
+// hex decodes the remaining fields as a single hexadecimal string.
+func (f *rdataFields) hex(what string) (b []byte, err error) {
+	s, err := f.rest(what)
+	if err != nil {
+		return
+	}
+
+	b, err = hex.DecodeString(strings.Join(s, ""))
+	if err != nil {
+		err = errors.Wrapf(err, "malformed %s", what)
+	}
+
+	return
+}
+
+// base64 decodes the remaining fields as a single base64 string.
+func (f *rdataFields) base64(what string) (b []byte, err error) {
+	s, err := f.rest(what)
+	if err != nil {
+		return
+	}
+
+	b, err = base64.StdEncoding.DecodeString(strings.Join(s, ""))
+	if err != nil {
+		err = errors.Wrapf(err, "malformed %s", what)
+	}
+
+	return
+}
+
+// ip parses an address of the family indicated by `v6`.
+func (f *rdataFields) ip(what string, v6 bool) (ip net.IP, err error) {
+	s, err := f.next(what)
+	if err != nil {
+		return
+	}
+
+	ip = net.ParseIP(s)
+	if ip == nil || strings.Contains(s, ":") != v6 {
+		err = errors.Errorf("malformed %s %s", what, s)
+	}
+
+	return
+}
+
+func parseARData(f *rdataFields) (data RData, err error) {
+	ip, err := f.ip("ipv4 address", false)
+	if err != nil {
+		return
+	}
+
+	data = &A{Address: ip.To4()}
+	return
+}
+
+func parseAAAARData(f *rdataFields) (data RData, err error) {
+	ip, err := f.ip("ipv6 address", true)
+	if err != nil {
+		return
+	}
+
+	data = &AAAA{Address: ip.To16()}
+	return
+}
+
+func parseNSRData(f *rdataFields) (data RData, err error) {
+	host, err := f.name("host")
+	if err != nil {
+		return
+	}
+
+	data = &NS{Host: host}
+	return
+}
+
+func parseCNAMERData(f *rdataFields) (data RData, err error) {
+	target, err := f.name("target")
+	if err != nil {
+		return
+	}
+
+	data = &CNAME{Target: target}
+	return
+}
+
+func parsePTRRData(f *rdataFields) (data RData, err error) {
+	target, err := f.name("target")
+	if err != nil {
+		return
+	}
+
+	data = &PTR{Target: target}
+	return
+}
+
+func parseNameRData(f *rdataFields) (data RData, err error) {
+	name, err := f.name("name")
+	if err != nil {
+		return
+	}
+
+	data = &NameRDATA{Name: name}
+	return
+}
+
+func parseSOARData(f *rdataFields) (data RData, err error) {
+	var soa = new(SOA)
+
+	soa.MName, err = f.name("mname")
+	if err != nil {
+		return
+	}
+
+	soa.RName, err = f.name("rname")
+	if err != nil {
+		return
+	}
+
+	for _, field := range []struct {
+		name  string
+		value *uint32
+	}{
+		{"serial", &soa.Serial},
+		{"refresh", &soa.Refresh},
+		{"retry", &soa.Retry},
+		{"expire", &soa.Expire},
+		{"minimum", &soa.Minimum},
+	} {
+		*field.value, err = f.ttl(field.name)
+		if err != nil {
+			return
+		}
+	}
+
+	data = soa
+	return
+}
+
+func parseMXRData(f *rdataFields) (data RData, err error) {
+	var mx = new(MX)
+
+	mx.Preference, err = f.uint16("preference")
+	if err != nil {
+		return
+	}
+
+	mx.Exchange, err = f.name("exchange")
+	if err != nil {
+		return
+	}
+
+	data = mx
+	return
+}
+
+func parseTXTRData(f *rdataFields) (data RData, err error) {
+	var (
+		txt = new(TXT)
+		s   []byte
+	)
+
+	if len(f.tokens) == 0 {
+		err = errors.Errorf("missing strings")
+		return
+	}
+
+	for len(f.tokens) > 0 {
+		s, err = f.characterString("string")
+		if err != nil {
+			return
+		}
+
+		txt.Strings = append(txt.Strings, string(s))
+	}
+
+	data = txt
+	return
+}
+
+func parseHINFORData(f *rdataFields) (data RData, err error) {
+	cpu, err := f.characterString("cpu")
+	if err != nil {
+		return
+	}
+
+	os, err := f.characterString("os")
+	if err != nil {
+		return
+	}
+
+	data = &HINFO{CPU: string(cpu), OS: string(os)}
+	return
+}
+
+func parseMINFORData(f *rdataFields) (data RData, err error) {
+	var minfo = new(MINFO)
+
+	minfo.RMailbx, err = f.name("rmailbx")
+	if err != nil {
+		return
+	}
+
+	minfo.EMailbx, err = f.name("emailbx")
+	if err != nil {
+		return
+	}
+
+	data = minfo
+	return
+}
+
+func parseRPRData(f *rdataFields) (data RData, err error) {
+	var rp = new(RP)
+
+	rp.Mbox, err = f.name("mbox")
+	if err != nil {
+		return
+	}
+
+	rp.Txt, err = f.name("txt")
+	if err != nil {
+		return
+	}
+
+	data = rp
+	return
+}
+
+func parseAFSDBRData(f *rdataFields) (data RData, err error) {
+	var afsdb = new(AFSDB)
+
+	afsdb.Subtype, err = f.uint16("subtype")
+	if err != nil {
+		return
+	}
+
+	afsdb.Hostname, err = f.name("hostname")
+	if err != nil {
+		return
+	}
+
+	data = afsdb
+	return
+}
+
+func parseSRVRData(f *rdataFields) (data RData, err error) {
+	var srv = new(SRV)
+
+	srv.Priority, err = f.uint16("priority")
+	if err != nil {
+		return
+	}
+
+	srv.Weight, err = f.uint16("weight")
+	if err != nil {
+		return
+	}
+
+	srv.Port, err = f.uint16("port")
+	if err != nil {
+		return
+	}
+
+	srv.Target, err = f.name("target")
+	if err != nil {
+		return
+	}
+
+	data = srv
+	return
+}
+
+// parseLOCRData parses the presentation format of LOC records (RFC1876
+// section 3):
+//
+//	d1 [m1 [s1]] {"N"|"S"} d2 [m2 [s2]] {"E"|"W"} alt["m"]
+//	    [siz["m"] [hp["m"] [vp["m"]]]]
+func parseLOCRData(f *rdataFields) (data RData, err error) {
+	var (
+		latitude, longitude float64
+		meters              = []float64{0, 1, 10000, 10}
+	)
+
+	latitude, err = f.locCoordinate("latitude", "N", "S")
+	if err != nil {
+		return
+	}
+
+	longitude, err = f.locCoordinate("longitude", "E", "W")
+	if err != nil {
+		return
+	}
+
+	for ndx, what := range []string{"altitude", "size", "horizontal precision", "vertical precision"} {
+		if ndx > 0 && len(f.tokens) == 0 {
+			break
+		}
+
+		var s string
+		s, err = f.next(what)
+		if err != nil {
+			return
+		}
+
+		meters[ndx], err = strconv.ParseFloat(strings.TrimSuffix(s, "m"), 64)
+		if err != nil {
+			err = errors.Errorf("malformed %s %s", what, s)
+			return
+		}
+	}
+
+	data, err = NewLOC(latitude, longitude, meters[0], meters[1], meters[2], meters[3])
+	return
+}
+
+// locCoordinate parses `d [m [s]] {positive|negative}` into degrees.
+func (f *rdataFields) locCoordinate(what, positive, negative string) (degrees float64, err error) {
+	var (
+		s     string
+		value float64
+		scale = 1.0
+	)
+
+	for ndx := 0; ; ndx++ {
+		s, err = f.next(what)
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(s) {
+		case positive:
+			return
+		case negative:
+			degrees = -degrees
+			return
+		}
+
+		if ndx == 3 {
+			err = errors.Errorf("malformed %s - missing %s or %s", what, positive, negative)
+			return
+		}
+
+		value, err = strconv.ParseFloat(s, 64)
+		if err != nil || value < 0 {
+			err = errors.Errorf("malformed %s %s", what, s)
+			return
+		}
+
+		degrees += value / scale
+		scale *= 60
+	}
+}
+
+func parseSSHFPRData(f *rdataFields) (data RData, err error) {
+	var sshfp = new(SSHFP)
+
+	algorithm, err := f.uint8("algorithm")
+	if err != nil {
+		return
+	}
+
+	fpType, err := f.uint8("fingerprint type")
+	if err != nil {
+		return
+	}
+
+	sshfp.Algorithm, sshfp.Type = SSHFPAlgorithm(algorithm), SSHFPType(fpType)
+
+	sshfp.Fingerprint, err = f.hex("fingerprint")
+	if err != nil {
+		return
+	}
+
+	data = sshfp
+	return
+}
+
+func parseTLSARData(f *rdataFields) (data RData, err error) {
+	var tlsa = new(TLSA)
+
+	usage, err := f.uint8("usage")
+	if err != nil {
+		return
+	}
+
+	selector, err := f.uint8("selector")
+	if err != nil {
+		return
+	}
+
+	matchingType, err := f.uint8("matching type")
+	if err != nil {
+		return
+	}
+
+	tlsa.Usage = TLSAUsage(usage)
+	tlsa.Selector = TLSASelector(selector)
+	tlsa.MatchingType = TLSAMatchingType(matchingType)
+
+	tlsa.CertificateAssociation, err = f.hex("certificate association data")
+	if err != nil {
+		return
+	}
+
+	data = tlsa
+	return
+}
+
+func parseOPENPGPKEYRData(f *rdataFields) (data RData, err error) {
+	key, err := f.base64("public key")
+	if err != nil {
+		return
+	}
+
+	data = &OPENPGPKEY{PublicKey: key}
+	return
+}
+
+// parseSVCBRData parses the presentation format of SVCB and HTTPS
+// records (RFC9460 section 2.1):
+//
+//	SvcPriority TargetName [key[=value] ...]
+func parseSVCBRData(f *rdataFields) (data RData, err error) {
+	var (
+		svcb  = new(SVCB)
+		token zoneToken
+		param SVCParam
+	)
+
+	svcb.Priority, err = f.uint16("priority")
+	if err != nil {
+		return
+	}
+
+	svcb.Target, err = f.name("target")
+	if err != nil {
+		return
+	}
+
+	for len(f.tokens) > 0 {
+		token, err = f.nextToken("param")
+		if err != nil {
+			return
+		}
+
+		// `key="value"` is split by the lexer only when there's
+		// whitespace between the `=` and the quotes.
+		if strings.HasSuffix(token.text, "=") && len(f.tokens) > 0 && f.tokens[0].quoted {
+			token.text += f.tokens[0].text
+			f.tokens = f.tokens[1:]
+		}
+
+		param, err = parseSVCParam(token.text)
+		if err != nil {
+			return
+		}
+
+		svcb.Params = append(svcb.Params, param)
+	}
+
+	sort.SliceStable(svcb.Params, func(i, j int) bool {
+		return svcb.Params[i].Key < svcb.Params[j].Key
+	})
+
+	for ndx := 1; ndx < len(svcb.Params); ndx++ {
+		if svcb.Params[ndx].Key == svcb.Params[ndx-1].Key {
+			err = errors.Errorf(
+				"duplicate param %s",
+				svcb.Params[ndx].Key)
+			return
+		}
+	}
+
+	err = svcb.Validate()
+	if err != nil {
+		return
+	}
+
+	data = svcb
+	return
+}
+
+// parseSVCParamKey parses the presentation name of a key, either the
+// registered one or the generic `keyNNNNN`.
+func parseSVCParamKey(s string) (key SVCParamKey, err error) {
+	for k, name := range svcParamKeyNames {
+		if name == s {
+			key = k
+			return
+		}
+	}
+
+	if !strings.HasPrefix(s, "key") {
+		err = errors.Errorf("unknown param key %s", s)
+		return
+	}
+
+	value, err := strconv.ParseUint(strings.TrimPrefix(s, "key"), 10, 16)
+	if err != nil || value == uint64(SVCParamInvalid) {
+		err = errors.Errorf("malformed param key %s", s)
+		return
+	}
+
+	key = SVCParamKey(value)
+	return
+}
+
+// parseSVCParam parses a single `key[=value]` SvcParam.
+func parseSVCParam(s string) (param SVCParam, err error) {
+	var (
+		parts    = strings.SplitN(s, "=", 2)
+		value    string
+		hasValue = len(parts) == 2
+		raw      []byte
+	)
+
+	param.Key, err = parseSVCParamKey(parts[0])
+	if err != nil {
+		return
+	}
+
+	if hasValue {
+		value = strings.TrimSuffix(strings.TrimPrefix(parts[1], `"`), `"`)
+	}
+
+	if !hasValue || value == "" {
+		switch param.Key {
+		case SVCParamNoDefaultALPN:
+			return
+		case SVCParamMandatory, SVCParamALPN, SVCParamPort,
+			SVCParamIPv4Hint, SVCParamIPv6Hint, SVCParamECH:
+			err = errors.Errorf("param %s requires a value", param.Key)
+			return
+		}
+
+		if !hasValue {
+			return
+		}
+	}
+
+	switch param.Key {
+	case SVCParamMandatory:
+		var keys []SVCParamKey
+
+		for _, name := range strings.Split(value, ",") {
+			var key SVCParamKey
+
+			key, err = parseSVCParamKey(name)
+			if err != nil {
+				return
+			}
+
+			keys = append(keys, key)
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		param = NewSVCParamMandatory(keys...)
+	case SVCParamALPN:
+		var ids []string
+
+		ids, err = splitALPN(value)
+		if err != nil {
+			return
+		}
+
+		param = NewSVCParamALPN(ids...)
+	case SVCParamNoDefaultALPN:
+		err = errors.Errorf("param %s takes no value", param.Key)
+	case SVCParamPort:
+		var port uint64
+
+		port, err = strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			err = errors.Errorf("malformed port %s", value)
+			return
+		}
+
+		param = NewSVCParamPort(uint16(port))
+	case SVCParamIPv4Hint, SVCParamIPv6Hint:
+		var ips []net.IP
+
+		for _, addr := range strings.Split(value, ",") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				err = errors.Errorf("malformed address %s", addr)
+				return
+			}
+
+			ips = append(ips, ip)
+		}
+
+		if param.Key == SVCParamIPv4Hint {
+			param, err = NewSVCParamIPv4Hint(ips...)
+		} else {
+			param, err = NewSVCParamIPv6Hint(ips...)
+		}
+	case SVCParamECH:
+		raw, err = base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			err = errors.Wrapf(err, "malformed ech config")
+			return
+		}
+
+		param = NewSVCParamECH(raw)
+	default:
+		param.Value, err = unescape(value)
+	}
+
+	return
+}
+
+// splitALPN splits the value of an `alpn` param into the protocol
+// ids. The value is a comma-separated list in which commas and
+// backslashes that are part of an id are escaped with a backslash -
+// on top of the escaping of the character-string itself.
+func splitALPN(value string) (ids []string, err error) {
+	var (
+		unescaped []byte
+		id        []byte
+	)
+
+	unescaped, err = unescape(value)
+	if err != nil {
+		return
+	}
+
+	for ndx := 0; ndx < len(unescaped); ndx++ {
+		switch c := unescaped[ndx]; {
+		case c == '\\' && ndx+1 < len(unescaped):
+			id = append(id, unescaped[ndx+1])
+			ndx++
+		case c == ',':
+			ids = append(ids, string(id))
+			id = nil
+		default:
+			id = append(id, c)
+		}
+	}
+
+	ids = append(ids, string(id))
+
+	for _, id := range ids {
+		if id == "" || len(id) > 255 {
+			err = errors.Errorf("malformed alpn value %s", value)
+			return
+		}
+	}
+
+	return
+}
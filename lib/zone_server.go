@@ -0,0 +1,383 @@
+package lib
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxCNAMEChain is the maximum number of aliases followed when
+	// answering a query, protecting against loops.
+	maxCNAMEChain = 8
+
+	// qtypeDS is the type of delegation signer records (RFC4034),
+	// which live at the parent side of delegations.
+	qtypeDS QType = 43
+)
+
+// Zone answers queries authoritatively with the records of a zone
+// held in memory (RFC1034 section 4.3.2): it follows aliases within
+// the zone, expands wildcards (RFC4592), refers queries for names
+// below delegations to their name servers and adds the addresses of
+// the names that answers point to as additional records.
+//
+// Zone is a Handler that answers REFUSED to queries for names outside
+// of it. It's safe for concurrent use.
+type Zone struct {
+	origin Name
+	class  QClass
+	soa    *RR
+
+	// nodes holds the records of each name of the zone, indexed by
+	// its canonical form. Empty non-terminals (names that only
+	// exist as ancestors of others) have nodes without records.
+	nodes map[string]*zoneNode
+}
+
+// zoneNode holds the records of a name of a zone.
+type zoneNode struct {
+	rrsets map[QType][]*RR
+	types  []QType
+}
+
+// NewZone creates a zone whose apex is `origin` from the records `rrs`
+// (e.g., read with `ParseZoneFile`). The zone must have a single SOA
+// at the apex and no records outside of it.
+func NewZone(origin string, rrs []*RR) (z *Zone, err error) {
+	var apex Name
+
+	apex, err = ParseName(origin)
+	if err != nil {
+		err = errors.Wrapf(err,
+			"invalid origin %s",
+			origin)
+		return
+	}
+
+	z = &Zone{
+		origin: apex.Canonical(),
+		nodes:  make(map[string]*zoneNode),
+	}
+
+	for _, rr := range rrs {
+		var name Name
+
+		name, err = ParseName(rr.NAME)
+		if err != nil {
+			err = errors.Wrapf(err,
+				"invalid owner of record %s",
+				rr)
+			return
+		}
+
+		if !name.IsSubdomainOf(z.origin) {
+			err = errors.Errorf(
+				"record %s is outside of the zone %s",
+				rr, z.origin)
+			return
+		}
+
+		if rr.TYPE == QTypeSOA {
+			if !name.Equal(z.origin) {
+				err = errors.Errorf(
+					"SOA record %s is not at the apex",
+					rr)
+				return
+			}
+
+			if z.soa != nil {
+				err = errors.Errorf(
+					"more than one SOA record at the apex of %s",
+					z.origin)
+				return
+			}
+
+			z.soa = rr
+			z.class = rr.CLASS
+		}
+
+		z.add(name.Canonical(), rr)
+	}
+
+	if z.soa == nil {
+		err = errors.Errorf(
+			"missing SOA record at the apex of %s",
+			z.origin)
+		return
+	}
+
+	return
+}
+
+// add adds the record `rr` to the node of `name`, creating the nodes
+// of its ancestors up to the apex as needed.
+func (z *Zone) add(name Name, rr *RR) {
+	var key = name.text()
+
+	node, ok := z.nodes[key]
+	if !ok {
+		node = &zoneNode{rrsets: make(map[QType][]*RR)}
+		z.nodes[key] = node
+
+		if !name.Equal(z.origin) {
+			z.add(name.Parent(), nil)
+		}
+	}
+
+	if rr == nil {
+		return
+	}
+
+	if _, ok := node.rrsets[rr.TYPE]; !ok {
+		node.types = append(node.types, rr.TYPE)
+	}
+
+	node.rrsets[rr.TYPE] = append(node.rrsets[rr.TYPE], rr)
+}
+
+// Origin returns the apex of the zone.
+func (z *Zone) Origin() string {
+	return z.origin.text()
+}
+
+// ServeDNS answers the request `r` (see `Answer`).
+func (z *Zone) ServeDNS(w ResponseWriter, r *Message) {
+	w.WriteMsg(z.Answer(r))
+}
+
+// Answer creates the response to the request `req`.
+func (z *Zone) Answer(req *Message) (res *Message) {
+	var (
+		name     Name
+		question *Question
+		err      error
+	)
+
+	res = NewReply(req)
+
+	if req.Opcode != OpcodeQuery {
+		res.RCODE = byte(RCODENotImplemented)
+		return
+	}
+
+	if len(req.Questions) != 1 {
+		res.RCODE = byte(RCODEFormatError)
+		return
+	}
+
+	question = req.Questions[0]
+
+	name, err = ParseName(question.QNAME)
+	if err != nil {
+		res.RCODE = byte(RCODEFormatError)
+		return
+	}
+
+	if !name.IsSubdomainOf(z.origin) ||
+		(question.QCLASS != z.class && question.QCLASS != QClassWildcard) {
+		res.RCODE = byte(RCODERefused)
+		return
+	}
+
+	res.AA = 1
+	z.answer(res, name, question.QTYPE)
+	z.addAdditionals(res)
+
+	return
+}
+
+// answer fills `res` with the records of type `qtype` of `name`,
+// following aliases within the zone.
+func (z *Zone) answer(res *Message, name Name, qtype QType) {
+	var visited = make(map[string]bool)
+
+	for chain := 0; chain <= maxCNAMEChain; chain++ {
+		var key = name.Canonical().text()
+
+		if visited[key] || !name.IsSubdomainOf(z.origin) {
+			return
+		}
+		visited[key] = true
+
+		if cut := z.findCut(name, qtype); cut != nil {
+			// referrals aren't authoritative, but any alias
+			// that led to them is.
+			if len(res.Answers) == 0 {
+				res.AA = 0
+			}
+
+			res.Authorities = append(res.Authorities, cut.rrsets[QTypeNS]...)
+			return
+		}
+
+		node, owner := z.findNode(name)
+		if node == nil {
+			res.RCODE = byte(RCODENameError)
+			res.Authorities = append(res.Authorities, z.negativeSOA())
+			return
+		}
+
+		var answers = len(res.Answers)
+
+		switch {
+		case qtype == QTypeWildcard:
+			for _, t := range node.types {
+				res.Answers = append(res.Answers, synthesize(node.rrsets[t], owner, name)...)
+			}
+		case len(node.rrsets[qtype]) > 0:
+			res.Answers = append(res.Answers, synthesize(node.rrsets[qtype], owner, name)...)
+		case len(node.rrsets[QTypeCNAME]) > 0:
+			cname := synthesize(node.rrsets[QTypeCNAME], owner, name)
+			res.Answers = append(res.Answers, cname...)
+
+			data, ok := cname[0].Data.(*CNAME)
+			if !ok {
+				return
+			}
+
+			target, err := ParseName(data.Target)
+			if err != nil {
+				return
+			}
+
+			name = target
+			continue
+		}
+
+		// the name exists, but not with records of the type asked
+		// (NODATA).
+		if len(res.Answers) == answers {
+			res.Authorities = append(res.Authorities, z.negativeSOA())
+		}
+
+		return
+	}
+}
+
+// findCut returns the node of the delegation (a name below the apex
+// with NS records) that `name` is at or below, if any. DS records are
+// answered by the parent side of a delegation (RFC4035 section 3.1.4).
+func (z *Zone) findCut(name Name, qtype QType) *zoneNode {
+	var labels = len(name.labels) - len(z.origin.labels)
+
+	for ndx := labels - 1; ndx >= 0; ndx-- {
+		if ndx == 0 && qtype == qtypeDS {
+			break
+		}
+
+		node, ok := z.nodes[Name{labels: name.labels[ndx:]}.Canonical().text()]
+		if !ok {
+			break
+		}
+
+		if len(node.rrsets[QTypeNS]) > 0 {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// findNode returns the node that holds the records of `name`: either
+// its own or, if it doesn't exist, the wildcard of its closest
+// encloser (RFC4592 section 3.3.1), along with the name of the node.
+func (z *Zone) findNode(name Name) (node *zoneNode, owner Name) {
+	var ok bool
+
+	node, ok = z.nodes[name.Canonical().text()]
+	if ok {
+		return node, name
+	}
+
+	for encloser := name.Parent(); encloser.IsSubdomainOf(z.origin); encloser = encloser.Parent() {
+		if _, ok = z.nodes[encloser.Canonical().text()]; !ok {
+			continue
+		}
+
+		wildcard, err := encloser.Child("*")
+		if err != nil {
+			return nil, name
+		}
+
+		node, ok = z.nodes[wildcard.Canonical().text()]
+		if !ok {
+			return nil, name
+		}
+
+		return node, wildcard
+	}
+
+	return nil, name
+}
+
+// synthesize returns the records `rrs` owned by `owner` as owned by
+// `name` instead, which differ when they come from a wildcard.
+func synthesize(rrs []*RR, owner, name Name) (res []*RR) {
+	if owner.Equal(name) {
+		return rrs
+	}
+
+	res = make([]*RR, len(rrs))
+	for ndx, rr := range rrs {
+		copied := *rr
+		copied.NAME = name.text()
+		res[ndx] = &copied
+	}
+
+	return
+}
+
+// negativeSOA returns the SOA record that goes in the authority
+// section of negative answers, with the TTL of the negative answer
+// (RFC2308 section 3).
+func (z *Zone) negativeSOA() *RR {
+	var soa = *z.soa
+
+	if data, ok := soa.Data.(*SOA); ok && data.Minimum < soa.TTL {
+		soa.TTL = data.Minimum
+	}
+
+	return &soa
+}
+
+// addAdditionals adds the addresses of the names that the NS, MX and
+// SRV records of the answer and authority sections point to, as long
+// as they're in the zone (glue included).
+func (z *Zone) addAdditionals(res *Message) {
+	var seen = make(map[string]bool)
+
+	for _, section := range [][]*RR{res.Answers, res.Authorities} {
+		for _, rr := range section {
+			var target string
+
+			switch data := rr.Data.(type) {
+			case *NS:
+				target = data.Host
+			case *MX:
+				target = data.Exchange
+			case *SRV:
+				target = data.Target
+			default:
+				continue
+			}
+
+			name, err := ParseName(target)
+			if err != nil || !name.IsSubdomainOf(z.origin) {
+				continue
+			}
+
+			key := name.Canonical().text()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			node, ok := z.nodes[key]
+			if !ok {
+				continue
+			}
+
+			res.Additionals = append(res.Additionals, node.rrsets[QTypeA]...)
+			res.Additionals = append(res.Additionals, node.rrsets[QTypeAAAA]...)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testZone = `
+$TTL 3600
+@		SOA	ns1 hostmaster 1 7200 900 604800 300
+		NS	ns1
+		NS	ns.example.net.
+		MX	10 mail
+		MX	20 mail.example.net.
+ns1		A	192.0.2.1
+mail		A	192.0.2.2
+mail		AAAA	2001:db8::2
+www		CNAME	web
+web		A	192.0.2.3
+alias		CNAME	www
+outside		CNAME	www.example.net.
+loop		CNAME	loop
+_sip._udp	SRV	10 60 5060 sip
+sip		A	192.0.2.4
+*.wild		TXT	"wildcard"
+*.wild		MX	10 mail
+exists.wild	A	192.0.2.5
+*.cname		CNAME	web
+a.b.c		A	192.0.2.6
+sub		NS	ns.sub
+sub		NS	ns.example.net.
+sub		TYPE43	\# 24 3039080249fd46e6c4b45c55d4ac69cbd3cd34ac1afe51de
+ns.sub		A	192.0.2.7
+`
+
+func testZoneAnswer(t *testing.T, qname string, qtype QType) *Message {
+	rrs, err := ParseZone(strings.NewReader(testZone), "example.com", "test")
+	require.NoError(t, err)
+
+	zone, err := NewZone("example.com", rrs)
+	require.NoError(t, err)
+
+	req := testQuery(1, qname)
+	req.Questions[0].QTYPE = qtype
+
+	res := HandleMessage(context.Background(), zone, req)
+	require.NotNil(t, res)
+
+	return res
+}
+
+func recordLines(rrs []*RR) (lines []string) {
+	for _, rr := range rrs {
+		lines = append(lines, strings.Replace(rr.String(), "\t", " ", -1))
+	}
+
+	return
+}
+
+func TestZone(t *testing.T) {
+	const soa = "example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 604800 300"
+
+	var testCases = []struct {
+		desc        string
+		qname       string
+		qtype       QType
+		rcode       RCODE
+		aa          byte
+		answers     []string
+		authorities []string
+		additionals []string
+	}{
+		{
+			desc:    "exact match",
+			qname:   "web.example.com",
+			qtype:   QTypeA,
+			aa:      1,
+			answers: []string{"web.example.com. 3600 IN A 192.0.2.3"},
+		},
+		{
+			desc:    "different case",
+			qname:   "WEB.Example.COM",
+			qtype:   QTypeA,
+			aa:      1,
+			answers: []string{"web.example.com. 3600 IN A 192.0.2.3"},
+		},
+		{
+			desc:  "mx with additional addresses",
+			qname: "example.com",
+			qtype: QTypeMX,
+			aa:    1,
+			answers: []string{
+				"example.com. 3600 IN MX 10 mail.example.com.",
+				"example.com. 3600 IN MX 20 mail.example.net.",
+			},
+			additionals: []string{
+				"mail.example.com. 3600 IN A 192.0.2.2",
+				"mail.example.com. 3600 IN AAAA 2001:db8::2",
+			},
+		},
+		{
+			desc:    "srv with additional addresses",
+			qname:   "_sip._udp.example.com",
+			qtype:   QTypeSRV,
+			aa:      1,
+			answers: []string{"_sip._udp.example.com. 3600 IN SRV 10 60 5060 sip.example.com."},
+			additionals: []string{
+				"sip.example.com. 3600 IN A 192.0.2.4",
+			},
+		},
+		{
+			desc:  "ns of the apex",
+			qname: "example.com",
+			qtype: QTypeNS,
+			aa:    1,
+			answers: []string{
+				"example.com. 3600 IN NS ns1.example.com.",
+				"example.com. 3600 IN NS ns.example.net.",
+			},
+			additionals: []string{
+				"ns1.example.com. 3600 IN A 192.0.2.1",
+			},
+		},
+		{
+			desc:  "cname chain",
+			qname: "alias.example.com",
+			qtype: QTypeA,
+			aa:    1,
+			answers: []string{
+				"alias.example.com. 3600 IN CNAME www.example.com.",
+				"www.example.com. 3600 IN CNAME web.example.com.",
+				"web.example.com. 3600 IN A 192.0.2.3",
+			},
+		},
+		{
+			desc:    "cname asked",
+			qname:   "www.example.com",
+			qtype:   QTypeCNAME,
+			aa:      1,
+			answers: []string{"www.example.com. 3600 IN CNAME web.example.com."},
+		},
+		{
+			desc:        "cname to a type the target lacks",
+			qname:       "www.example.com",
+			qtype:       QTypeMX,
+			aa:          1,
+			answers:     []string{"www.example.com. 3600 IN CNAME web.example.com."},
+			authorities: []string{soa},
+		},
+		{
+			desc:    "cname out of the zone",
+			qname:   "outside.example.com",
+			qtype:   QTypeA,
+			aa:      1,
+			answers: []string{"outside.example.com. 3600 IN CNAME www.example.net."},
+		},
+		{
+			desc:    "cname loop",
+			qname:   "loop.example.com",
+			qtype:   QTypeA,
+			aa:      1,
+			answers: []string{"loop.example.com. 3600 IN CNAME loop.example.com."},
+		},
+		{
+			desc:        "nxdomain",
+			qname:       "nope.example.com",
+			qtype:       QTypeA,
+			rcode:       RCODENameError,
+			aa:          1,
+			authorities: []string{soa},
+		},
+		{
+			desc:        "nodata",
+			qname:       "web.example.com",
+			qtype:       QTypeAAAA,
+			aa:          1,
+			authorities: []string{soa},
+		},
+		{
+			desc:        "empty non-terminal",
+			qname:       "b.c.example.com",
+			qtype:       QTypeA,
+			aa:          1,
+			authorities: []string{soa},
+		},
+		{
+			desc:    "wildcard",
+			qname:   "anything.wild.example.com",
+			qtype:   QTypeTXT,
+			aa:      1,
+			answers: []string{`anything.wild.example.com. 3600 IN TXT "wildcard"`},
+		},
+		{
+			desc:    "wildcard with many labels",
+			qname:   "a.b.wild.example.com",
+			qtype:   QTypeMX,
+			aa:      1,
+			answers: []string{"a.b.wild.example.com. 3600 IN MX 10 mail.example.com."},
+			additionals: []string{
+				"mail.example.com. 3600 IN A 192.0.2.2",
+				"mail.example.com. 3600 IN AAAA 2001:db8::2",
+			},
+		},
+		{
+			desc:        "wildcard without the type",
+			qname:       "anything.wild.example.com",
+			qtype:       QTypeA,
+			aa:          1,
+			authorities: []string{soa},
+		},
+		{
+			desc:        "name that exists blocks the wildcard",
+			qname:       "exists.wild.example.com",
+			qtype:       QTypeTXT,
+			aa:          1,
+			authorities: []string{soa},
+		},
+		{
+			desc:        "name below one that exists",
+			qname:       "below.exists.wild.example.com",
+			qtype:       QTypeTXT,
+			rcode:       RCODENameError,
+			aa:          1,
+			authorities: []string{soa},
+		},
+		{
+			desc:  "wildcard cname",
+			qname: "x.cname.example.com",
+			qtype: QTypeA,
+			aa:    1,
+			answers: []string{
+				"x.cname.example.com. 3600 IN CNAME web.example.com.",
+				"web.example.com. 3600 IN A 192.0.2.3",
+			},
+		},
+		{
+			desc:  "referral",
+			qname: "www.sub.example.com",
+			qtype: QTypeA,
+			authorities: []string{
+				"sub.example.com. 3600 IN NS ns.sub.example.com.",
+				"sub.example.com. 3600 IN NS ns.example.net.",
+			},
+			additionals: []string{
+				"ns.sub.example.com. 3600 IN A 192.0.2.7",
+			},
+		},
+		{
+			desc:  "referral at the cut",
+			qname: "sub.example.com",
+			qtype: QTypeNS,
+			authorities: []string{
+				"sub.example.com. 3600 IN NS ns.sub.example.com.",
+				"sub.example.com. 3600 IN NS ns.example.net.",
+			},
+			additionals: []string{
+				"ns.sub.example.com. 3600 IN A 192.0.2.7",
+			},
+		},
+		{
+			desc:    "ds at the cut",
+			qname:   "sub.example.com",
+			qtype:   qtypeDS,
+			aa:      1,
+			answers: []string{"sub.example.com. 3600 IN TYPE43 \\# 24 3039080249FD46E6C4B45C55D4AC69CBD3CD34AC1AFE51DE"},
+		},
+		{
+			desc:  "out of the zone",
+			qname: "example.net",
+			qtype: QTypeA,
+			rcode: RCODERefused,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			res := testZoneAnswer(t, tc.qname, tc.qtype)
+
+			assert.Equal(t, byte(tc.rcode), res.RCODE)
+			assert.Equal(t, tc.aa, res.AA)
+			assert.Equal(t, tc.answers, recordLines(res.Answers))
+			assert.Equal(t, tc.authorities, recordLines(res.Authorities))
+			assert.Equal(t, tc.additionals, recordLines(res.Additionals))
+			assert.Equal(t, uint16(len(res.Answers)), res.ANCOUNT)
+		})
+	}
+}
+
+func TestZone_any(t *testing.T) {
+	res := testZoneAnswer(t, "mail.example.com", QTypeWildcard)
+
+	assert.Equal(t, []string{
+		"mail.example.com. 3600 IN A 192.0.2.2",
+		"mail.example.com. 3600 IN AAAA 2001:db8::2",
+	}, recordLines(res.Answers))
+}
+
+func TestNewZone_invalid(t *testing.T) {
+	var testCases = []struct {
+		desc string
+		zone string
+	}{
+		{
+			desc: "missing soa",
+			zone: "@ NS ns.example.net.",
+		},
+		{
+			desc: "two soa",
+			zone: "@ SOA ns1 hostmaster 1 7200 900 604800 300\n@ SOA ns1 hostmaster 2 7200 900 604800 300",
+		},
+		{
+			desc: "soa below the apex",
+			zone: "@ SOA ns1 hostmaster 1 7200 900 604800 300\nsub SOA ns1 hostmaster 1 7200 900 604800 300",
+		},
+		{
+			desc: "record outside of the zone",
+			zone: "@ SOA ns1 hostmaster 1 7200 900 604800 300\nexample.net. A 192.0.2.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			rrs, err := ParseZone(strings.NewReader("$TTL 300\n"+tc.zone), "example.com", "test")
+			require.NoError(t, err)
+
+			_, err = NewZone("example.com", rrs)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZone(t *testing.T) {
+	var testCases = []struct {
+		desc       string
+		zone       string
+		expected   []string
+		shouldFail bool
+	}{
+		{
+			desc: "records with directives and defaults",
+			zone: `
+$ORIGIN example.com.
+$TTL 1h
+@	IN	SOA	ns1 hostmaster (
+			2024010101 ; serial
+			2h         ; refresh
+			15m        ; retry
+			1w         ; expire
+			300 )      ; minimum
+	IN	NS	ns1
+	IN	MX	10 mail.example.net.
+ns1	300	A	192.0.2.1
+	AAAA	2001:db8::1
+www	IN 60	CNAME	@
+`,
+			expected: []string{
+				"example.com.\t3600\tIN\tSOA\tns1.example.com. hostmaster.example.com. 2024010101 7200 900 604800 300",
+				"example.com.\t3600\tIN\tNS\tns1.example.com.",
+				"example.com.\t3600\tIN\tMX\t10 mail.example.net.",
+				"ns1.example.com.\t300\tIN\tA\t192.0.2.1",
+				"ns1.example.com.\t3600\tIN\tAAAA\t2001:db8::1",
+				"www.example.com.\t60\tIN\tCNAME\texample.com.",
+			},
+		},
+		{
+			desc: "last ttl is used without $TTL",
+			zone: `
+a.example.com. 120 IN A 192.0.2.1
+b.example.com. IN A 192.0.2.2
+`,
+			expected: []string{
+				"a.example.com.\t120\tIN\tA\t192.0.2.1",
+				"b.example.com.\t120\tIN\tA\t192.0.2.2",
+			},
+		},
+		{
+			desc: "relative $ORIGIN",
+			zone: `
+$TTL 60
+$ORIGIN com.
+$ORIGIN example
+www A 192.0.2.1
+`,
+			expected: []string{
+				"www.example.com.\t60\tIN\tA\t192.0.2.1",
+			},
+		},
+		{
+			desc: "root origin",
+			zone: `
+$TTL 60
+$ORIGIN .
+@ NS a.root-servers.net.
+a.root-servers.net. A 198.41.0.4
+`,
+			expected: []string{
+				".\t60\tIN\tNS\ta.root-servers.net.",
+				"a.root-servers.net.\t60\tIN\tA\t198.41.0.4",
+			},
+		},
+		{
+			desc: "quotes, escapes and comments",
+			zone: `
+$TTL 60
+txt TXT "a; not a comment" b\;c "with \"quotes\"" ; comment
+a\032b TXT "\065"
+`,
+			expected: []string{
+				"txt.example.org.\t60\tIN\tTXT\t\"a; not a comment\" \"b;c\" \"with \\\"quotes\\\"\"",
+				"a\\032b.example.org.\t60\tIN\tTXT\t\"A\"",
+			},
+		},
+		{
+			desc: "generic syntax",
+			zone: `
+$TTL 60
+a CLASS1 TYPE1 \# 4 C0000201
+b IN TYPE731 \# 6 abcd (
+	ef012345 )
+c IN TYPE62347 \# 0
+`,
+			expected: []string{
+				"a.example.org.\t60\tIN\tA\t192.0.2.1",
+				"b.example.org.\t60\tIN\tTYPE731\t\\# 6 ABCDEF012345",
+				"c.example.org.\t60\tIN\tTYPE62347\t\\# 0",
+			},
+		},
+		{
+			desc: "other record types",
+			zone: `
+$TTL 60
+_sip._tcp SRV 10 20 5060 sip
+_443._tcp TLSA 3 1 1 ( 0123456789abcdef
+	0123456789ABCDEF )
+svc HTTPS 1 . alpn="h2,h3" port=443 ipv4hint=192.0.2.1
+loc LOC 52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m
+mbx MB mail
+`,
+			expected: []string{
+				"_sip._tcp.example.org.\t60\tIN\tSRV\t10 20 5060 sip.example.org.",
+				"_443._tcp.example.org.\t60\tIN\tTLSA\t3 1 1 0123456789ABCDEF0123456789ABCDEF",
+				"svc.example.org.\t60\tIN\tHTTPS\t1 . alpn=\"h2,h3\" port=443 ipv4hint=192.0.2.1",
+				"loc.example.org.\t60\tIN\tLOC\t52 22 23.000 N 4 53 32.000 E -2.00m 0m 10000m 10m",
+				"mbx.example.org.\t60\tIN\tMB\tmail.example.org.",
+			},
+		},
+		{
+			desc: "$GENERATE",
+			zone: `
+$TTL 60
+$GENERATE 1-3 host-$ A 192.0.2.$
+$GENERATE 0-4/2 ${10,3,x} PTR h\$.${0,2,d}
+`,
+			expected: []string{
+				"host-1.example.org.\t60\tIN\tA\t192.0.2.1",
+				"host-2.example.org.\t60\tIN\tA\t192.0.2.2",
+				"host-3.example.org.\t60\tIN\tA\t192.0.2.3",
+				"00a.example.org.\t60\tIN\tPTR\th\\$.00.example.org.",
+				"00c.example.org.\t60\tIN\tPTR\th\\$.02.example.org.",
+				"00e.example.org.\t60\tIN\tPTR\th\\$.04.example.org.",
+			},
+		},
+		{
+			desc:       "missing ttl",
+			zone:       "a A 192.0.2.1\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "missing owner",
+			zone:       "$TTL 60\n\tA 192.0.2.1\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "unbalanced parentheses",
+			zone:       "$TTL 60\na TXT ( \"a\"\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "unterminated quote",
+			zone:       "$TTL 60\na TXT \"a\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "wrong address family",
+			zone:       "$TTL 60\na A 2001:db8::1\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "trailing fields",
+			zone:       "$TTL 60\na CNAME b c\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "label too long",
+			zone:       "$TTL 60\n" + strings.Repeat("a", 64) + " A 192.0.2.1\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "generic length mismatch",
+			zone:       "$TTL 60\na TYPE731 \\# 2 abcdef\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "unknown type without generic syntax",
+			zone:       "$TTL 60\na TYPE731 abcdef\n",
+			shouldFail: true,
+		},
+		{
+			desc:       "ttl out of range",
+			zone:       "$TTL 2147483648\n",
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			rrs, err := ParseZone(strings.NewReader(tc.zone), "example.org", "zone")
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var actual []string
+			for _, rr := range rrs {
+				assert.Equal(t, int(rr.RDLENGTH), len(rr.RDATA))
+				actual = append(actual, rr.String())
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParseZone_errorsCarryTheLine(t *testing.T) {
+	_, err := ParseZone(strings.NewReader(`
+$TTL 60
+a A 192.0.2.1
+b TXT (
+	"ok"
+)
+c MX mail
+`), "example.org", "db.example")
+	require.Error(t, err)
+
+	parseErr, ok := err.(*ZoneParseError)
+	require.True(t, ok)
+	assert.Equal(t, "db.example", parseErr.File)
+	assert.Equal(t, 7, parseErr.Line)
+	assert.True(t, strings.HasPrefix(err.Error(), "db.example:7: "))
+}
+
+func TestParseZone_include(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rawdns-zone")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hosts"), []byte(`
+www A 192.0.2.1
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "loop"), []byte(`
+$INCLUDE loop
+`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db"), []byte(`
+$TTL 60
+$INCLUDE hosts sub
+mail A 192.0.2.2
+`), 0644))
+
+	rrs, err := ParseZoneFile(filepath.Join(dir, "db"), "example.com.")
+	require.NoError(t, err)
+	require.Len(t, rrs, 2)
+	assert.Equal(t, "www.sub.example.com.\t60\tIN\tA\t192.0.2.1", rrs[0].String())
+	assert.Equal(t, "mail.example.com.\t60\tIN\tA\t192.0.2.2", rrs[1].String())
+
+	_, err = ParseZoneFile(filepath.Join(dir, "loop"), "example.com.")
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -9,14 +10,22 @@ import (
 )
 
 type cliConfig struct {
-	Hostname string `arg:"positional,required,help:name to resolve"`
-	Address  string `arg:-a,help:DNS server to query against`
+	Hostname      string `arg:"positional,required,help:name to resolve"`
+	Address       string `arg:"-a,help:DNS server to query against"`
+	Type          string `arg:"-t,help:type of the records to query (e.g. A or TYPE65)"`
+	JSON          bool   `arg:"--json,help:print the response as JSON (RFC8427)"`
+	Unicode       bool   `arg:"--unicode,help:display internationalized names in Unicode"`
+	Trace         bool   `arg:"--trace,help:resolve from the root servers printing every server queried"`
+	Hints         string `arg:"--hints,help:root hints file to start tracing from (e.g. named.root)"`
+	NoMinimise    bool   `arg:"--no-minimise,help:send the full name to every server when tracing"`
+	RandomizeCase bool   `arg:"--randomize-case,help:randomize the case of the name asked and require it echoed (0x20)"`
 }
 
 var (
 	config = &cliConfig{
 		Hostname: "",
 		Address:  "8.8.8.8:53",
+		Type:     "A",
 	}
 )
 
@@ -29,19 +38,74 @@ func must(err error) {
 	os.Exit(1)
 }
 
+// commands are the subcommands of the CLI, indexed by their path
+// (e.g., `zone lint`). Without one, the CLI queries a name.
+var commands = map[string]func(args []string){
+	"zone lint": zoneLint,
+	"zone diff": zoneDiff,
+	"serve":     serve,
+	"proxy":     proxy,
+}
+
 func main() {
+	if len(os.Args) > 2 {
+		if command, ok := commands[os.Args[1]+" "+os.Args[2]]; ok {
+			command(os.Args[3:])
+			return
+		}
+	}
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
 	arg.MustParse(config)
 
-	client, err := lib.NewClient(lib.ClientConfig{
-		Address: config.Address,
-	})
+	qtype, err := lib.ParseQType(config.Type)
 	must(err)
-	defer client.Close()
 
-	ips, err := client.LookupAddr(config.Hostname)
+	msg, err := query(qtype)
 	must(err)
 
-	for _, ip := range ips {
-		fmt.Println(ip)
+	if config.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		must(encoder.Encode(msg))
+		return
 	}
+
+	// the response was printed already as the last step of the
+	// trace.
+	if config.Trace {
+		return
+	}
+
+	if config.Unicode {
+		fmt.Print(lib.UnicodeNames(msg.String()))
+		return
+	}
+
+	fmt.Print(msg)
+}
+
+// query looks up the records of type `qtype` of the name of the CLI,
+// either asking the server of the CLI or tracing its resolution.
+func query(qtype lib.QType) (msg *lib.Message, err error) {
+	if config.Trace {
+		return traceName(qtype)
+	}
+
+	client, err := lib.NewClient(lib.ClientConfig{
+		Address:       config.Address,
+		RandomizeCase: config.RandomizeCase,
+	})
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	return client.Query(config.Hostname, qtype)
 }
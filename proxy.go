@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cirocosta/rawdns/lib"
+)
+
+type proxyConfig struct {
+	Listen    string        `arg:"-l,help:address to listen on over both UDP and TCP"`
+	Upstreams []string      `arg:"--upstream,separate,required,help:server to forward queries to (may be repeated)"`
+	Strategy  string        `arg:"-s,help:order to try upstreams in: sequential or random or fastest"`
+	Timeout   time.Duration `arg:"--timeout,help:how long to wait for an upstream before failing over"`
+	Cache     int           `arg:"--cache,help:number of RRsets to cache (0 disables caching)"`
+	Stale     bool          `arg:"--serve-stale,help:answer with expired records when upstreams fail"`
+}
+
+// proxy implements `rawdns proxy --upstream 10.0.0.2:53`, forwarding
+// the queries it receives to upstream servers until interrupted.
+func proxy(args []string) {
+	var (
+		config = &proxyConfig{
+			Listen:   ":53",
+			Strategy: "sequential",
+			Timeout:  2 * time.Second,
+		}
+	)
+
+	parse("rawdns proxy", config, args)
+
+	strategy, err := lib.ParseStrategy(config.Strategy)
+	must(err)
+
+	handler, err := lib.NewProxy(lib.ProxyConfig{
+		Upstreams: config.Upstreams,
+		Strategy:  strategy,
+		Timeout:   config.Timeout,
+	})
+	must(err)
+
+	server := &lib.Server{
+		Addr:    config.Listen,
+		Handler: handler,
+	}
+
+	if config.Cache > 0 {
+		cache := lib.NewCache(lib.CacheConfig{
+			MaxEntries: config.Cache,
+			ServeStale: config.Stale,
+		})
+
+		server.Handler = cache.Handler(handler)
+	}
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(ctx)
+	}()
+
+	fmt.Printf("forwarding %s to %v (%s)\n", config.Listen, config.Upstreams, strategy)
+
+	err = server.ListenAndServe()
+	if err != lib.ErrServerClosed {
+		must(err)
+	}
+}
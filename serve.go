@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cirocosta/rawdns/lib"
+)
+
+type serveConfig struct {
+	Listen string   `arg:"-l,help:address to listen on over both UDP and TCP"`
+	Zones  []string `arg:"--zone,separate,required,help:zone to serve as origin=path (may be repeated)"`
+}
+
+// serve implements `rawdns serve --zone example.com=example.com.zone`,
+// answering authoritatively for the zones loaded from master files
+// until interrupted.
+func serve(args []string) {
+	var (
+		config = &serveConfig{
+			Listen: ":53",
+		}
+		mux = lib.NewServeMux()
+	)
+
+	parse("rawdns serve", config, args)
+
+	for _, spec := range config.Zones {
+		origin, path, ok := strings.Cut(spec, "=")
+		if !ok {
+			must(fmt.Errorf("zone %s must be in the form origin=path", spec))
+		}
+
+		rrs, err := lib.ParseZoneFile(path, origin)
+		must(err)
+
+		zone, err := lib.NewZone(origin, rrs)
+		must(err)
+
+		must(mux.Handle(zone.Origin(), zone))
+		fmt.Printf("serving %s (%d records) from %s\n", zone.Origin(), len(rrs), path)
+	}
+
+	server := &lib.Server{
+		Addr:    config.Listen,
+		Handler: mux,
+	}
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		server.Shutdown(ctx)
+	}()
+
+	err := server.ListenAndServe()
+	if err != lib.ErrServerClosed {
+		must(err)
+	}
+}
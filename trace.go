@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cirocosta/rawdns/lib"
+)
+
+// traceName implements `rawdns --trace example.com`, resolving the name
+// iteratively from the root servers (those of `--hints`, if given) and
+// printing the records each server responds with, like `dig +trace`.
+// Lookups nested in others (e.g., finding the addresses of name servers
+// that come without glue) are indented.
+func traceName(qtype lib.QType) (msg *lib.Message, err error) {
+	var hints []*lib.RR
+
+	if config.Hints != "" {
+		hints, err = lib.ParseZoneFile(config.Hints, ".")
+		if err != nil {
+			return
+		}
+	}
+
+	resolver, err := lib.NewResolver(lib.ResolverConfig{
+		Hints:               hints,
+		DisableMinimisation: config.NoMinimise,
+		Trace:               printStep,
+	})
+	if err != nil {
+		return
+	}
+
+	return resolver.Resolve(context.Background(), config.Hostname, qtype)
+}
+
+func printStep(step lib.ResolverStep) {
+	var indent = strings.Repeat("  ", step.Depth)
+
+	if step.Err != nil {
+		fmt.Printf("%s;; %s %s from %s (%s) at %s failed: %v\n\n",
+			indent, step.Question.QNAME, step.Question.QTYPE,
+			step.Server, step.Address, step.Zone, step.Err)
+		return
+	}
+
+	var res = step.Response
+
+	for _, section := range [][]*lib.RR{res.Answers, res.Authorities, res.Additionals} {
+		for _, rr := range section {
+			fmt.Printf("%s%s\n", indent, rr)
+		}
+	}
+
+	fmt.Printf("%s;; %s for %s %s from %s (%s) at %s in %s\n\n",
+		indent, lib.RCODE(res.RCODE), step.Question.QNAME, step.Question.QTYPE,
+		step.Server, step.Address, step.Zone, step.RTT.Round(100*time.Microsecond))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/cirocosta/rawdns/lib"
+)
+
+type zoneLintConfig struct {
+	File   string `arg:"positional,required,help:master file to check"`
+	Origin string `arg:"-o,help:origin of the zone (defaults to the owner of the first record)"`
+}
+
+// zoneLint implements `rawdns zone lint <file>`, reporting the
+// problems found in a master file. It exits with a non-zero code
+// if any of them is an error so that it can be used in CI.
+func zoneLint(args []string) {
+	var config = &zoneLintConfig{}
+
+	parse("rawdns zone lint", config, args)
+
+	file, err := os.Open(config.File)
+	must(err)
+	defer file.Close()
+
+	rrs, err := lib.ParseZone(file, config.Origin, config.File)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+
+	origin := config.Origin
+	if origin == "" && len(rrs) > 0 {
+		origin = rrs[0].NAME
+	}
+
+	var failed bool
+	for _, problem := range lib.LintZone(origin, rrs) {
+		fmt.Println(problem)
+		failed = failed || problem.Severity == lib.LintError
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+type zoneDiffConfig struct {
+	From   string `arg:"positional,required,help:master file of the old version of the zone (or axfr://server[:port] to transfer it)"`
+	To     string `arg:"positional,required,help:master file of the new version of the zone (or axfr://server[:port] to transfer it)"`
+	Origin string `arg:"-o,required,help:origin of the zone"`
+	Format string `arg:"-f,help:output format (text|update|ixfr)"`
+}
+
+// zoneDiff implements `rawdns zone diff <from> <to>`, printing the
+// changes between two versions of a zone either as text, as a dynamic
+// update message or as an incremental zone transfer. Each version is
+// read from a master file or transferred from a server (AXFR).
+func zoneDiff(args []string) {
+	var config = &zoneDiffConfig{
+		Format: "text",
+	}
+
+	parse("rawdns zone diff", config, args)
+
+	from, err := loadZone(config.From, config.Origin)
+	must(err)
+
+	to, err := loadZone(config.To, config.Origin)
+	must(err)
+
+	delta := lib.DiffZone(from, to)
+
+	switch config.Format {
+	case "text":
+		fmt.Print(delta)
+	case "update":
+		msg, err := delta.UpdateMessage(config.Origin)
+		must(err)
+
+		fmt.Print(msg)
+	case "ixfr":
+		rrs, err := delta.IXFR()
+		must(err)
+
+		for _, rr := range rrs {
+			fmt.Println(rr)
+		}
+	default:
+		must(fmt.Errorf("unknown format %s", config.Format))
+	}
+}
+
+// axfrScheme prefixes the versions of a zone given to `zone diff` that
+// are transferred from a server rather than read from a master file.
+const axfrScheme = "axfr://"
+
+// loadZone reads the records of the zone `origin` from `source`: either
+// a master file or `axfr://server[:port]`, which transfers the zone from
+// the server (port 53 by default).
+func loadZone(source, origin string) (rrs []*lib.RR, err error) {
+	server, ok := strings.CutPrefix(source, axfrScheme)
+	if !ok {
+		return lib.ParseZoneFile(source, origin)
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	client, err := lib.NewClient(lib.ClientConfig{Address: server})
+	if err != nil {
+		return
+	}
+
+	return client.Transfer(context.Background(), origin)
+}
+
+// parse parses the arguments of a subcommand into `config`,
+// printing the usage and exiting if they are not valid.
+func parse(program string, config interface{}, args []string) {
+	parser, err := arg.NewParser(arg.Config{Program: program}, config)
+	must(err)
+
+	err = parser.Parse(args)
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		os.Exit(0)
+	}
+
+	if err != nil {
+		parser.Fail(err.Error())
+	}
+}